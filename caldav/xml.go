@@ -0,0 +1,282 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	NsDAV    = "DAV:"
+	NsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	NsCS     = "http://calendarserver.org/ns/"
+	NsApple  = "http://apple.com/ns/ical/"
+
+	timeRangeForm = "20060102T150405Z"
+)
+
+// prefixes of namespaces declared on multistatus element
+var prefixes = map[string]string{NsDAV: "D", NsCalDAV: "C", NsCS: "CS", NsApple: "A"}
+
+// Name of property, like xml.Name{Space: "DAV:", Local: "getetag"}
+type Name = xml.Name
+
+var (
+	ResourceType                  = Name{Space: NsDAV, Local: "resourcetype"}
+	DisplayName                   = Name{Space: NsDAV, Local: "displayname"}
+	CurrentUserPrincipal          = Name{Space: NsDAV, Local: "current-user-principal"}
+	PrincipalURL                  = Name{Space: NsDAV, Local: "principal-URL"}
+	Owner                         = Name{Space: NsDAV, Local: "owner"}
+	GetETag                       = Name{Space: NsDAV, Local: "getetag"}
+	GetContentType                = Name{Space: NsDAV, Local: "getcontenttype"}
+	SupportedReportSet            = Name{Space: NsDAV, Local: "supported-report-set"}
+	CurrentUserPrivilegeSet       = Name{Space: NsDAV, Local: "current-user-privilege-set"}
+	CalendarHomeSet               = Name{Space: NsCalDAV, Local: "calendar-home-set"}
+	CalendarUserAddressSet        = Name{Space: NsCalDAV, Local: "calendar-user-address-set"}
+	SupportedCalendarComponentSet = Name{Space: NsCalDAV, Local: "supported-calendar-component-set"}
+	CalendarData                  = Name{Space: NsCalDAV, Local: "calendar-data"}
+	GetCTag                       = Name{Space: NsCS, Local: "getctag"}
+)
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+type propList struct {
+	Names []anyElement `xml:",any"`
+}
+
+func (p *propList) names() []Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]Name, len(p.Names))
+	for i, n := range p.Names {
+		names[i] = n.XMLName
+	}
+	return names
+}
+
+// Propfind is PROPFIND request body, empty Props means allprop
+type Propfind struct {
+	Props []Name
+}
+
+type propfindXML struct {
+	XMLName xml.Name  `xml:"DAV: propfind"`
+	Prop    *propList `xml:"DAV: prop"`
+}
+
+// ParsePropfind read PROPFIND body, empty body is allprop request
+func ParsePropfind(r io.Reader) (Propfind, error) {
+	var pf propfindXML
+	err := xml.NewDecoder(r).Decode(&pf)
+	if err == io.EOF {
+		return Propfind{}, nil
+	}
+	return Propfind{pf.Prop.names()}, err
+}
+
+// Report is calendar-query or calendar-multiget REPORT body
+type Report struct {
+	Multiget bool
+	Props    []Name
+	Hrefs    []string
+	// Start and End is time-range of VEVENT filter, zero values mean no limit
+	Start time.Time
+	End   time.Time
+}
+
+type compFilter struct {
+	Name      string       `xml:"name,attr"`
+	TimeRange *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Comps     []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+type reportXML struct {
+	XMLName xml.Name
+	Prop    *propList   `xml:"DAV: prop"`
+	Hrefs   []string    `xml:"DAV: href"`
+	Filter  *compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+// ErrUnsupportedReport is returned for REPORT other than calendar-query and calendar-multiget
+var ErrUnsupportedReport = &Error{http.StatusForbidden, Name{Space: NsDAV, Local: "supported-report"}}
+
+// ErrInvalidCalendarData is returned for PUT of body which is not one VEVENT series
+var ErrInvalidCalendarData = &Error{http.StatusForbidden, Name{Space: NsCalDAV, Local: "valid-calendar-data"}}
+
+// Error is DAV error with precondition element
+type Error struct {
+	Status       int
+	Precondition Name
+}
+
+func (e *Error) Error() string {
+	return e.Precondition.Local
+}
+
+// ParseReport read REPORT body
+func ParseReport(r io.Reader) (Report, error) {
+	var rx reportXML
+	if err := xml.NewDecoder(r).Decode(&rx); err != nil {
+		return Report{}, err
+	}
+	report := Report{Props: rx.Prop.names(), Hrefs: rx.Hrefs}
+	switch rx.XMLName {
+	case Name{Space: NsCalDAV, Local: "calendar-multiget"}:
+		report.Multiget = true
+	case Name{Space: NsCalDAV, Local: "calendar-query"}:
+		if tr := findTimeRange(rx.Filter); tr != nil {
+			var err error
+			if tr.Start != "" {
+				if report.Start, err = time.Parse(timeRangeForm, tr.Start); err != nil {
+					return report, err
+				}
+			}
+			if tr.End != "" {
+				if report.End, err = time.Parse(timeRangeForm, tr.End); err != nil {
+					return report, err
+				}
+			}
+		}
+	default:
+		return report, ErrUnsupportedReport
+	}
+	return report, nil
+}
+
+func findTimeRange(cf *compFilter) *timeRange {
+	if cf == nil {
+		return nil
+	}
+	if cf.TimeRange != nil {
+		return cf.TimeRange
+	}
+	for i := range cf.Comps {
+		if tr := findTimeRange(&cf.Comps[i]); tr != nil {
+			return tr
+		}
+	}
+	return nil
+}
+
+// Props are values of properties of one resource, value is inner xml of property element
+type Props map[Name]string
+
+// Href return inner xml of property which contains href
+func Href(href string) string {
+	return "<D:href>" + escape(href) + "</D:href>"
+}
+
+// Text return escaped inner xml of text property
+func Text(text string) string {
+	return escape(text)
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Multistatus collects responses of PROPFIND and REPORT
+type Multistatus struct {
+	responses []response
+}
+
+type response struct {
+	Href      string     `xml:"D:href"`
+	Propstats []propstat `xml:"D:propstat,omitempty"`
+	Status    string     `xml:"D:status,omitempty"`
+}
+
+type propstat struct {
+	Prop   propValues `xml:"D:prop"`
+	Status string     `xml:"D:status"`
+}
+
+type propValues struct {
+	Values []propValue
+}
+
+type propValue struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// Add response for href with requested properties, all known properties are returned if names is empty
+func (ms *Multistatus) Add(href string, props Props, names []Name) {
+	var found, missing []propValue
+	if len(names) == 0 {
+		for name := range props {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if value, ok := props[name]; ok {
+			found = append(found, propValue{elementName(name), value})
+		} else {
+			missing = append(missing, propValue{elementName(name), ""})
+		}
+	}
+	resp := response{Href: href}
+	if len(found) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{propValues{found}, statusLine(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{propValues{missing}, statusLine(http.StatusNotFound)})
+	}
+	ms.responses = append(ms.responses, resp)
+}
+
+// AddStatus add response for href without properties, like 404 for unknown href of multiget
+func (ms *Multistatus) AddStatus(href string, status int) {
+	ms.responses = append(ms.responses, response{Href: href, Status: statusLine(status)})
+}
+
+// elementName use declared prefix for known namespace
+func elementName(name Name) xml.Name {
+	if prefix, ok := prefixes[name.Space]; ok {
+		return xml.Name{Local: prefix + ":" + name.Local}
+	}
+	return name
+}
+
+func statusLine(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+type multistatusXML struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	D         string     `xml:"xmlns:D,attr"`
+	C         string     `xml:"xmlns:C,attr"`
+	CS        string     `xml:"xmlns:CS,attr"`
+	A         string     `xml:"xmlns:A,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+// WriteTo write 207 Multi-Status response
+func (ms *Multistatus) WriteTo(w http.ResponseWriter) error {
+	w.Header().Set("content-type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(multistatusXML{D: NsDAV, C: NsCalDAV, CS: NsCS, A: NsApple, Responses: ms.responses})
+}
+
+// WriteError write DAV error response with precondition element
+func WriteError(w http.ResponseWriter, err *Error) {
+	w.Header().Set("content-type", "application/xml; charset=utf-8")
+	w.WriteHeader(err.Status)
+	_, _ = io.WriteString(w, xml.Header+`<D:error xmlns:D="DAV:"><`+err.Precondition.Local+` xmlns="`+err.Precondition.Space+`"/></D:error>`)
+}
@@ -0,0 +1,93 @@
+package caldav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePropfind(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:" xmlns:CS="http://calendarserver.org/ns/">
+  <D:prop><D:getetag/><CS:getctag/></D:prop>
+</D:propfind>`
+	pf, err := ParsePropfind(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pf.Props) != 2 || pf.Props[0] != GetETag || pf.Props[1] != GetCTag {
+		t.Errorf("ParsePropfind() = %v", pf.Props)
+	}
+
+	pf, err = ParsePropfind(strings.NewReader(""))
+	if err != nil || len(pf.Props) != 0 {
+		t.Errorf("ParsePropfind() of empty body = %v, %v, want allprop", pf.Props, err)
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	query := `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">
+    <C:time-range start="20210801T000000Z" end="20210901T000000Z"/>
+  </C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`
+	report, err := ParseReport(strings.NewReader(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Multiget || !report.Start.Equal(time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)) ||
+		!report.End.Equal(time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseReport() = %+v", report)
+	}
+
+	multiget := `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>/dav/User1/events/a.ics</D:href>
+  <D:href>/dav/User1/events/b.ics</D:href>
+</C:calendar-multiget>`
+	report, err = ParseReport(strings.NewReader(multiget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Multiget || len(report.Hrefs) != 2 || len(report.Props) != 2 || report.Props[1] != CalendarData {
+		t.Errorf("ParseReport() = %+v", report)
+	}
+
+	_, err = ParseReport(strings.NewReader(`<D:sync-collection xmlns:D="DAV:"/>`))
+	if err != ErrUnsupportedReport {
+		t.Errorf("ParseReport() error = %v, want %v", err, ErrUnsupportedReport)
+	}
+}
+
+func TestMultistatus(t *testing.T) {
+	var ms Multistatus
+	ms.Add("/dav/User1/events/", Props{
+		ResourceType: "<D:collection/><C:calendar/>",
+		DisplayName:  Text("Work & fun"),
+	}, []Name{DisplayName, GetCTag})
+	ms.AddStatus("/dav/User1/events/missing.ics", http.StatusNotFound)
+
+	w := httptest.NewRecorder()
+	if err := ms.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusMultiStatus {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMultiStatus)
+	}
+	got := w.Body.String()
+	for _, want := range []string{
+		`<D:multistatus xmlns:D="DAV:"`,
+		`<D:href>/dav/User1/events/</D:href>`,
+		`<D:displayname>Work &amp; fun</D:displayname>`,
+		`<D:status>HTTP/1.1 200 OK</D:status>`,
+		`<CS:getctag></CS:getctag>`,
+		`<D:status>HTTP/1.1 404 Not Found</D:status>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteTo() = %s, want it to contain %s", got, want)
+		}
+	}
+}
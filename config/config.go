@@ -0,0 +1,400 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StorageMemory = "memory"
+	StorageMySQL  = "mysql"
+
+	NotifierLog     = "log"
+	NotifierSMTP    = "smtp"
+	NotifierWebhook = "webhook"
+
+	// envPrefix is prefix of environment variables, like CALENDAR_DB_DSN
+	envPrefix = "CALENDAR_"
+	// minJwtKeyLength is minimal length of key for HS256 signature
+	minJwtKeyLength = 16
+)
+
+// Config is settings of calendar server.
+// Values are taken in order of precedence: flags, environment variables, config file, defaults
+type Config struct {
+	Storage   string          `yaml:"storage" toml:"storage"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	DB        DBConfig        `yaml:"db" toml:"db"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown" toml:"shutdown"`
+	Reminders RemindersConfig `yaml:"reminders" toml:"reminders"`
+}
+
+type ServerConfig struct {
+	Addr        string `yaml:"addr" toml:"addr"`
+	MetricsAddr string `yaml:"metrics_addr" toml:"metrics_addr"`
+}
+
+type DBConfig struct {
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// DSNFile is file with DSN, like docker secret, because DSN contains password
+	DSNFile      string `yaml:"dsn_file" toml:"dsn_file"`
+	MaxIdleConns int    `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	MaxOpenConns int    `yaml:"max_open_conns" toml:"max_open_conns"`
+}
+
+type AuthConfig struct {
+	JwtKey          string        `yaml:"jwt_key" toml:"jwt_key"`
+	JwtKeyFile      string        `yaml:"jwt_key_file" toml:"jwt_key_file"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+}
+
+// ShutdownConfig is time given to every step of graceful shutdown
+type ShutdownConfig struct {
+	// HTTPTimeout is time to finish in-flight requests of every server
+	HTTPTimeout    time.Duration `yaml:"http_timeout" toml:"http_timeout"`
+	WorkersTimeout time.Duration `yaml:"workers_timeout" toml:"workers_timeout"`
+	DBTimeout      time.Duration `yaml:"db_timeout" toml:"db_timeout"`
+}
+
+// RemindersConfig is settings of reminder scheduler
+type RemindersConfig struct {
+	// Notifier is channel of reminders: log, smtp or webhook
+	Notifier string `yaml:"notifier" toml:"notifier"`
+	// Interval is time between checks of due reminders
+	Interval   time.Duration `yaml:"interval" toml:"interval"`
+	WebhookURL string        `yaml:"webhook_url" toml:"webhook_url"`
+	SMTP       SMTPConfig    `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	// Addr is host:port of SMTP server
+	Addr         string `yaml:"addr" toml:"addr"`
+	From         string `yaml:"from" toml:"from"`
+	Username     string `yaml:"username" toml:"username"`
+	Password     string `yaml:"password" toml:"password"`
+	PasswordFile string `yaml:"password_file" toml:"password_file"`
+}
+
+// Default return settings which are used when they are not set anywhere else
+func Default() Config {
+	return Config{
+		Storage: StorageMySQL,
+		Server: ServerConfig{
+			Addr:        ":5000",
+			MetricsAddr: ":5050",
+		},
+		DB: DBConfig{
+			MaxIdleConns: 10,
+			MaxOpenConns: 60,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  5 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		Shutdown: ShutdownConfig{
+			HTTPTimeout:    15 * time.Second,
+			WorkersTimeout: 10 * time.Second,
+			DBTimeout:      5 * time.Second,
+		},
+		Reminders: RemindersConfig{
+			Notifier: NotifierLog,
+			Interval: 30 * time.Second,
+		},
+	}
+}
+
+// option is setting which can be set by flag --name and by environment variable CALENDAR_NAME
+type option struct {
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"storage", "events storage: memory or mysql", func(c *Config, v string) error {
+		c.Storage = v
+		return nil
+	}},
+	{"addr", "address of events server", func(c *Config, v string) error {
+		c.Server.Addr = v
+		return nil
+	}},
+	{"metrics-addr", "address of metrics server", func(c *Config, v string) error {
+		c.Server.MetricsAddr = v
+		return nil
+	}},
+	{"db-dsn", "MySQL DSN", func(c *Config, v string) error {
+		c.DB.DSN, c.DB.DSNFile = v, ""
+		return nil
+	}},
+	{"db-dsn-file", "file with MySQL DSN", func(c *Config, v string) error {
+		c.DB.DSNFile, c.DB.DSN = v, ""
+		return nil
+	}},
+	{"db-max-idle-conns", "maximum number of idle db connections", func(c *Config, v string) error {
+		return parseInt(v, &c.DB.MaxIdleConns)
+	}},
+	{"db-max-open-conns", "maximum number of open db connections", func(c *Config, v string) error {
+		return parseInt(v, &c.DB.MaxOpenConns)
+	}},
+	{"jwt-key", "key of JWT signature", func(c *Config, v string) error {
+		c.Auth.JwtKey, c.Auth.JwtKeyFile = v, ""
+		return nil
+	}},
+	{"jwt-key-file", "file with key of JWT signature", func(c *Config, v string) error {
+		c.Auth.JwtKeyFile, c.Auth.JwtKey = v, ""
+		return nil
+	}},
+	{"access-token-ttl", "lifetime of access token, like 5m", func(c *Config, v string) error {
+		return parseDuration(v, &c.Auth.AccessTokenTTL)
+	}},
+	{"refresh-token-ttl", "lifetime of session without refresh, like 720h", func(c *Config, v string) error {
+		return parseDuration(v, &c.Auth.RefreshTokenTTL)
+	}},
+	{"shutdown-http-timeout", "time to drain every http server on shutdown", func(c *Config, v string) error {
+		return parseDuration(v, &c.Shutdown.HTTPTimeout)
+	}},
+	{"shutdown-workers-timeout", "time to stop background workers on shutdown", func(c *Config, v string) error {
+		return parseDuration(v, &c.Shutdown.WorkersTimeout)
+	}},
+	{"shutdown-db-timeout", "time to close db connections on shutdown", func(c *Config, v string) error {
+		return parseDuration(v, &c.Shutdown.DBTimeout)
+	}},
+	{"reminders-notifier", "channel of reminders: log, smtp or webhook", func(c *Config, v string) error {
+		c.Reminders.Notifier = v
+		return nil
+	}},
+	{"reminders-interval", "time between checks of due reminders", func(c *Config, v string) error {
+		return parseDuration(v, &c.Reminders.Interval)
+	}},
+	{"reminders-webhook-url", "URL which reminders are posted to", func(c *Config, v string) error {
+		c.Reminders.WebhookURL = v
+		return nil
+	}},
+	{"smtp-addr", "host:port of SMTP server for reminders", func(c *Config, v string) error {
+		c.Reminders.SMTP.Addr = v
+		return nil
+	}},
+	{"smtp-from", "sender address of reminders", func(c *Config, v string) error {
+		c.Reminders.SMTP.From = v
+		return nil
+	}},
+	{"smtp-username", "SMTP user", func(c *Config, v string) error {
+		c.Reminders.SMTP.Username = v
+		return nil
+	}},
+	{"smtp-password", "SMTP password", func(c *Config, v string) error {
+		c.Reminders.SMTP.Password, c.Reminders.SMTP.PasswordFile = v, ""
+		return nil
+	}},
+	{"smtp-password-file", "file with SMTP password", func(c *Config, v string) error {
+		c.Reminders.SMTP.PasswordFile, c.Reminders.SMTP.Password = v, ""
+		return nil
+	}},
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+func parseDuration(v string, dst *time.Duration) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
+
+// envName return name of environment variable of option
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Load read settings from config file, environment and command line arguments without program name,
+// file is set by --config flag or CALENDAR_CONFIG variable. Secrets are read from files and settings are validated
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	type flagValue struct {
+		opt   option
+		value string
+	}
+	var flagValues []flagValue
+	fs := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(envPrefix+"CONFIG"), "YAML or TOML config file")
+	for _, opt := range options {
+		opt := opt
+		fs.Func(opt.name, opt.usage+" (env "+envName(opt.name)+")", func(v string) error {
+			flagValues = append(flagValues, flagValue{opt, v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return cfg, fmt.Errorf("config file %s: %w", *configFile, err)
+		}
+	}
+	for _, opt := range options {
+		if v := getenv(envName(opt.name)); v != "" {
+			if err := opt.set(&cfg, v); err != nil {
+				return cfg, fmt.Errorf("%s: %w", envName(opt.name), err)
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := fv.opt.set(&cfg, fv.value); err != nil {
+			return cfg, fmt.Errorf("--%s: %w", fv.opt.name, err)
+		}
+	}
+
+	if err := cfg.readSecrets(); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadFile decode YAML or TOML file by its extension over defaults, unknown keys are errors
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.NewDecoder(f).Decode(cfg)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown key %s", undecoded[0])
+		}
+	default:
+		err = errors.New("unknown format, use .yml, .yaml or .toml")
+	}
+	if err != nil {
+		return err
+	}
+	if cfg.DB.DSN != "" && cfg.DB.DSNFile != "" {
+		return errors.New("only one of db.dsn and db.dsn_file can be set")
+	}
+	if cfg.Auth.JwtKey != "" && cfg.Auth.JwtKeyFile != "" {
+		return errors.New("only one of auth.jwt_key and auth.jwt_key_file can be set")
+	}
+	if cfg.Reminders.SMTP.Password != "" && cfg.Reminders.SMTP.PasswordFile != "" {
+		return errors.New("only one of reminders.smtp.password and reminders.smtp.password_file can be set")
+	}
+	return nil
+}
+
+// readSecrets replace secrets with content of their files
+func (c *Config) readSecrets() error {
+	var err error
+	if c.DB.DSNFile != "" {
+		if c.DB.DSN, err = readSecret(c.DB.DSNFile); err != nil {
+			return err
+		}
+	}
+	if c.Auth.JwtKeyFile != "" {
+		if c.Auth.JwtKey, err = readSecret(c.Auth.JwtKeyFile); err != nil {
+			return err
+		}
+	}
+	if c.Reminders.SMTP.PasswordFile != "" {
+		if c.Reminders.SMTP.Password, err = readSecret(c.Reminders.SMTP.PasswordFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("secret file: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Validate check that settings are complete and consistent
+func (c *Config) Validate() error {
+	var errs []string
+	switch c.Storage {
+	case StorageMemory:
+	case StorageMySQL:
+		if c.DB.DSN == "" {
+			errs = append(errs, "db dsn is required for mysql storage")
+		}
+		if c.DB.MaxOpenConns <= 0 || c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+			errs = append(errs, "db connections must be 0 <= max_idle_conns <= max_open_conns and max_open_conns > 0")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unknown storage %q, use memory or mysql", c.Storage))
+	}
+	for _, addr := range []string{c.Server.Addr, c.Server.MetricsAddr} {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Sprintf("wrong address %q", addr))
+		}
+	}
+	if c.Server.Addr == c.Server.MetricsAddr {
+		errs = append(errs, "events and metrics servers must have different addresses")
+	}
+	if len(c.Auth.JwtKey) < minJwtKeyLength {
+		errs = append(errs, fmt.Sprintf("jwt key must be at least %d bytes", minJwtKeyLength))
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		errs = append(errs, "token lifetimes must be 0 < access_token_ttl <= refresh_token_ttl")
+	}
+	if c.Shutdown.HTTPTimeout <= 0 || c.Shutdown.WorkersTimeout <= 0 || c.Shutdown.DBTimeout <= 0 {
+		errs = append(errs, "shutdown timeouts must be positive")
+	}
+	switch c.Reminders.Notifier {
+	case NotifierLog:
+	case NotifierSMTP:
+		if _, _, err := net.SplitHostPort(c.Reminders.SMTP.Addr); err != nil || c.Reminders.SMTP.From == "" {
+			errs = append(errs, "smtp addr and from are required for smtp notifier")
+		}
+	case NotifierWebhook:
+		if u, err := url.Parse(c.Reminders.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, "http or https webhook url is required for webhook notifier")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unknown notifier %q, use log, smtp or webhook", c.Reminders.Notifier))
+	}
+	if c.Reminders.Interval < time.Second {
+		errs = append(errs, "reminders interval must be at least 1s")
+	}
+	if len(errs) > 0 {
+		return errors.New("config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	vars := map[string]string{
+		"CALENDAR_STORAGE":          "memory",
+		"CALENDAR_ADDR":             ":6000",
+		"CALENDAR_JWT_KEY":          "key from environment",
+		"CALENDAR_ACCESS_TOKEN_TTL": "10m",
+	}
+	cfg, err := Load([]string{"--addr", ":7000"}, env(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage != StorageMemory {
+		t.Errorf("got storage %q, want memory from environment", cfg.Storage)
+	}
+	if cfg.Server.Addr != ":7000" {
+		t.Errorf("got addr %q, want :7000 from flag", cfg.Server.Addr)
+	}
+	if cfg.Server.MetricsAddr != ":5050" {
+		t.Errorf("got metrics addr %q, want default :5050", cfg.Server.MetricsAddr)
+	}
+	if cfg.Auth.AccessTokenTTL != 10*time.Minute {
+		t.Errorf("got access token ttl %v, want 10m", cfg.Auth.AccessTokenTTL)
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "jwt_key")
+	if err := os.WriteFile(keyFile, []byte("key from secret file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{
+		"CALENDAR_STORAGE": "memory",
+		"CALENDAR_JWT_KEY": "key from environment",
+	}
+	cfg, err := Load([]string{"--jwt-key-file", keyFile}, env(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.JwtKey != "key from secret file" {
+		t.Errorf("got jwt key %q, want content of secret file", cfg.Auth.JwtKey)
+	}
+
+	_, err = Load([]string{"--storage", "memory", "--jwt-key-file", filepath.Join(dir, "missing")}, env(nil))
+	if err == nil {
+		t.Error("missing secret file is not an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{"unknown storage", []string{"--storage", "file", "--jwt-key", "0123456789abcdef"}, "unknown storage"},
+		{"mysql without dsn", []string{"--jwt-key", "0123456789abcdef"}, "dsn is required"},
+		{"short jwt key", []string{"--storage", "memory", "--jwt-key", "secret"}, "jwt key"},
+		{"same addresses", []string{"--storage", "memory", "--jwt-key", "0123456789abcdef", "--metrics-addr", ":5000"}, "different addresses"},
+		{"wrong number", []string{"--db-max-open-conns", "ten"}, "--db-max-open-conns"},
+		{"smtp without server", []string{"--storage", "memory", "--jwt-key", "0123456789abcdef", "--reminders-notifier", "smtp"}, "smtp addr"},
+		{"webhook without url", []string{"--storage", "memory", "--jwt-key", "0123456789abcdef", "--reminders-notifier", "webhook"}, "webhook url"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Load(c.args, env(nil))
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Errorf("got error %v, want error with %q", err, c.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE calendar.events DROP COLUMN rrule;
\ No newline at end of file
@@ -0,0 +1,3 @@
+
+ALTER TABLE calendar.events
+    ADD COLUMN rrule VARCHAR(256) NOT NULL DEFAULT '' AFTER notes;
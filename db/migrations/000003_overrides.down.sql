@@ -0,0 +1 @@
+DROP TABLE overrides;
\ No newline at end of file
@@ -0,0 +1,20 @@
+
+CREATE TABLE calendar.overrides (
+                                 event_id BINARY(16) NOT NULL,
+                                 recurrence_id TIMESTAMP NOT NULL,
+                                 cancelled TINYINT(1) NOT NULL DEFAULT 0,
+                                 title VARCHAR(100) DEFAULT NULL,
+                                 description VARCHAR(256) DEFAULT NULL,
+                                 time TIMESTAMP NULL DEFAULT NULL,
+                                 timezone VARCHAR(30) DEFAULT NULL,
+                                 duration INT DEFAULT NULL,
+                                 notes TEXT DEFAULT NULL,
+                                 PRIMARY KEY (event_id, recurrence_id)
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
+
+ALTER TABLE calendar.overrides
+    ADD CONSTRAINT overrides_ibfk_1 FOREIGN KEY (event_id)
+        REFERENCES calendar.events(id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE calendar.users DROP INDEX idx_users_feed_hash, DROP COLUMN feed_hash;
\ No newline at end of file
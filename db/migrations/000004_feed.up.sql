@@ -0,0 +1,4 @@
+
+ALTER TABLE calendar.users
+    ADD COLUMN feed_hash VARCHAR(64) DEFAULT NULL,
+    ADD INDEX idx_users_feed_hash (feed_hash);
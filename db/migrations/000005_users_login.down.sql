@@ -0,0 +1 @@
+ALTER TABLE calendar.users DROP INDEX idx_users_login;
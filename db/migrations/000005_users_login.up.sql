@@ -0,0 +1,3 @@
+ALTER TABLE calendar.users
+    MODIFY COLUMN login VARCHAR(64) NOT NULL,
+    ADD UNIQUE INDEX idx_users_login (login);
@@ -0,0 +1,20 @@
+CREATE TABLE calendar.sessions (
+                                   id BINARY(16) NOT NULL,
+                                   user_id BINARY(16) NOT NULL,
+                                   refresh_hash VARCHAR(64) NOT NULL,
+                                   previous_hash VARCHAR(64) DEFAULT NULL,
+                                   user_agent VARCHAR(256) DEFAULT NULL,
+                                   created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+                                   last_used_at TIMESTAMP NULL DEFAULT NULL,
+                                   expires_at TIMESTAMP NOT NULL,
+                                   revoked_at TIMESTAMP NULL DEFAULT NULL,
+                                   PRIMARY KEY (id),
+                                   INDEX idx_sessions_user_id (user_id),
+                                   INDEX idx_sessions_refresh_hash (refresh_hash),
+                                   INDEX idx_sessions_previous_hash (previous_hash),
+                                   CONSTRAINT sessions_ibfk_1 FOREIGN KEY (user_id)
+                                       REFERENCES calendar.users(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
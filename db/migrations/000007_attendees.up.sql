@@ -0,0 +1,21 @@
+
+CREATE TABLE calendar.attendees (
+                                 id BINARY(16) NOT NULL,
+                                 event_id BINARY(16) NOT NULL,
+                                 user_id BINARY(16) DEFAULT NULL,
+                                 login VARCHAR(64) DEFAULT NULL,
+                                 email VARCHAR(254) DEFAULT NULL,
+                                 role VARCHAR(20) NOT NULL DEFAULT 'req-participant',
+                                 status VARCHAR(20) NOT NULL DEFAULT 'needs-action',
+                                 updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_attendees_event_id (event_id),
+                                 INDEX idx_attendees_user_id (user_id)
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
+
+ALTER TABLE calendar.attendees
+    ADD CONSTRAINT attendees_ibfk_1 FOREIGN KEY (event_id)
+        REFERENCES calendar.events(id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE calendar.users DROP COLUMN work_start, DROP COLUMN work_end;
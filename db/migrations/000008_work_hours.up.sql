@@ -0,0 +1,3 @@
+ALTER TABLE calendar.users
+    ADD COLUMN work_start VARCHAR(5) DEFAULT NULL,
+    ADD COLUMN work_end VARCHAR(5) DEFAULT NULL;
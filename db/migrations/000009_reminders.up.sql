@@ -0,0 +1,35 @@
+CREATE TABLE calendar.reminders (
+                                 id BINARY(16) NOT NULL,
+                                 event_id BINARY(16) NOT NULL,
+                                 before_start BIGINT NOT NULL DEFAULT 0,
+                                 at VARCHAR(5) DEFAULT NULL,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_reminders_event_id (event_id),
+                                 CONSTRAINT reminders_ibfk_1 FOREIGN KEY (event_id)
+                                     REFERENCES calendar.events(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
+
+CREATE TABLE calendar.triggers (
+                                 id BINARY(16) NOT NULL,
+                                 event_id BINARY(16) NOT NULL,
+                                 reminder_id BINARY(16) NOT NULL,
+                                 start TIMESTAMP NOT NULL,
+                                 fire_at TIMESTAMP NOT NULL,
+                                 status VARCHAR(10) NOT NULL DEFAULT 'pending',
+                                 attempts INT NOT NULL DEFAULT 0,
+                                 last_error VARCHAR(256) DEFAULT NULL,
+                                 locked_until TIMESTAMP NULL DEFAULT NULL,
+                                 sent_at TIMESTAMP NULL DEFAULT NULL,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_triggers_event_id (event_id),
+                                 INDEX idx_triggers_reminder_id (reminder_id),
+                                 INDEX idx_triggers_due (status, fire_at),
+                                 CONSTRAINT triggers_ibfk_1 FOREIGN KEY (event_id)
+                                     REFERENCES calendar.events(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,38 @@
+CREATE TABLE calendar.webhooks (
+                                 id BINARY(16) NOT NULL,
+                                 user_id BINARY(16) NOT NULL,
+                                 url VARCHAR(2048) NOT NULL,
+                                 secret VARCHAR(64) NOT NULL,
+                                 created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_webhooks_user_id (user_id),
+                                 CONSTRAINT webhooks_ibfk_1 FOREIGN KEY (user_id)
+                                     REFERENCES calendar.users(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
+
+CREATE TABLE calendar.deliveries (
+                                 id BINARY(16) NOT NULL,
+                                 webhook_id BINARY(16) NOT NULL,
+                                 user_id BINARY(16) NOT NULL,
+                                 payload_id BINARY(16) NOT NULL,
+                                 type VARCHAR(20) NOT NULL,
+                                 payload TEXT NOT NULL,
+                                 status VARCHAR(10) NOT NULL DEFAULT 'pending',
+                                 attempts INT NOT NULL DEFAULT 0,
+                                 response_status INT NOT NULL DEFAULT 0,
+                                 last_error VARCHAR(256) DEFAULT NULL,
+                                 next_attempt_at TIMESTAMP NOT NULL,
+                                 created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+                                 delivered_at TIMESTAMP NULL DEFAULT NULL,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_deliveries_webhook_id (webhook_id, created_at),
+                                 INDEX idx_deliveries_due (status, next_attempt_at),
+                                 CONSTRAINT deliveries_ibfk_1 FOREIGN KEY (webhook_id)
+                                     REFERENCES calendar.webhooks(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,12 @@
+CREATE TABLE calendar.changes (
+                                 seq BIGINT NOT NULL AUTO_INCREMENT,
+                                 user_id BINARY(16) NOT NULL,
+                                 event_id BINARY(16) NOT NULL,
+                                 type VARCHAR(10) NOT NULL,
+                                 at TIMESTAMP NOT NULL,
+                                 PRIMARY KEY (seq),
+                                 INDEX idx_changes_user_id (user_id, seq)
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
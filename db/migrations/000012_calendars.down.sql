@@ -0,0 +1,2 @@
+ALTER TABLE calendar.events DROP INDEX idx_events_calendar_id, DROP COLUMN calendar_id;
+DROP TABLE calendars;
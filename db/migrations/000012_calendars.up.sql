@@ -0,0 +1,26 @@
+CREATE TABLE calendar.calendars (
+                                 id BINARY(16) NOT NULL,
+                                 user_id BINARY(16) NOT NULL,
+                                 name VARCHAR(100) NOT NULL,
+                                 color VARCHAR(7) NOT NULL DEFAULT '',
+                                 timezone VARCHAR(64) NOT NULL DEFAULT '',
+                                 reminders TEXT DEFAULT NULL,
+                                 created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+                                 PRIMARY KEY (id),
+                                 INDEX idx_calendars_user_id (user_id),
+                                 CONSTRAINT calendars_ibfk_1 FOREIGN KEY (user_id)
+                                     REFERENCES calendar.users(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
+
+-- default calendar of user has id of user, existing events are moved to it
+INSERT INTO calendar.calendars (id, user_id, name, reminders)
+SELECT id, id, 'Calendar', '[]' FROM calendar.users;
+
+ALTER TABLE calendar.events
+    ADD COLUMN calendar_id BINARY(16) DEFAULT NULL AFTER user_id,
+    ADD INDEX idx_events_calendar_id (calendar_id);
+
+UPDATE calendar.events SET calendar_id = user_id;
@@ -0,0 +1,16 @@
+CREATE TABLE calendar.shares (
+                                 calendar_id BINARY(16) NOT NULL,
+                                 user_id BINARY(16) NOT NULL,
+                                 login VARCHAR(64) NOT NULL,
+                                 access VARCHAR(10) NOT NULL,
+                                 created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+                                 PRIMARY KEY (calendar_id, user_id),
+                                 INDEX idx_shares_user_id (user_id),
+                                 CONSTRAINT shares_ibfk_1 FOREIGN KEY (calendar_id)
+                                     REFERENCES calendar.calendars(id) ON DELETE CASCADE,
+                                 CONSTRAINT shares_ibfk_2 FOREIGN KEY (user_id)
+                                     REFERENCES calendar.users(id) ON DELETE CASCADE
+)
+    ENGINE = INNODB,
+CHARACTER SET utf8mb4,
+COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1 @@
+ALTER TABLE calendar.events ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE calendar.events DROP COLUMN resource_name;
@@ -0,0 +1 @@
+ALTER TABLE calendar.events ADD COLUMN resource_name VARCHAR(255) NOT NULL DEFAULT '';
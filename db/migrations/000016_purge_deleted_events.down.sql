@@ -0,0 +1,2 @@
+-- removed events can not be restored
+DO 0;
@@ -0,0 +1,2 @@
+-- events deleted before are removed for good, so their ids can be used again
+DELETE FROM calendar.events WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE calendar.changes MODIFY seq BIGINT NOT NULL AUTO_INCREMENT;
+DROP TABLE calendar.change_seqs;
@@ -0,0 +1,9 @@
+-- seq of changes is taken from counter row locked until commit, so changes are committed in order of seq
+CREATE TABLE calendar.change_seqs (
+                                     id TINYINT NOT NULL,
+                                     seq BIGINT NOT NULL,
+                                     PRIMARY KEY (id)
+)
+    ENGINE = INNODB;
+INSERT INTO calendar.change_seqs (id, seq) SELECT 1, COALESCE(MAX(seq), 0) FROM calendar.changes;
+ALTER TABLE calendar.changes MODIFY seq BIGINT NOT NULL;
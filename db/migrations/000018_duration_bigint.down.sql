@@ -0,0 +1,2 @@
+ALTER TABLE calendar.overrides MODIFY duration INT DEFAULT NULL;
+ALTER TABLE calendar.events MODIFY duration INT DEFAULT NULL;
@@ -0,0 +1,3 @@
+-- duration is kept in nanoseconds, which don't fit in INT
+ALTER TABLE calendar.events MODIFY duration BIGINT DEFAULT NULL;
+ALTER TABLE calendar.overrides MODIFY duration BIGINT DEFAULT NULL;
@@ -0,0 +1,146 @@
+package event
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Role of attendee, values are ROLE of RFC 5545 in lower case
+type Role string
+
+const (
+	RoleChair    Role = "chair"
+	RoleRequired Role = "req-participant"
+	RoleOptional Role = "opt-participant"
+	RoleNone     Role = "non-participant"
+)
+
+// PartStat is participation status of attendee, values are PARTSTAT of RFC 5545 in lower case
+type PartStat string
+
+const (
+	NeedsAction PartStat = "needs-action"
+	Accepted    PartStat = "accepted"
+	Declined    PartStat = "declined"
+	Tentative   PartStat = "tentative"
+)
+
+var (
+	ErrInvalidRole     = errors.New("wrong attendee role")
+	ErrInvalidPartStat = errors.New("wrong participation status")
+	ErrNotAttendee     = errors.New("user is not invited to event")
+)
+
+// Attendee is invited registered user or external email
+type Attendee struct {
+	ID      uuid.UUID `json:"id" gorm:"primaryKey"`
+	EventId uuid.UUID `json:"-" gorm:"index"`
+	// UserId is Nil for external attendee
+	UserId    uuid.UUID `json:"-" gorm:"index"`
+	Login     string    `json:"login,omitempty" gorm:"size:64"`
+	Email     string    `json:"email,omitempty" gorm:"size:254"`
+	Role      Role      `json:"role" gorm:"size:20"`
+	Status    PartStat  `json:"status" gorm:"size:20"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ParseRole check role, empty role is required participant
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(s)); r {
+	case "":
+		return RoleRequired, nil
+	case RoleChair, RoleRequired, RoleOptional, RoleNone:
+		return r, nil
+	}
+	return "", ErrInvalidRole
+}
+
+// ParsePartStat check participation status
+func ParsePartStat(s string) (PartStat, error) {
+	switch ps := PartStat(strings.ToLower(s)); ps {
+	case NeedsAction, Accepted, Declined, Tentative:
+		return ps, nil
+	}
+	return "", ErrInvalidPartStat
+}
+
+// same check if attendees are the same user or the same external email
+func (a *Attendee) same(other Attendee) bool {
+	if a.UserId != uuid.Nil || other.UserId != uuid.Nil {
+		return a.UserId == other.UserId
+	}
+	return strings.EqualFold(a.Email, other.Email)
+}
+
+// Invite add attendee with needs-action status, role of already invited attendee is updated
+func (ev *Event) Invite(a Attendee) Attendee {
+	for i := range ev.Attendees {
+		if ev.Attendees[i].same(a) {
+			ev.Attendees[i].Role = a.Role
+			ev.Attendees[i].UpdatedAt = time.Now().UTC()
+			return ev.Attendees[i]
+		}
+	}
+	a.ID = uuid.New()
+	a.EventId = ev.ID
+	a.Status = NeedsAction
+	a.UpdatedAt = time.Now().UTC()
+	ev.Attendees = append(ev.Attendees, a)
+	return a
+}
+
+// Uninvite remove attendee by id
+func (ev *Event) Uninvite(id uuid.UUID) bool {
+	for i, a := range ev.Attendees {
+		if a.ID == id {
+			ev.Attendees = append(ev.Attendees[:i], ev.Attendees[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Attendee return attendee which is registered user
+func (ev *Event) Attendee(userId uuid.UUID) *Attendee {
+	if userId == uuid.Nil {
+		return nil
+	}
+	for i := range ev.Attendees {
+		if ev.Attendees[i].UserId == userId {
+			return &ev.Attendees[i]
+		}
+	}
+	return nil
+}
+
+// Respond set participation status of invited user
+func (ev *Event) Respond(userId uuid.UUID, status PartStat) (Attendee, error) {
+	a := ev.Attendee(userId)
+	if a == nil {
+		return Attendee{}, ErrNotAttendee
+	}
+	a.Status = status
+	a.UpdatedAt = time.Now().UTC()
+	return *a, nil
+}
+
+// AttachRsvp set Rsvp to status of invited user, so user sees own answer in events list.
+// Nothing is attached for organizer
+func (ev *Event) AttachRsvp(userId uuid.UUID) {
+	ev.Rsvp = ""
+	if a := ev.Attendee(userId); a != nil && ev.UserId != userId {
+		ev.Rsvp = a.Status
+	}
+}
+
+// CopyAttendees invite attendees of other event with their statuses, used when series is split
+func (ev *Event) CopyAttendees(other *Event) {
+	ev.Attendees = make([]Attendee, 0, len(other.Attendees))
+	for _, a := range other.Attendees {
+		a.ID = uuid.New()
+		a.EventId = ev.ID
+		ev.Attendees = append(ev.Attendees, a)
+	}
+}
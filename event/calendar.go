@@ -0,0 +1,188 @@
+package event
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"time"
+)
+
+const (
+	// DefaultCalendarName is name of calendar which every user has, its id is id of user
+	DefaultCalendarName = "Calendar"
+	maxCalendarName     = 100
+)
+
+var (
+	ErrInvalidCalendar = errors.New("calendar must have name up to 100 characters, color like #1e90ff and valid timezone")
+	colorPattern       = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Calendar is named list of events of user, like work, personal or on-call.
+// Timezone and Reminders are used for new events which don't set them
+type Calendar struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	UserId    uuid.UUID `gorm:"index"`
+	Name      string    `gorm:"size:100"`
+	Color     string    `gorm:"size:7"`
+	Timezone  string    `gorm:"size:64"`
+	Reminders Reminders `gorm:"type:text"`
+	CreatedAt time.Time
+	// Access is access of user who requested calendar
+	Access Access `gorm:"-"`
+}
+
+type calendarHelper struct {
+	ID        uuid.UUID       `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Color     string          `json:"color,omitempty"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Reminders json.RawMessage `json:"reminders,omitempty"`
+	Default   bool            `json:"default"`
+	Access    Access          `json:"access,omitempty"`
+}
+
+// defaultReminder is reminder of calendar in JSON, it has no id
+type defaultReminder struct {
+	Before string `json:"before"`
+	At     string `json:"at,omitempty"`
+}
+
+// DefaultCalendar return calendar which user has without creating it, events without calendar belong to it
+func DefaultCalendar(userId uuid.UUID, timezone string) Calendar {
+	return Calendar{ID: userId, UserId: userId, Name: DefaultCalendarName, Timezone: timezone, Reminders: Reminders{},
+		CreatedAt: time.Now().UTC()}
+}
+
+// IsDefault check if calendar is default calendar of its user
+func (c *Calendar) IsDefault() bool {
+	return c.ID == c.UserId
+}
+
+// MarshalJSON convert calendar to JSON
+func (c Calendar) MarshalJSON() ([]byte, error) {
+	reminders, err := c.Reminders.marshal()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(calendarHelper{c.ID, c.Name, c.Color, c.Timezone, reminders, c.IsDefault(), c.Access})
+}
+
+// UnmarshalJSON convert JSON to calendar and check it, id and owner are set by server
+func (c *Calendar) UnmarshalJSON(j []byte) error {
+	var ch calendarHelper
+	if err := json.Unmarshal(j, &ch); err != nil {
+		return err
+	}
+	if ch.Name == "" || len(ch.Name) > maxCalendarName || (ch.Color != "" && !colorPattern.MatchString(ch.Color)) {
+		return ErrInvalidCalendar
+	}
+	if ch.Timezone != "" {
+		loc, err := time.LoadLocation(ch.Timezone)
+		if err != nil {
+			return ErrInvalidCalendar
+		}
+		ch.Timezone = loc.String()
+	}
+	c.Reminders = Reminders{}
+	if len(ch.Reminders) > 0 {
+		if err := c.Reminders.unmarshal(ch.Reminders); err != nil {
+			return err
+		}
+	}
+	c.Name = ch.Name
+	c.Color = ch.Color
+	c.Timezone = ch.Timezone
+	return nil
+}
+
+// Reminders are default reminders of calendar, they are stored as JSON
+type Reminders []Reminder
+
+// Value convert reminders to JSON for db
+func (rs Reminders) Value() (driver.Value, error) {
+	b, err := rs.marshal()
+	return string(b), err
+}
+
+// Scan read reminders from JSON in db
+func (rs *Reminders) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*rs = Reminders{}
+		return nil
+	case string:
+		return rs.unmarshal([]byte(v))
+	case []byte:
+		return rs.unmarshal(v)
+	default:
+		return fmt.Errorf("can't scan %T into reminders", src)
+	}
+}
+
+func (rs Reminders) marshal() ([]byte, error) {
+	list := make([]defaultReminder, 0, len(rs))
+	for _, r := range rs {
+		list = append(list, defaultReminder{r.Before.String(), r.At})
+	}
+	return json.Marshal(list)
+}
+
+// unmarshal check reminders like reminders of event, default reminders get their ids when they are copied to event
+func (rs *Reminders) unmarshal(j []byte) error {
+	var reminders []Reminder
+	if err := json.Unmarshal(j, &reminders); err != nil {
+		return err
+	}
+	if len(reminders) > MaxReminders {
+		return ErrTooManyReminders
+	}
+	*rs = make(Reminders, 0, len(reminders))
+	for _, r := range reminders {
+		*rs = append(*rs, Reminder{Before: r.Before, At: r.At})
+	}
+	return nil
+}
+
+// ApplyCalendar put new event to calendar, timezone and reminders of calendar are used
+// when JSON of event doesn't have them
+func (ev *Event) ApplyCalendar(c *Calendar) error {
+	ev.CalendarId = c.ID
+	if ev.noTimezone && c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return err
+		}
+		// time of event is local time of calendar
+		d := ev.DateTime
+		ev.DateTime = time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), 0, loc)
+		ev.Timezone = loc.String()
+		if ev.RRule != "" {
+			rule, err := ParseRRule(ev.RRule, loc)
+			if err != nil {
+				return err
+			}
+			ev.RRule = rule.String()
+		}
+		ev.noTimezone = false
+	}
+	if ev.noReminders {
+		ev.Reminders = make([]Reminder, 0, len(c.Reminders))
+		for _, r := range c.Reminders {
+			ev.Reminders = append(ev.Reminders, Reminder{ID: uuid.New(), EventId: ev.ID, Before: r.Before, At: r.At})
+		}
+		ev.noReminders = false
+	}
+	return nil
+}
+
+// CalendarOf return id of calendar of event, events saved before calendars belong to default calendar of owner
+func (ev *Event) CalendarOf() uuid.UUID {
+	if ev.CalendarId == uuid.Nil {
+		return ev.UserId
+	}
+	return ev.CalendarId
+}
@@ -0,0 +1,95 @@
+package event
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ChangeType is kind of change of event as user sees it
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	// ChangeDeleted is also written for attendee who is removed from event
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change is record of change log. There is one record for every user who sees the event:
+// organizer, invited registered users and users the calendar of event is shared with.
+// Seq grows with every record and records are committed in order of Seq
+type Change struct {
+	Seq     int64      `json:"seq" gorm:"primaryKey;autoIncrement:false"`
+	UserId  uuid.UUID  `json:"-" gorm:"index"`
+	EventId uuid.UUID  `json:"eventId"`
+	Type    ChangeType `json:"type" gorm:"size:10"`
+	At      time.Time  `json:"at"`
+}
+
+// SaveChanges return changes of saved event, old is nil when event is new. Grantees are users
+// the calendar of event is shared with, oldGrantees are users of calendar of old event
+func SaveChanges(old *Event, oldGrantees []uuid.UUID, ev *Event, grantees []uuid.UUID, now time.Time) []Change {
+	saw := map[uuid.UUID]bool{}
+	if old != nil {
+		for _, id := range old.viewers(oldGrantees) {
+			saw[id] = true
+		}
+	}
+	changes := make([]Change, 0, len(saw)+1)
+	for _, id := range ev.viewers(grantees) {
+		typ := ChangeCreated
+		if saw[id] {
+			typ = ChangeUpdated
+		}
+		delete(saw, id)
+		changes = append(changes, Change{UserId: id, EventId: ev.ID, Type: typ, At: now})
+	}
+	// the rest are removed attendees and users who lost share of calendar
+	for id := range saw {
+		changes = append(changes, Change{UserId: id, EventId: ev.ID, Type: ChangeDeleted, At: now})
+	}
+	return changes
+}
+
+// DeleteChanges return changes of deleted event
+func DeleteChanges(ev *Event, grantees []uuid.UUID, now time.Time) []Change {
+	viewers := ev.viewers(grantees)
+	changes := make([]Change, 0, len(viewers))
+	for _, id := range viewers {
+		changes = append(changes, Change{UserId: id, EventId: ev.ID, Type: ChangeDeleted, At: now})
+	}
+	return changes
+}
+
+// ShareChanges return changes of events of calendar for user the calendar is shared with, unshared from
+// or whose access is changed. Events the user sees anyway as organizer or attendee are skipped
+func ShareChanges(evs []Event, userId uuid.UUID, typ ChangeType, now time.Time) []Change {
+	changes := make([]Change, 0, len(evs))
+	for k := range evs {
+		if evs[k].UserId == userId || evs[k].Attendee(userId) != nil {
+			continue
+		}
+		changes = append(changes, Change{UserId: userId, EventId: evs[k].ID, Type: typ, At: now})
+	}
+	return changes
+}
+
+// viewers return organizer, registered attendees of event and users its calendar is shared with
+func (ev *Event) viewers(grantees []uuid.UUID) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(ev.Attendees)+len(grantees)+1)
+	seen := map[uuid.UUID]bool{uuid.Nil: true}
+	add := func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	add(ev.UserId)
+	for _, a := range ev.Attendees {
+		add(a.UserId)
+	}
+	for _, id := range grantees {
+		add(id)
+	}
+	return ids
+}
@@ -0,0 +1,84 @@
+package event
+
+import (
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// ConflictHorizon limits search of conflicts for recurring events without end
+const ConflictHorizon = 365 * 24 * time.Hour
+
+// maxConflicts is number of reported conflicts, the rest are not interesting for user
+const maxConflicts = 100
+
+// Conflict is occurrence of other event which overlaps saved event
+type Conflict struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	RecurrenceId string    `json:"recurrenceId,omitempty"`
+}
+
+// BusyOnly return conflict which shows only its time, for events which user can't read
+func (c Conflict) BusyOnly() Conflict {
+	return Conflict{Title: BusyTitle, Start: c.Start, End: c.End}
+}
+
+// ConflictWindow return time range where occurrences of event can be
+func (ev *Event) ConflictWindow() (from, to time.Time) {
+	from = ev.DateTime
+	to = ev.DateTime.Add(ev.Duration)
+	if ev.IsRecurring() {
+		to = ev.DateTime.Add(ConflictHorizon)
+	}
+	return from, to
+}
+
+// FindConflicts return occurrences of other events which overlap occurrences of event in [from, to).
+// Other events are expanded occurrences, the event itself, declined invitations and events without duration are skipped
+func (ev *Event) FindConflicts(others []Event, from, to time.Time) ([]Conflict, error) {
+	if ev.Duration <= 0 {
+		return nil, nil
+	}
+	occurrences, err := ev.Occurrences(from, to)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]Event, 0, len(others))
+	for _, o := range others {
+		if o.ID != ev.ID && o.Rsvp != Declined && o.Duration > 0 {
+			candidates = append(candidates, o)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].DateTime.Before(candidates[j].DateTime) })
+
+	conflicts := make([]Conflict, 0)
+	seen := make(map[Conflict]bool)
+	for _, occ := range occurrences {
+		start, end := occ.DateTime, occ.DateTime.Add(occ.Duration)
+		for _, o := range candidates {
+			if !o.DateTime.Before(end) {
+				break
+			}
+			oEnd := o.DateTime.Add(o.Duration)
+			if !oEnd.After(start) {
+				continue
+			}
+			c := Conflict{ID: o.ID, Title: o.Title, Start: o.DateTime, End: oEnd}
+			if !o.RecurrenceId.IsZero() {
+				c.RecurrenceId = o.RecurrenceId.UTC().Format(untilForm) + "Z"
+			}
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			conflicts = append(conflicts, c)
+			if len(conflicts) == maxConflicts {
+				return conflicts, nil
+			}
+		}
+	}
+	return conflicts, nil
+}
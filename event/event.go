@@ -16,19 +16,47 @@ type Event struct {
 	Description string        `json:"description"`
 	DateTime    time.Time     `json:"time" gorm:"column:time"`
 	Timezone    string        `json:"timezone,omitempty"`
-	Duration    time.Duration `json:"duration" gorm:"type:string"`
+	Duration    time.Duration `json:"duration" gorm:"type:bigint"`
 	Notes       string        `json:"notes,omitempty" gorm:"type:string"`
-	UserId      uuid.UUID     `json:"-"`
-	Unmarshaler `json:"-" gorm:"-"`
+	RRule       string        `json:"rrule,omitempty" gorm:"column:rrule"`
+	Overrides   []Override    `json:"-" gorm:"foreignKey:EventId"`
+	Attendees   []Attendee    `json:"-" gorm:"foreignKey:EventId"`
+	Reminders   []Reminder    `json:"-" gorm:"foreignKey:EventId"`
+	// RecurrenceId is original start of occurrence expanded from recurring event
+	RecurrenceId time.Time `json:"recurrenceId,omitempty" gorm:"-"`
+	// Rsvp is participation status of invited user who requested the event
+	Rsvp PartStat `json:"rsvp,omitempty" gorm:"-"`
+	// Conflicts are overlapping events found when event is saved
+	Conflicts []Conflict `json:"conflicts,omitempty" gorm:"-"`
+	UserId    uuid.UUID  `json:"-"`
+	// CalendarId is calendar of owner, it is id of owner for default calendar
+	CalendarId uuid.UUID `json:"calendarId" gorm:"index"`
+	// Version is incremented by store on every save, change of older version is rejected
+	Version int64 `json:"version" gorm:"not null;default:1"`
+	// ResourceName is name of event resource given by CalDAV client, like "abc.ics", the client finds event by it
+	ResourceName string `json:"-" gorm:"size:255"`
+	Unmarshaler  `json:"-" gorm:"-"`
+	// noTimezone and noReminders are set when JSON of event doesn't have them, then calendar defaults are used
+	noTimezone  bool
+	noReminders bool
 }
 type Helper struct {
-	ID          uuid.UUID `json:"id,omitempty"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	DateTime    string    `json:"time"`
-	Timezone    string    `json:"timezone"`
-	Duration    string    `json:"duration"`
-	Notes       string    `json:"notes"`
+	ID           uuid.UUID  `json:"id,omitempty"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	DateTime     string     `json:"time"`
+	Timezone     string     `json:"timezone"`
+	Duration     string     `json:"duration"`
+	Notes        string     `json:"notes"`
+	RRule        string     `json:"rrule,omitempty"`
+	RecurrenceId string     `json:"recurrenceId,omitempty"`
+	Attendees    []Attendee `json:"attendees,omitempty"`
+	Rsvp         PartStat   `json:"rsvp,omitempty"`
+	Conflicts    []Conflict `json:"conflicts,omitempty"`
+	Reminders    []Reminder `json:"reminders,omitempty"`
+	CalendarId   uuid.UUID  `json:"calendarId"`
+	// Version is only returned, expected version of change is sent in If-Match header
+	Version int64 `json:"version,omitempty"`
 }
 
 type Unmarshaler interface {
@@ -37,7 +65,10 @@ type Unmarshaler interface {
 
 // MarshalJSON convert event to JSON
 func (ev *Event) MarshalJSON() ([]byte, error) {
-	eh := Helper{ev.ID, ev.Title, ev.Description, ev.DateTime.Format(longForm), ev.Timezone, ev.Duration.String(), ev.Notes}
+	eh := Helper{ev.ID, ev.Title, ev.Description, ev.DateTime.Format(longForm), ev.Timezone, ev.Duration.String(), ev.Notes, ev.RRule, "", ev.Attendees, ev.Rsvp, ev.Conflicts, ev.Reminders, ev.CalendarId, ev.Version}
+	if !ev.RecurrenceId.IsZero() {
+		eh.RecurrenceId = ev.RecurrenceId.UTC().Format(untilForm) + "Z"
+	}
 	return json.Marshal(eh)
 }
 
@@ -54,6 +85,9 @@ func (ev *Event) UnmarshalJSON(j []byte) error {
 		ev.ID = uuid.New()
 	}
 
+	ev.CalendarId = eh.CalendarId
+	ev.noTimezone = eh.Timezone == ""
+	ev.noReminders = eh.Reminders == nil
 	ev.Title = eh.Title
 	ev.Description = eh.Description
 	ev.Notes = eh.Notes
@@ -70,6 +104,20 @@ func (ev *Event) UnmarshalJSON(j []byte) error {
 	if err != nil {
 		return err
 	}
+	if err = ev.setReminders(eh.Reminders); err != nil {
+		return err
+	}
+	ev.RRule = ""
+	if eh.RRule != "" {
+		rule, err := ParseRRule(eh.RRule, loc)
+		if err != nil {
+			return err
+		}
+		if !rule.Repeats(ev.DateTime) {
+			return ErrNeverRepeats
+		}
+		ev.RRule = rule.String()
+	}
 	return nil
 }
 
@@ -87,3 +135,21 @@ func (ev *Event) ChangeTimezone(loc *time.Location) {
 	ev.Timezone = loc.String()
 	ev.DateTime = ev.DateTime.In(loc)
 }
+
+// Equal check if events have the same content, occurrence overrides included
+func (ev *Event) Equal(other *Event) bool {
+	if ev.ID != other.ID || ev.Title != other.Title || ev.Description != other.Description ||
+		!ev.DateTime.Equal(other.DateTime) || ev.Timezone != other.Timezone ||
+		ev.Duration != other.Duration || ev.Notes != other.Notes || ev.RRule != other.RRule ||
+		len(ev.Overrides) != len(other.Overrides) {
+		return false
+	}
+	for _, o := range ev.Overrides {
+		po := other.override(o.RecurrenceId)
+		if po == nil || po.Cancelled != o.Cancelled || po.Title != o.Title || po.Description != o.Description ||
+			!po.DateTime.Equal(o.DateTime) || po.Timezone != o.Timezone || po.Duration != o.Duration || po.Notes != o.Notes {
+			return false
+		}
+	}
+	return true
+}
@@ -1 +1,501 @@
 package event
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{"weekly by days", "FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE", false},
+		{"prefix and lower case", "RRULE:freq=daily;interval=2;count=3", "FREQ=DAILY;INTERVAL=2;COUNT=3", false},
+		{"monthly last friday", "FREQ=MONTHLY;BYDAY=-1FR", "FREQ=MONTHLY;BYDAY=-1FR", false},
+		{"until", "FREQ=DAILY;UNTIL=20210901T100000Z", "FREQ=DAILY;UNTIL=20210901T100000Z", false},
+		{"no frequency", "COUNT=3", "", true},
+		{"unsupported part", "FREQ=DAILY;BYHOUR=5", "", true},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20210901", "", true},
+		{"wrong month day", "FREQ=MONTHLY;BYMONTHDAY=32", "", true},
+		{"yearly ordinal weekday", "FREQ=YEARLY;BYDAY=-53FR", "FREQ=YEARLY;BYDAY=-53FR", false},
+		{"monthly ordinal weekday beyond month", "FREQ=MONTHLY;BYDAY=6MO", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRRule(tt.rule, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseRRule() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleBetween(t *testing.T) {
+	riga, _ := time.LoadLocation("Europe/Riga")
+	start := time.Date(2021, time.October, 25, 9, 30, 0, 0, riga) // Monday
+	tests := []struct {
+		name     string
+		rule     string
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			"daily count",
+			"FREQ=DAILY;COUNT=3",
+			time.Time{}, time.Time{},
+			[]time.Time{start, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)},
+		},
+		{
+			"weekly keeps wall clock across dst",
+			"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			time.Time{}, time.Time{},
+			[]time.Time{
+				start,
+				time.Date(2021, time.October, 27, 9, 30, 0, 0, riga),
+				time.Date(2021, time.November, 1, 9, 30, 0, 0, riga),
+				time.Date(2021, time.November, 3, 9, 30, 0, 0, riga),
+			},
+		},
+		{
+			"window",
+			"FREQ=DAILY;INTERVAL=2",
+			time.Date(2021, time.October, 28, 0, 0, 0, 0, riga), time.Date(2021, time.November, 2, 0, 0, 0, 0, riga),
+			[]time.Time{
+				time.Date(2021, time.October, 29, 9, 30, 0, 0, riga),
+				time.Date(2021, time.October, 31, 9, 30, 0, 0, riga),
+			},
+		},
+		{
+			"monthly last friday until",
+			"FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20211231",
+			time.Time{}, time.Time{},
+			[]time.Time{
+				start,
+				time.Date(2021, time.October, 29, 9, 30, 0, 0, riga),
+				time.Date(2021, time.November, 26, 9, 30, 0, 0, riga),
+				time.Date(2021, time.December, 31, 9, 30, 0, 0, riga),
+			},
+		},
+		{
+			"monthly day skips short months",
+			"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			time.Time{}, time.Time{},
+			[]time.Time{
+				start,
+				time.Date(2021, time.October, 31, 9, 30, 0, 0, riga),
+				time.Date(2021, time.December, 31, 9, 30, 0, 0, riga),
+			},
+		},
+		{
+			"yearly by day is every weekday of year",
+			"FREQ=YEARLY;BYDAY=MO;COUNT=3",
+			time.Time{}, time.Time{},
+			[]time.Time{start, time.Date(2021, time.November, 1, 9, 30, 0, 0, riga), time.Date(2021, time.November, 8, 9, 30, 0, 0, riga)},
+		},
+		{
+			"yearly by ordinal day of year",
+			"FREQ=YEARLY;BYDAY=20MO;COUNT=3",
+			time.Time{}, time.Time{},
+			[]time.Time{start, time.Date(2022, time.May, 16, 9, 30, 0, 0, riga), time.Date(2023, time.May, 15, 9, 30, 0, 0, riga)},
+		},
+		{
+			"yearly by month day is every month",
+			"FREQ=YEARLY;BYMONTHDAY=1;COUNT=3",
+			time.Time{}, time.Time{},
+			[]time.Time{start, time.Date(2021, time.November, 1, 9, 30, 0, 0, riga), time.Date(2021, time.December, 1, 9, 30, 0, 0, riga)},
+		},
+		{
+			"rule which never matches stops at end of window",
+			"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=2MO",
+			time.Time{}, start.AddDate(1, 0, 0),
+			[]time.Time{start},
+		},
+		{
+			"endless rule which never matches stops after cycle",
+			"FREQ=DAILY;INTERVAL=7;BYDAY=TU",
+			time.Time{}, time.Time{},
+			[]time.Time{start},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule, riga)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rule.Between(start, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("Between()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRRuleRepeats(t *testing.T) {
+	start := time.Date(2021, time.October, 25, 9, 30, 0, 0, time.UTC) // Monday
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO,WE", true},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=5FR", true},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=2MO", false},
+		{"FREQ=DAILY;INTERVAL=7;BYDAY=TU", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rule.Repeats(start); got != tt.want {
+				t.Errorf("Repeats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	var ev Event
+	err := ev.UnmarshalJSON([]byte(`{"title":"Never","time":"2021-10-25 09:30:00","timezone":"UTC","duration":"1h","rrule":"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=2MO"}`))
+	if !errors.Is(err, ErrNeverRepeats) {
+		t.Errorf("UnmarshalJSON() error = %v, want ErrNeverRepeats", err)
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	ev := Event{
+		Title:    "Standup",
+		DateTime: time.Date(2021, time.August, 2, 10, 0, 0, 0, loc),
+		Timezone: loc.String(),
+		Duration: 15 * time.Minute,
+		RRule:    "FREQ=WEEKLY;COUNT=2",
+	}
+	got, err := ev.Occurrences(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{ev.DateTime, ev.DateTime.AddDate(0, 0, 7)}
+	if len(got) != len(want) {
+		t.Fatalf("Occurrences() returned %d events, want %d", len(got), len(want))
+	}
+	for i, occ := range got {
+		if occ.Title != ev.Title || occ.Duration != ev.Duration {
+			t.Errorf("Occurrences() changed event fields: %v", occ)
+		}
+		if !occ.DateTime.Equal(want[i]) {
+			t.Errorf("Occurrences()[%d] = %v, want %v", i, occ.DateTime, want[i])
+		}
+	}
+}
+
+func TestOccurrenceOverrides(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Riga")
+	newEvent := func() Event {
+		return Event{
+			Title:    "Standup",
+			DateTime: time.Date(2021, time.August, 2, 10, 0, 0, 0, loc),
+			Timezone: loc.String(),
+			Duration: 15 * time.Minute,
+			RRule:    "FREQ=DAILY;COUNT=4",
+		}
+	}
+
+	t.Run("cancel occurrence", func(t *testing.T) {
+		ev := newEvent()
+		rid, err := ev.FindOccurrence("20210803")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev.CancelOccurrence(rid)
+		got, _ := ev.Occurrences(time.Time{}, time.Time{})
+		if len(got) != 3 || got[1].DateTime.Day() != 4 {
+			t.Errorf("Occurrences() after cancel = %v", got)
+		}
+	})
+
+	t.Run("move occurrence into window", func(t *testing.T) {
+		ev := newEvent()
+		rid, _ := ev.FindOccurrence("20210802T070000Z")
+		moved := ev
+		moved.Title = "Moved standup"
+		moved.DateTime = time.Date(2021, time.August, 10, 12, 0, 0, 0, loc)
+		ev.ModifyOccurrence(rid, moved)
+		from := time.Date(2021, time.August, 9, 0, 0, 0, 0, loc)
+		got, _ := ev.Occurrences(from, from.AddDate(0, 0, 7))
+		if len(got) != 1 || got[0].Title != "Moved standup" || !got[0].RecurrenceId.Equal(rid) {
+			t.Errorf("Occurrences() after move = %v", got)
+		}
+	})
+
+	t.Run("split series", func(t *testing.T) {
+		ev := newEvent()
+		rid, _ := ev.FindOccurrence("20210804")
+		rest, err := ev.SplitAt(rid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := ev.Occurrences(time.Time{}, time.Time{})
+		if len(got) != 2 || rest.Count != 2 {
+			t.Errorf("SplitAt() left %d occurrences and %d following, want 2 and 2", len(got), rest.Count)
+		}
+	})
+
+	t.Run("no occurrence", func(t *testing.T) {
+		ev := newEvent()
+		if _, err := ev.FindOccurrence("20210901"); err != ErrNoOccurrence {
+			t.Errorf("FindOccurrence() error = %v, want %v", err, ErrNoOccurrence)
+		}
+	})
+}
+
+func TestAttendees(t *testing.T) {
+	organizer, invitee := uuid.New(), uuid.New()
+	ev := Event{ID: uuid.New(), UserId: organizer}
+	first := ev.Invite(Attendee{UserId: invitee, Login: "User2", Role: RoleOptional})
+	again := ev.Invite(Attendee{UserId: invitee, Login: "User2", Role: RoleRequired})
+	ev.Invite(Attendee{Email: "guest@example.com", Role: RoleRequired})
+	ev.Invite(Attendee{Email: "GUEST@example.com", Role: RoleOptional})
+
+	if len(ev.Attendees) != 2 {
+		t.Fatalf("got %d attendees, want 2 without duplicates", len(ev.Attendees))
+	}
+	if first.ID != again.ID || again.Role != RoleRequired || again.Status != NeedsAction {
+		t.Errorf("second invitation = %+v, want updated role of the same attendee", again)
+	}
+	if _, err := ev.Respond(uuid.New(), Accepted); err != ErrNotAttendee {
+		t.Errorf("got error %v for not invited user, want ErrNotAttendee", err)
+	}
+	if _, err := ev.Respond(invitee, Accepted); err != nil {
+		t.Fatal(err)
+	}
+
+	ev.AttachRsvp(invitee)
+	if ev.Rsvp != Accepted {
+		t.Errorf("got rsvp %q, want accepted", ev.Rsvp)
+	}
+	ev.AttachRsvp(organizer)
+	if ev.Rsvp != "" {
+		t.Errorf("got rsvp %q for organizer, want empty", ev.Rsvp)
+	}
+	if !ev.Uninvite(first.ID) || ev.Attendee(invitee) != nil {
+		t.Error("attendee is not removed")
+	}
+}
+
+func TestBusy(t *testing.T) {
+	day := time.Date(2021, time.August, 2, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	events := []Event{
+		{DateTime: at(9, 0), Duration: time.Hour},
+		{DateTime: at(9, 30), Duration: time.Hour},
+		{DateTime: at(10, 30), Duration: 30 * time.Minute},
+		{DateTime: at(13, 0), Duration: time.Hour, Rsvp: Declined},
+		{DateTime: at(15, 0), Duration: 0},
+		{DateTime: at(17, 30), Duration: time.Hour},
+		{DateTime: at(7, 0), Duration: 30 * time.Minute},
+	}
+	got := Busy(events, at(8, 0), at(18, 0))
+	want := []Interval{
+		{at(9, 0), at(11, 0)},
+		{at(17, 30), at(18, 0)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Busy() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			t.Errorf("interval %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	day := time.Date(2021, time.August, 2, 0, 0, 0, 0, time.UTC)
+	at := func(d, h int) time.Time { return day.AddDate(0, 0, d).Add(time.Duration(h) * time.Hour) }
+	standup := Event{ID: uuid.New(), DateTime: at(0, 9), Timezone: "UTC", Duration: time.Hour, RRule: "FREQ=DAILY;COUNT=5"}
+	review := Event{ID: uuid.New(), Title: "Review", DateTime: at(2, 9).Add(30 * time.Minute), Duration: time.Hour}
+	declined := Event{ID: uuid.New(), DateTime: at(3, 9), Duration: time.Hour, Rsvp: Declined}
+	adjacent := Event{ID: uuid.New(), DateTime: at(1, 10), Duration: time.Hour}
+	itself := standup
+	itself.DateTime = at(4, 9)
+
+	from, to := standup.ConflictWindow()
+	conflicts, err := standup.FindConflicts([]Event{review, declined, adjacent, itself}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].ID != review.ID || conflicts[0].Title != "Review" {
+		t.Errorf("FindConflicts() = %+v, want only review", conflicts)
+	}
+}
+
+func TestReminderFireAt(t *testing.T) {
+	riga, _ := time.LoadLocation("Europe/Riga")
+	start := time.Date(2021, time.August, 3, 14, 0, 0, 0, riga)
+	tests := []struct {
+		name     string
+		reminder Reminder
+		want     time.Time
+	}{
+		{"minutes before", Reminder{Before: 10 * time.Minute}, time.Date(2021, time.August, 3, 13, 50, 0, 0, riga)},
+		{"day before at 09:00", Reminder{Before: 24 * time.Hour, At: "09:00"}, time.Date(2021, time.August, 2, 9, 0, 0, 0, riga)},
+		{"same day at time after start", Reminder{At: "15:00"}, time.Date(2021, time.August, 2, 15, 0, 0, 0, riga)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.reminder.FireAt(start, riga); !got.Equal(tt.want) {
+				t.Errorf("FireAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextTriggerOfRecurringEvent(t *testing.T) {
+	ev := Event{ID: uuid.New(), DateTime: time.Date(2021, time.August, 2, 9, 0, 0, 0, time.UTC), Timezone: "UTC",
+		Duration: time.Hour, RRule: "FREQ=DAILY;COUNT=3"}
+	r := Reminder{ID: uuid.New(), Before: 10 * time.Minute}
+
+	trigger, err := ev.NextTrigger(r, time.Date(2021, time.August, 3, 8, 55, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStart := time.Date(2021, time.August, 4, 9, 0, 0, 0, time.UTC)
+	if !trigger.Start.Equal(wantStart) || !trigger.FireAt.Equal(wantStart.Add(-10*time.Minute)) {
+		t.Errorf("NextTrigger() = %v at %v, want occurrence %v", trigger.Start, trigger.FireAt, wantStart)
+	}
+	if _, err = ev.NextTrigger(r, wantStart); !errors.Is(err, ErrNoTrigger) {
+		t.Errorf("NextTrigger() after last occurrence error = %v, want ErrNoTrigger", err)
+	}
+}
+
+func TestUnmarshalReminders(t *testing.T) {
+	var ev Event
+	err := ev.UnmarshalJSON([]byte(`{"title":"a","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"1h",
+		"reminders":[{"before":"10m"},{"before":"24h","at":"09:00"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Reminders) != 2 || ev.Reminders[1].At != "09:00" || ev.Reminders[0].EventId != ev.ID || ev.Reminders[0].ID == uuid.Nil {
+		t.Errorf("reminders = %+v", ev.Reminders)
+	}
+	err = ev.UnmarshalJSON([]byte(`{"title":"a","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"1h","reminders":[{"before":"-5m"}]}`))
+	if err == nil {
+		t.Error("negative reminder is accepted")
+	}
+}
+
+func TestApplyCalendar(t *testing.T) {
+	var c Calendar
+	err := c.UnmarshalJSON([]byte(`{"name":"Work","color":"#1e90ff","timezone":"Europe/Riga","reminders":[{"before":"15m"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ID = uuid.New()
+
+	var ev Event
+	if err = ev.UnmarshalJSON([]byte(`{"title":"a","time":"2021-08-02 09:00:00","duration":"1h"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err = ev.ApplyCalendar(&c); err != nil {
+		t.Fatal(err)
+	}
+	if ev.CalendarId != c.ID || ev.Timezone != "Europe/Riga" || ev.DateTime.Hour() != 9 || ev.DateTime.UTC().Hour() != 6 {
+		t.Errorf("event in calendar timezone = %v %v", ev.Timezone, ev.DateTime)
+	}
+	if len(ev.Reminders) != 1 || ev.Reminders[0].Before != 15*time.Minute || ev.Reminders[0].ID == uuid.Nil {
+		t.Errorf("default reminders = %+v", ev.Reminders)
+	}
+
+	// values of event are kept
+	ev = Event{}
+	if err = ev.UnmarshalJSON([]byte(`{"title":"a","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"1h","reminders":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err = ev.ApplyCalendar(&c); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Timezone != "UTC" || ev.DateTime.UTC().Hour() != 9 || len(ev.Reminders) != 0 {
+		t.Errorf("event = %v %v %+v", ev.Timezone, ev.DateTime, ev.Reminders)
+	}
+
+	if err = c.UnmarshalJSON([]byte(`{"name":"Work","color":"blue"}`)); !errors.Is(err, ErrInvalidCalendar) {
+		t.Errorf("wrong color: %v", err)
+	}
+}
+
+func TestAccessOf(t *testing.T) {
+	owner, attendee, other := uuid.New(), uuid.New(), uuid.New()
+	ev := Event{ID: uuid.New(), Title: "Review", Description: "secret", UserId: owner,
+		Attendees: []Attendee{{ID: uuid.New(), UserId: attendee}}}
+	tests := []struct {
+		name    string
+		userId  uuid.UUID
+		granted Access
+		want    Access
+	}{
+		{"owner", owner, "", AccessOwner},
+		{"attendee", attendee, "", AccessRead},
+		{"attendee with edit share", attendee, AccessEdit, AccessEdit},
+		{"attendee with free/busy share", attendee, AccessFreeBusy, AccessRead},
+		{"free/busy share", other, AccessFreeBusy, AccessFreeBusy},
+		{"no access", other, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ev.AccessOf(tt.userId, tt.granted); got != tt.want {
+				t.Errorf("AccessOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if Access("").Allows(AccessFreeBusy) || AccessRead.Allows(AccessEdit) || !AccessOwner.Allows(AccessEdit) {
+		t.Error("wrong order of access levels")
+	}
+	if busy := ev.BusyOnly(); busy.Title != BusyTitle || busy.Description != "" || len(busy.Attendees) != 0 {
+		t.Errorf("busy block shows event: %+v", busy)
+	}
+	ev.Overrides = []Override{{EventId: ev.ID, Cancelled: true}, {EventId: ev.ID, Title: "moved", Description: "secret"}}
+	if busy := ev.BusyOnly(); len(busy.Overrides) != 2 || !busy.Overrides[0].Cancelled || busy.Overrides[1].Title != BusyTitle ||
+		busy.Overrides[1].Description != "" {
+		t.Errorf("busy overrides = %+v", busy.Overrides)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	ev := Event{}
+	if err := ev.UnmarshalJSON([]byte(`{"title":"a","description":"d","time":"2021-08-02 09:00:00","timezone":"Europe/Riga","duration":"1h","reminders":[{"before":"15m"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	ev.Attendees = []Attendee{{ID: uuid.New()}}
+	patched, err := ev.MergePatch([]byte(`{"id":"` + uuid.New().String() + `","title":"b","description":null,"time":"2021-08-02 10:00:00"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.ID != ev.ID || patched.Title != "b" || patched.Description != "" || patched.Timezone != "Europe/Riga" ||
+		patched.DateTime.Hour() != 10 || patched.Duration != time.Hour || len(patched.Attendees) != 1 {
+		t.Errorf("patched = %+v", patched)
+	}
+	if len(patched.Reminders) != 1 || patched.Reminders[0].ID != ev.Reminders[0].ID {
+		t.Errorf("reminders = %+v", patched.Reminders)
+	}
+	if ev.Title != "a" {
+		t.Error("event is changed by patch")
+	}
+	if _, err = ev.MergePatch([]byte(`["title"]`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("array patch: %v", err)
+	}
+	if _, err = ev.MergePatch([]byte(`{"duration":"soon"}`)); err == nil {
+		t.Error("wrong duration is patched")
+	}
+}
@@ -0,0 +1,52 @@
+package event
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is busy time of user, it tells nothing about the event
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Busy return sorted and merged intervals of events clipped to [from, to).
+// Events which have no duration and invitations declined by user don't make user busy
+func Busy(events []Event, from, to time.Time) []Interval {
+	intervals := make([]Interval, 0, len(events))
+	for _, ev := range events {
+		if ev.Rsvp == Declined || ev.Duration <= 0 {
+			continue
+		}
+		start, end := ev.DateTime, ev.DateTime.Add(ev.Duration)
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		intervals = append(intervals, Interval{start, end})
+	}
+	return MergeIntervals(intervals)
+}
+
+// MergeIntervals join overlapping and adjacent intervals
+func MergeIntervals(intervals []Interval) []Interval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	merged := make([]Interval, 0, len(intervals))
+	for _, in := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !in.Start.After(merged[last].End) {
+			if in.End.After(merged[last].End) {
+				merged[last].End = in.End
+			}
+			continue
+		}
+		merged = append(merged, in)
+	}
+	return merged
+}
@@ -0,0 +1,186 @@
+package event
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoOccurrence is returned when recurring event has no occurrence at requested date
+var ErrNoOccurrence = errors.New("event has no occurrence at this date")
+
+// ErrNotRecurring is returned for occurrence operations on single event
+var ErrNotRecurring = errors.New("event is not recurring")
+
+// Override replaces or cancels one occurrence of recurring event (RECURRENCE-ID and EXDATE in RFC 5545)
+type Override struct {
+	EventId      uuid.UUID `gorm:"primaryKey;"`
+	RecurrenceId time.Time `gorm:"primaryKey;"` // original start of occurrence
+	Cancelled    bool
+	Title        string
+	Description  string
+	DateTime     time.Time `gorm:"column:time"`
+	Timezone     string
+	Duration     time.Duration `gorm:"type:bigint"`
+	Notes        string        `gorm:"type:string"`
+}
+
+// apply override to occurrence
+func (o *Override) apply(occ *Event) {
+	occ.Title = o.Title
+	occ.Description = o.Description
+	occ.DateTime = o.DateTime
+	occ.Timezone = o.Timezone
+	occ.Duration = o.Duration
+	occ.Notes = o.Notes
+}
+
+// override return override of occurrence started at rid
+func (ev *Event) override(rid time.Time) *Override {
+	for i := range ev.Overrides {
+		if ev.Overrides[i].RecurrenceId.Equal(rid) {
+			return &ev.Overrides[i]
+		}
+	}
+	return nil
+}
+
+// setOverride add override or replace existing one for the same occurrence
+func (ev *Event) setOverride(o Override) {
+	overrides := make([]Override, 0, len(ev.Overrides)+1)
+	for _, old := range ev.Overrides {
+		if !old.RecurrenceId.Equal(o.RecurrenceId) {
+			overrides = append(overrides, old)
+		}
+	}
+	ev.Overrides = append(overrides, o)
+}
+
+// rule parse recurrence rule of event in its own timezone
+func (ev *Event) rule() (*RRule, *time.Location, error) {
+	if !ev.IsRecurring() {
+		return nil, nil, ErrNotRecurring
+	}
+	loc, err := time.LoadLocation(ev.Timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+	rule, err := ParseRRule(ev.RRule, loc)
+	return rule, loc, err
+}
+
+// FindOccurrence return original start of occurrence by date "20060102" or time "20060102T150405"
+// in event timezone, time can be in UTC with "Z" suffix
+func (ev *Event) FindOccurrence(date string) (time.Time, error) {
+	rule, loc, err := ev.rule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	date = strings.ToUpper(date)
+	start := ev.DateTime.In(loc)
+	var from, to time.Time
+	if len(date) == len(dateForm) {
+		from, err = time.ParseInLocation(dateForm, date, loc)
+		to = from.AddDate(0, 0, 1)
+	} else if strings.HasSuffix(date, "Z") {
+		from, err = time.Parse(untilForm, strings.TrimSuffix(date, "Z"))
+		to = from.Add(time.Second)
+	} else {
+		from, err = time.ParseInLocation(untilForm, date, loc)
+		to = from.Add(time.Second)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if starts := rule.Between(start, from, to); len(starts) > 0 {
+		return starts[0], nil
+	}
+	return time.Time{}, ErrNoOccurrence
+}
+
+// Occurrence return occurrence started at rid with override applied
+func (ev *Event) Occurrence(rid time.Time) Event {
+	occ := *ev
+	occ.Overrides = nil
+	occ.DateTime = rid
+	occ.RecurrenceId = rid
+	if o := ev.override(rid); o != nil && !o.Cancelled {
+		o.apply(&occ)
+	}
+	return occ
+}
+
+// CancelOccurrence exclude occurrence started at rid from event
+func (ev *Event) CancelOccurrence(rid time.Time) {
+	ev.setOverride(Override{EventId: ev.ID, RecurrenceId: rid, Cancelled: true})
+}
+
+// ModifyOccurrence replace occurrence started at rid by fields of changed event
+func (ev *Event) ModifyOccurrence(rid time.Time, changed Event) {
+	ev.setOverride(Override{
+		EventId:      ev.ID,
+		RecurrenceId: rid,
+		Title:        changed.Title,
+		Description:  changed.Description,
+		DateTime:     changed.DateTime,
+		Timezone:     changed.Timezone,
+		Duration:     changed.Duration,
+		Notes:        changed.Notes,
+	})
+}
+
+// SplitAt finish event series before occurrence started at rid and return rule for the rest of series.
+// Overrides of the following occurrences are removed from event.
+func (ev *Event) SplitAt(rid time.Time) (*RRule, error) {
+	rule, loc, err := ev.rule()
+	if err != nil {
+		return nil, err
+	}
+	following := *rule
+	if rule.Count > 0 {
+		following.Count = rule.Count - len(rule.Between(ev.DateTime.In(loc), time.Time{}, rid))
+		rule.Count = 0
+	}
+	rule.Until = rid.Add(-time.Second)
+	ev.RRule = rule.String()
+
+	overrides := make([]Override, 0, len(ev.Overrides))
+	for _, o := range ev.Overrides {
+		if o.RecurrenceId.Before(rid) {
+			overrides = append(overrides, o)
+		}
+	}
+	ev.Overrides = overrides
+	return &following, nil
+}
+
+// mergeOverrides apply overrides to occurrences started at starts and add occurrences
+// which were moved into [from, to) from outside of it
+func (ev *Event) mergeOverrides(rule *RRule, start time.Time, starts []time.Time, from, to time.Time) []Event {
+	events := make([]Event, 0, len(starts))
+	seen := make(map[time.Time]bool, len(starts))
+	for _, t := range starts {
+		seen[t.UTC()] = true
+		if o := ev.override(t); o != nil && o.Cancelled {
+			continue
+		}
+		events = append(events, ev.Occurrence(t))
+	}
+
+	for _, o := range ev.Overrides {
+		if o.Cancelled || seen[o.RecurrenceId.UTC()] {
+			continue
+		}
+		if (!from.IsZero() && o.DateTime.Before(from)) || (!to.IsZero() && !o.DateTime.Before(to)) {
+			continue
+		}
+		if len(rule.Between(start, o.RecurrenceId, o.RecurrenceId.Add(time.Second))) == 0 {
+			continue
+		}
+		events = append(events, ev.Occurrence(o.RecurrenceId))
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].DateTime.Before(events[j].DateTime) })
+	return events
+}
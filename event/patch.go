@@ -0,0 +1,61 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidPatch = errors.New("patch must be JSON object")
+
+// MergePatch return event changed by JSON Merge Patch (RFC 7396): fields of patch replace fields of JSON of event
+// and null removes field. Id can't be changed, overrides, attendees and owner are not in JSON and are kept
+func (ev *Event) MergePatch(patch []byte) (Event, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return Event{}, err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return Event{}, ErrInvalidPatch
+	}
+	doc, err := json.Marshal(ev)
+	if err != nil {
+		return Event{}, err
+	}
+	var target interface{}
+	if err = json.Unmarshal(doc, &target); err != nil {
+		return Event{}, err
+	}
+	merged, err := json.Marshal(mergePatch(target, p))
+	if err != nil {
+		return Event{}, err
+	}
+	patched := *ev
+	if err = patched.UnmarshalJSON(merged); err != nil {
+		return Event{}, err
+	}
+	patched.ID = ev.ID
+	for i := range patched.Reminders {
+		patched.Reminders[i].EventId = ev.ID
+	}
+	return patched, nil
+}
+
+// mergePatch apply patch to target as MergePatch algorithm of RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
@@ -0,0 +1,419 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxOccurrences limits expansion of endless rules when window has no end
+	maxOccurrences = 1000
+	untilForm      = "20060102T150405"
+	dateForm       = "20060102"
+)
+
+// ErrNeverRepeats is returned for rule which has no occurrence after the first one, like "FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=2MO"
+var ErrNeverRepeats = errors.New("recurrence rule never repeats the first occurrence")
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is BYDAY value, N is ordinal in month ("2MO", "-1FR") or 0 for every weekday
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// RRule is recurrence rule from RFC 5545 limited to FREQ, INTERVAL, BYDAY, BYMONTHDAY, COUNT and UNTIL
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+}
+
+// ParseRRule parse rule like "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", floating UNTIL is read in loc
+func ParseRRule(str string, loc *time.Location) (*RRule, error) {
+	rule := &RRule{Interval: 1}
+	str = strings.TrimPrefix(strings.TrimSpace(str), "RRULE:")
+	for _, part := range strings.Split(str, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("wrong rrule part %q", part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly && rule.Freq != Yearly {
+				return nil, fmt.Errorf("unsupported rrule frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 {
+				return nil, fmt.Errorf("wrong rrule interval %q", value)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("wrong rrule count %q", value)
+			}
+		case "UNTIL":
+			rule.Until, err = parseUntil(value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("wrong rrule until %q", value)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(d)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				md, err := strconv.Atoi(d)
+				if err != nil || md == 0 || md < -31 || md > 31 {
+					return nil, fmt.Errorf("wrong rrule month day %q", d)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, md)
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, fmt.Errorf("unsupported rrule week start %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", name)
+		}
+	}
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("rrule frequency is required")
+	}
+	for _, wd := range rule.ByDay {
+		if rule.Freq != Yearly && (wd.N < -5 || wd.N > 5) {
+			return nil, fmt.Errorf("wrong rrule weekday %q, only yearly rule has ordinal beyond 5", wd.String())
+		}
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("rrule can't have both count and until")
+	}
+	return rule, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(untilForm, strings.TrimSuffix(value, "Z"))
+	}
+	if len(value) == len(dateForm) {
+		// date only until includes the whole day
+		t, err := time.ParseInLocation(dateForm, value, loc)
+		return t.Add(h24 - time.Nanosecond), err
+	}
+	return time.ParseInLocation(untilForm, value, loc)
+}
+
+func parseWeekdayNum(str string) (WeekdayNum, error) {
+	if len(str) < 2 {
+		return WeekdayNum{}, fmt.Errorf("wrong rrule weekday %q", str)
+	}
+	day, ok := weekdays[str[len(str)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("wrong rrule weekday %q", str)
+	}
+	wd := WeekdayNum{Day: day}
+	if n := str[:len(str)-2]; n != "" {
+		var err error
+		wd.N, err = strconv.Atoi(n)
+		if err != nil || wd.N == 0 || wd.N < -53 || wd.N > 53 {
+			return WeekdayNum{}, fmt.Errorf("wrong rrule weekday %q", str)
+		}
+	}
+	return wd, nil
+}
+
+// String return rule in RFC 5545 form without "RRULE:" prefix
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, md := range r.ByMonthDay {
+			days[i] = strconv.Itoa(md)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilForm)+"Z")
+	}
+	return strings.Join(parts, ";")
+}
+
+func (wd WeekdayNum) String() string {
+	name := strings.ToUpper(wd.Day.String()[:2])
+	if wd.N != 0 {
+		return strconv.Itoa(wd.N) + name
+	}
+	return name
+}
+
+// Between return start times of occurrences in [from, to), start is the first occurrence.
+// Dates are generated by wall clock of start location, so time of day is kept across DST changes.
+// Zero from or to means that window is not limited from this side.
+func (r *RRule) Between(start, from, to time.Time) []time.Time {
+	var result []time.Time
+	n := 0
+	add := func(t time.Time) bool {
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		n++
+		if r.Count > 0 && n > r.Count {
+			return false
+		}
+		if !to.IsZero() && !t.Before(to) {
+			return false
+		}
+		if from.IsZero() || !t.Before(from) {
+			result = append(result, t)
+		}
+		return len(result) < maxOccurrences
+	}
+
+	if !add(start) {
+		return result
+	}
+	// rule which has no date in the whole cycle never has one
+	for p, idle := 0, 0; idle < r.cycle(); p++ {
+		// periods are in order, so when one begins after window or until the rest do too
+		first := r.periodStart(start, p)
+		if (!to.IsZero() && !first.Before(to)) || (!r.Until.IsZero() && first.After(r.Until)) {
+			return result
+		}
+		idle++
+		for _, t := range r.period(start, p) {
+			if !t.After(start) {
+				continue
+			}
+			idle = 0
+			if !add(t) {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// Repeats check if rule has an occurrence after the first one at start, COUNT and UNTIL are not checked
+func (r *RRule) Repeats(start time.Time) bool {
+	for p := 0; p < r.cycle(); p++ {
+		for _, t := range r.period(start, p) {
+			if t.After(start) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cycle return number of periods in 400 years, after them days of week and lengths of months repeat
+func (r *RRule) cycle() int {
+	switch r.Freq {
+	case Daily:
+		return 146097
+	case Weekly:
+		return 20871
+	case Monthly:
+		return 4800
+	}
+	return 400
+}
+
+// periodStart return the earliest time candidates of p-th period can have
+func (r *RRule) periodStart(start time.Time, p int) time.Time {
+	y, m, d := start.Date()
+	switch r.Freq {
+	case Daily:
+		return date(y, m, d+p*r.Interval, start)
+	case Weekly:
+		return date(y, m, d-(int(start.Weekday())+6)%7+7*p*r.Interval, start)
+	case Monthly:
+		return date(y, m+time.Month(p*r.Interval), 1, start)
+	}
+	return date(y+p*r.Interval, time.January, 1, start)
+}
+
+// period return sorted candidates of p-th period of the rule
+func (r *RRule) period(start time.Time, p int) []time.Time {
+	y, m, d := start.Date()
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := date(y, m, d+p*r.Interval, start)
+		if r.matchDay(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		monday := d - (int(start.Weekday())+6)%7 + 7*p*r.Interval
+		for i := 0; i < 7; i++ {
+			day := date(y, m, monday+i, start)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchDay(day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		days = r.monthDays(date(y, m+time.Month(p*r.Interval), 1, start), start)
+	case Yearly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			days = r.monthDays(date(y+p*r.Interval, m, 1, start), start)
+		} else {
+			days = r.yearDays(y+p*r.Interval, start)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays return candidates inside the month of first day
+func (r *RRule) monthDays(first time.Time, start time.Time) []time.Time {
+	var days []time.Time
+	last := first.AddDate(0, 1, -1).Day()
+	for d := 1; d <= last; d++ {
+		day := date(first.Year(), first.Month(), d, start)
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			if d == start.Day() {
+				days = append(days, day)
+			}
+			continue
+		}
+		if r.matchMonthDay(d, last) && r.matchWeekdayInMonth(d, last, day.Weekday()) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// yearDays return candidates inside the year, without BYMONTH every month of it is used
+// and ordinal of BYDAY is week of year, like "20MO" is the 20th Monday of year
+func (r *RRule) yearDays(y int, start time.Time) []time.Time {
+	var days []time.Time
+	yearLen := date(y, time.December, 31, start).YearDay()
+	for d := 1; d <= yearLen; d++ {
+		day := date(y, time.January, d, start)
+		last := date(y, day.Month()+1, 0, start).Day()
+		if r.matchMonthDay(day.Day(), last) && r.matchWeekdayInMonth(d, yearLen, day.Weekday()) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// matchDay apply BYDAY and BYMONTHDAY as filters for daily and weekly rules
+func (r *RRule) matchDay(day time.Time) bool {
+	last := date(day.Year(), day.Month()+1, 0, day).Day()
+	if !r.matchMonthDay(day.Day(), last) {
+		return false
+	}
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(d, last int) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	for _, md := range r.ByMonthDay {
+		if md == d || (md < 0 && last+md+1 == d) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekdayInMonth apply BYDAY to day d of month or of year which has last days
+func (r *RRule) matchWeekdayInMonth(d, last int, weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day != weekday {
+			continue
+		}
+		if wd.N == 0 || (wd.N > 0 && (d-1)/7+1 == wd.N) || (wd.N < 0 && (last-d)/7+1 == -wd.N) {
+			return true
+		}
+	}
+	return false
+}
+
+// date build time with wall clock and location of start
+func date(y int, m time.Month, d int, start time.Time) time.Time {
+	return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+}
+
+// IsRecurring check if event has recurrence rule
+func (ev *Event) IsRecurring() bool {
+	return ev.RRule != ""
+}
+
+// Occurrences return copies of event for every occurrence started in [from, to)
+// with overrides applied, not recurring event is returned as is
+func (ev *Event) Occurrences(from, to time.Time) ([]Event, error) {
+	if !ev.IsRecurring() {
+		return []Event{*ev}, nil
+	}
+	rule, loc, err := ev.rule()
+	if err != nil {
+		return nil, err
+	}
+	start := ev.DateTime.In(loc)
+	return ev.mergeOverrides(rule, start, rule.Between(start, from, to), from, to), nil
+}
@@ -0,0 +1,222 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxReminders is maximal number of reminders of one event
+	MaxReminders = 5
+	// maxReminderBefore is how long before start reminder can fire
+	maxReminderBefore = 28 * h24
+	// reminderHorizon is how far occurrences of recurring event are searched for next reminder
+	reminderHorizon = 366 * h24
+	reminderWindow  = 31 * h24
+	reminderAtForm  = "15:04"
+)
+
+var (
+	ErrInvalidReminder  = errors.New("reminder must fire from 0 to 28 days before start in whole minutes, at must be HH:MM")
+	ErrTooManyReminders = errors.New("event can have at most 5 reminders")
+	// ErrNoTrigger is returned when reminder has no occurrence to fire for within a year
+	ErrNoTrigger = errors.New("reminder has no next occurrence")
+)
+
+// Reminder fires some time before every occurrence of event,
+// like 10 minutes before or 1 day before at 09:00 local time of event
+type Reminder struct {
+	ID      uuid.UUID `gorm:"primaryKey"`
+	EventId uuid.UUID `gorm:"index"`
+	// Before is time before start of occurrence, BEFORE is reserved word of MySQL
+	Before time.Duration `gorm:"column:before_start"`
+	// At is local time of day, like "09:00", reminder fires at this time of the day it would fire by Before
+	At string `gorm:"size:5"`
+}
+
+type reminderHelper struct {
+	ID     uuid.UUID `json:"id,omitempty"`
+	Before string    `json:"before"`
+	At     string    `json:"at,omitempty"`
+}
+
+// MarshalJSON convert reminder to JSON with duration like "10m0s"
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(reminderHelper{r.ID, r.Before.String(), r.At})
+}
+
+// UnmarshalJSON convert JSON to reminder and check it, reminder without id gets new one
+func (r *Reminder) UnmarshalJSON(j []byte) error {
+	var rh reminderHelper
+	if err := json.Unmarshal(j, &rh); err != nil {
+		return err
+	}
+	before, err := time.ParseDuration(rh.Before)
+	if err != nil || before < 0 || before > maxReminderBefore || before%time.Minute != 0 {
+		return ErrInvalidReminder
+	}
+	if rh.At != "" {
+		if _, err = time.Parse(reminderAtForm, rh.At); err != nil {
+			return ErrInvalidReminder
+		}
+	}
+	r.ID = rh.ID
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	r.Before = before
+	r.At = rh.At
+	return nil
+}
+
+// FireAt return time when reminder fires for occurrence started at start, it is never after start
+func (r *Reminder) FireAt(start time.Time, loc *time.Location) time.Time {
+	fire := start.Add(-r.Before)
+	at, err := time.Parse(reminderAtForm, r.At)
+	if r.At == "" || err != nil {
+		return fire
+	}
+	local := fire.In(loc)
+	fire = time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if fire.After(start) {
+		fire = fire.AddDate(0, 0, -1)
+	}
+	return fire
+}
+
+// setReminders attach reminders to event
+func (ev *Event) setReminders(reminders []Reminder) error {
+	if len(reminders) > MaxReminders {
+		return ErrTooManyReminders
+	}
+	ev.Reminders = make([]Reminder, 0, len(reminders))
+	for _, r := range reminders {
+		r.EventId = ev.ID
+		ev.Reminders = append(ev.Reminders, r)
+	}
+	return nil
+}
+
+// Reminder return reminder of event by id or nil
+func (ev *Event) Reminder(id uuid.UUID) *Reminder {
+	for i := range ev.Reminders {
+		if ev.Reminders[i].ID == id {
+			return &ev.Reminders[i]
+		}
+	}
+	return nil
+}
+
+// CopyReminders add reminders of other event with new ids, used when series is split
+func (ev *Event) CopyReminders(other *Event) {
+	ev.Reminders = make([]Reminder, 0, len(other.Reminders))
+	for _, r := range other.Reminders {
+		r.ID = uuid.New()
+		r.EventId = ev.ID
+		ev.Reminders = append(ev.Reminders, r)
+	}
+}
+
+// NextReminder return the first occurrence which reminder fires for after given time and time it fires,
+// ok is false when there is no such occurrence within a year
+func (ev *Event) NextReminder(r Reminder, after time.Time) (occ Event, fireAt time.Time, ok bool, err error) {
+	if !ev.IsRecurring() {
+		fireAt, err = occurrenceFireAt(&r, ev)
+		return *ev, fireAt, err == nil && fireAt.After(after), err
+	}
+	// reminder never fires after start of occurrence, so only later occurrences are checked
+	for from := after; from.Before(after.Add(reminderHorizon)); from = from.Add(reminderWindow) {
+		occurrences, err := ev.Occurrences(from, from.Add(reminderWindow))
+		if err != nil {
+			return Event{}, time.Time{}, false, err
+		}
+		sort.Slice(occurrences, func(i, j int) bool {
+			return occurrences[i].DateTime.Before(occurrences[j].DateTime)
+		})
+		for _, occ := range occurrences {
+			fireAt, err = occurrenceFireAt(&r, &occ)
+			if err != nil {
+				return Event{}, time.Time{}, false, err
+			}
+			if fireAt.After(after) {
+				return occ, fireAt, true, nil
+			}
+		}
+	}
+	return Event{}, time.Time{}, false, nil
+}
+
+// occurrenceFireAt return time reminder fires for occurrence in its own timezone
+func occurrenceFireAt(r *Reminder, occ *Event) (time.Time, error) {
+	loc, err := time.LoadLocation(occ.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.FireAt(occ.DateTime, loc), nil
+}
+
+// TriggerStatus is state of delivery of reminder
+type TriggerStatus string
+
+const (
+	TriggerPending TriggerStatus = "pending"
+	TriggerSent    TriggerStatus = "sent"
+	// TriggerFailed is trigger which is not delivered after all attempts
+	TriggerFailed TriggerStatus = "failed"
+	// TriggerCancelled is trigger of removed reminder, changed occurrence or occurrence which is already over
+	TriggerCancelled TriggerStatus = "cancelled"
+)
+
+// Trigger is persisted moment when reminder fires for one occurrence, it tracks delivery of reminder
+type Trigger struct {
+	ID         uuid.UUID `json:"id" gorm:"primaryKey"`
+	EventId    uuid.UUID `json:"eventId" gorm:"index"`
+	ReminderId uuid.UUID `json:"reminderId" gorm:"index"`
+	// Start is start of occurrence the reminder is about
+	Start     time.Time     `json:"start"`
+	FireAt    time.Time     `json:"fireAt" gorm:"index"`
+	Status    TriggerStatus `json:"status" gorm:"size:10;index"`
+	Attempts  int           `json:"attempts"`
+	LastError string        `json:"lastError,omitempty" gorm:"size:256"`
+	// LockedUntil is end of lease of scheduler which delivers trigger, or time of next attempt after failure
+	LockedUntil *time.Time `json:"-"`
+	SentAt      *time.Time `json:"sentAt,omitempty"`
+}
+
+// NextTrigger return pending trigger of the first occurrence which reminder fires for after given time
+func (ev *Event) NextTrigger(r Reminder, after time.Time) (Trigger, error) {
+	occ, fireAt, ok, err := ev.NextReminder(r, after)
+	if err != nil {
+		return Trigger{}, err
+	}
+	if !ok {
+		return Trigger{}, ErrNoTrigger
+	}
+	return Trigger{
+		ID:         uuid.New(),
+		EventId:    ev.ID,
+		ReminderId: r.ID,
+		Start:      occ.DateTime,
+		FireAt:     fireAt,
+		Status:     TriggerPending,
+	}, nil
+}
+
+// Triggers return next trigger of every reminder which fires after given time
+func (ev *Event) Triggers(after time.Time) ([]Trigger, error) {
+	triggers := make([]Trigger, 0, len(ev.Reminders))
+	for _, r := range ev.Reminders {
+		t, err := ev.NextTrigger(r, after)
+		if errors.Is(err, ErrNoTrigger) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
@@ -18,6 +18,13 @@ type EventFilter struct {
 	TimeFrom string `schema:"timeFrom"`           // format "05:30"
 	TimeTo   string `schema:"timeTo"`             // format "06:30"
 	Title    string `schema:"title"`              // filter if title of event contains this string
+	// Calendar is ids of calendars, like "calendar=id1&calendar=id2" or "calendar=id1,id2", empty means all calendars
+	Calendar []string `schema:"calendar"`
+	// IncludeShared adds events of calendars shared with user, it is set by server and not read from request
+	IncludeShared bool `schema:"-"`
+	// Series return every event once as it is stored, recurring events as series with their overrides.
+	// Date and time criteria are not checked, timezone is changed only when it is given. It is set by server
+	Series bool `schema:"-"`
 }
 
 type HoursMin struct {
@@ -58,7 +65,7 @@ func IsFiltered(event *Event, ef EventFilter, loc *time.Location, dateFrom *time
 		return false
 	}
 
-	if ef.TimeTo != "" && !(timeTo.H > et.Hour() || (timeTo.H == et.Hour() && timeTo.H >= et.Minute())) {
+	if ef.TimeTo != "" && !(timeTo.H > et.Hour() || (timeTo.H == et.Hour() && timeTo.M >= et.Minute())) {
 		return false
 	}
 
@@ -0,0 +1,83 @@
+package event
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Access is what user can do with events of calendar
+type Access string
+
+const (
+	// AccessFreeBusy shows only time of events, like busy blocks
+	AccessFreeBusy Access = "freebusy"
+	AccessRead     Access = "read"
+	// AccessEdit allows to create, change and delete events of calendar
+	AccessEdit Access = "edit"
+	// AccessOwner is access of owner of calendar, it can't be granted
+	AccessOwner Access = "owner"
+)
+
+// BusyTitle is title of events which user sees with AccessFreeBusy
+const BusyTitle = "Busy"
+
+var ErrInvalidAccess = errors.New("access must be freebusy, read or edit")
+
+var accessRank = map[Access]int{AccessFreeBusy: 1, AccessRead: 2, AccessEdit: 3, AccessOwner: 4}
+
+// ParseAccess check access which can be granted
+func ParseAccess(s string) (Access, error) {
+	switch a := Access(s); a {
+	case AccessFreeBusy, AccessRead, AccessEdit:
+		return a, nil
+	}
+	return "", ErrInvalidAccess
+}
+
+// Allows check if access is enough for needed one, empty access allows nothing
+func (a Access) Allows(need Access) bool {
+	return accessRank[a] > 0 && accessRank[a] >= accessRank[need]
+}
+
+// Share is access of user to calendar of other user
+type Share struct {
+	CalendarId uuid.UUID `json:"calendarId" gorm:"primaryKey"`
+	UserId     uuid.UUID `json:"-" gorm:"primaryKey;index"`
+	// Login is login of user the calendar is shared with
+	Login     string    `json:"login" gorm:"size:64"`
+	Access    Access    `json:"access" gorm:"size:10"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AccessOf return access of user to event: owner, attendee, or access granted to calendar of event
+func (ev *Event) AccessOf(userId uuid.UUID, granted Access) Access {
+	if ev.UserId == userId {
+		return AccessOwner
+	}
+	if ev.Attendee(userId) != nil && !granted.Allows(AccessRead) {
+		return AccessRead
+	}
+	return granted
+}
+
+// BusyOnly return copy of event which shows only its time, moved and cancelled occurrences are kept
+func (ev *Event) BusyOnly() Event {
+	busy := Event{
+		ID:           ev.ID,
+		Title:        BusyTitle,
+		DateTime:     ev.DateTime,
+		Timezone:     ev.Timezone,
+		Duration:     ev.Duration,
+		RRule:        ev.RRule,
+		RecurrenceId: ev.RecurrenceId,
+		UserId:       ev.UserId,
+		CalendarId:   ev.CalendarId,
+		Version:      ev.Version,
+	}
+	for _, o := range ev.Overrides {
+		busy.Overrides = append(busy.Overrides, Override{EventId: o.EventId, RecurrenceId: o.RecurrenceId, Cancelled: o.Cancelled,
+			Title: BusyTitle, DateTime: o.DateTime, Timezone: o.Timezone, Duration: o.Duration})
+	}
+	return busy
+}
@@ -0,0 +1,448 @@
+package ical
+
+import (
+	"bufio"
+	"calendar/event"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateForm = "20060102"
+
+// EntryError describes VEVENT which can't be imported
+type EntryError struct {
+	UID   string `json:"uid,omitempty"`
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// property is content line like "DTSTART;TZID=Europe/Riga:20210802T100000"
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+	line   int
+}
+
+// component is BEGIN:NAME ... END:NAME block
+type component struct {
+	name       string
+	props      []property
+	components []*component
+	line       int
+}
+
+func (c *component) get(name string) *property {
+	for i := range c.props {
+		if c.props[i].name == name {
+			return &c.props[i]
+		}
+	}
+	return nil
+}
+
+func (c *component) value(name string) string {
+	if p := c.get(name); p != nil {
+		return p.value
+	}
+	return ""
+}
+
+// Decoder reads events from iCalendar (RFC 5545)
+type Decoder struct {
+	r io.Reader
+	// Location is used for floating times without TZID
+	Location *time.Location
+	// UserId is user who imports calendar, UIDs which are not UUID are hashed with it
+	UserId uuid.UUID
+}
+
+// NewDecoder return decoder which reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, Location: time.UTC}
+}
+
+// Decode read VEVENTs of all VCALENDARs. VEVENTs with RECURRENCE-ID are attached
+// to the series with the same UID. Entries which can't be converted to event.Event
+// are reported as EntryError, error is returned only if the whole input is broken.
+func (d *Decoder) Decode() ([]event.Event, []EntryError, error) {
+	top, err := parse(d.r)
+	if err != nil {
+		return nil, nil, err
+	}
+	dc := decoding{floating: d.Location, timezones: map[string]*vtimezone{}, userId: d.UserId}
+	if dc.floating == nil {
+		dc.floating = time.UTC
+	}
+	var vevents []*component
+	for _, cal := range top {
+		if cal.name != "VCALENDAR" {
+			return nil, nil, fmt.Errorf("line %d: unexpected component %s", cal.line, cal.name)
+		}
+		for _, c := range cal.components {
+			switch c.name {
+			case "VTIMEZONE":
+				tz, err := newVTimezone(c)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: %w", c.line, err)
+				}
+				dc.timezones[c.value("TZID")] = tz
+			case "VEVENT":
+				vevents = append(vevents, c)
+			}
+		}
+	}
+	if len(top) == 0 {
+		return nil, nil, errors.New("no VCALENDAR found")
+	}
+
+	var errs []EntryError
+	var uids []string
+	series := map[string]*event.Event{}
+	var overrides []*component
+	for _, c := range vevents {
+		uid := c.value("UID")
+		if c.get("RECURRENCE-ID") != nil {
+			overrides = append(overrides, c)
+			continue
+		}
+		if _, ok := series[uid]; ok {
+			errs = append(errs, EntryError{uid, c.line, "duplicate UID"})
+			continue
+		}
+		ev, err := dc.event(c)
+		if err != nil {
+			errs = append(errs, EntryError{uid, c.line, err.Error()})
+			continue
+		}
+		uids = append(uids, uid)
+		series[uid] = &ev
+	}
+
+	for _, c := range overrides {
+		uid := c.value("UID")
+		ev, ok := series[uid]
+		if !ok || !ev.IsRecurring() {
+			errs = append(errs, EntryError{uid, c.line, "no recurring event with this UID"})
+			continue
+		}
+		rid, _, err := dc.dateTime(*c.get("RECURRENCE-ID"))
+		if err != nil {
+			errs = append(errs, EntryError{uid, c.line, err.Error()})
+			continue
+		}
+		if strings.ToUpper(c.value("STATUS")) == "CANCELLED" {
+			ev.CancelOccurrence(rid)
+			continue
+		}
+		changed, err := dc.event(c)
+		if err != nil {
+			errs = append(errs, EntryError{uid, c.line, err.Error()})
+			continue
+		}
+		ev.ModifyOccurrence(rid, changed)
+	}
+
+	events := make([]event.Event, len(uids))
+	for i, uid := range uids {
+		events[i] = *series[uid]
+	}
+	return events, errs, nil
+}
+
+// EventId return id of event imported by user with uid. UID which is UUID, like UID of event exported
+// by this server, is the id. Other UIDs are hashed with user, so the same event gets the same id on every import
+// and users who import the same calendar get their own events
+func EventId(userId uuid.UUID, uid string) uuid.UUID {
+	if id, err := uuid.Parse(uid); err == nil {
+		return id
+	}
+	return UserEventId(userId, uid)
+}
+
+// UserEventId return id of event of user with uid hashed with user, it is used when UUID UID
+// is id of event of another user
+func UserEventId(userId uuid.UUID, uid string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(userId.String()+"/"+uid))
+}
+
+// parse read unfolded content lines and build components from them
+func parse(r io.Reader) ([]*component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	var numbers []int
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text != "" && (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, text)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var top, stack []*component
+	for i, text := range lines {
+		prop, err := parseProperty(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", numbers[i], err)
+		}
+		prop.line = numbers[i]
+		switch prop.name {
+		case "BEGIN":
+			c := &component{name: strings.ToUpper(prop.value), line: prop.line}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			} else {
+				top = append(top, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", prop.line, prop.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s is outside of component", prop.line, prop.name)
+			}
+			c := stack[len(stack)-1]
+			c.props = append(c.props, prop)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s is not closed", stack[len(stack)-1].name)
+	}
+	return top, nil
+}
+
+func parseProperty(text string) (property, error) {
+	colon := indexUnquoted(text, ':')
+	if colon < 0 {
+		return property{}, fmt.Errorf("wrong content line %q", text)
+	}
+	head := text[:colon]
+	prop := property{value: text[colon+1:], params: map[string]string{}}
+	for i := 0; ; i++ {
+		end := indexUnquoted(head, ';')
+		part := head
+		if end >= 0 {
+			part, head = head[:end], head[end+1:]
+		}
+		if i == 0 {
+			prop.name = strings.ToUpper(part)
+		} else {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 {
+				return property{}, fmt.Errorf("wrong parameter %q", part)
+			}
+			prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+		if end < 0 {
+			break
+		}
+	}
+	return prop, nil
+}
+
+// indexUnquoted return index of first ch outside of double quotes
+func indexUnquoted(s string, ch byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' {
+			quoted = !quoted
+		} else if s[i] == ch && !quoted {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape TEXT value
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// decoding keeps timezones of calendar while VEVENTs are converted
+type decoding struct {
+	floating  *time.Location
+	timezones map[string]*vtimezone
+	userId    uuid.UUID
+}
+
+// event convert VEVENT to event.Event
+func (dc *decoding) event(c *component) (event.Event, error) {
+	var ev event.Event
+	uid := c.value("UID")
+	if uid == "" {
+		return ev, errors.New("UID is required")
+	}
+	ev.ID = EventId(dc.userId, uid)
+	start := c.get("DTSTART")
+	if start == nil {
+		return ev, errors.New("DTSTART is required")
+	}
+	var err error
+	ev.DateTime, ev.Timezone, err = dc.dateTime(*start)
+	if err != nil {
+		return ev, err
+	}
+	if d := c.get("DURATION"); d != nil {
+		ev.Duration, err = ParseDuration(d.value)
+	} else if end := c.get("DTEND"); end != nil {
+		var endTime time.Time
+		endTime, _, err = dc.dateTime(*end)
+		ev.Duration = endTime.Sub(ev.DateTime)
+	} else if isDate(*start) {
+		ev.Duration = 24 * time.Hour
+	}
+	if err != nil {
+		return ev, err
+	}
+	if ev.Duration < 0 {
+		return ev, errors.New("event ends before start")
+	}
+	ev.Title = unescape(c.value("SUMMARY"))
+	ev.Description = unescape(c.value("DESCRIPTION"))
+
+	if rr := c.get("RRULE"); rr != nil {
+		loc, err := time.LoadLocation(ev.Timezone)
+		if err != nil {
+			return ev, err
+		}
+		rule, err := event.ParseRRule(rr.value, loc)
+		if err != nil {
+			return ev, err
+		}
+		ev.RRule = rule.String()
+	}
+	for _, p := range c.props {
+		if p.name != "EXDATE" {
+			continue
+		}
+		for _, value := range strings.Split(p.value, ",") {
+			p.value = value
+			rid, _, err := dc.dateTime(p)
+			if err != nil {
+				return ev, err
+			}
+			ev.CancelOccurrence(rid)
+		}
+	}
+	return ev, nil
+}
+
+func isDate(p property) bool {
+	return p.params["VALUE"] == "DATE" || len(p.value) == len(dateForm)
+}
+
+// dateTime convert DATE or DATE-TIME value to time and name of its timezone.
+// Times in VTIMEZONE unknown to time.LoadLocation are converted to UTC.
+func (dc *decoding) dateTime(p property) (time.Time, string, error) {
+	value := strings.ToUpper(p.value)
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeForm, strings.TrimSuffix(value, "Z"))
+		return t, "UTC", err
+	}
+	form := dateTimeForm
+	if isDate(p) {
+		form = dateForm
+	}
+	wall, err := time.Parse(form, value)
+	if err != nil {
+		return wall, "", err
+	}
+
+	tzid := strings.TrimPrefix(p.params["TZID"], "/")
+	loc := dc.floating
+	if tzid != "" {
+		if vtz, ok := dc.timezones[tzid]; ok && vtz.location != nil {
+			loc = vtz.location
+		} else if loc, err = time.LoadLocation(tzid); err != nil {
+			if !ok {
+				return wall, "", fmt.Errorf("unknown timezone %q", tzid)
+			}
+			return vtz.toUTC(wall), "UTC", nil
+		}
+	}
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+	return t, loc.String(), nil
+}
+
+// ParseDuration convert RFC 5545 duration like "PT1H30M", "P1D" or "-P1W" to time.Duration
+func ParseDuration(s string) (time.Duration, error) {
+	value := strings.ToUpper(s)
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+	}
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, fmt.Errorf("wrong duration %q", s)
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, ch := range value[1:] {
+		if ch >= '0' && ch <= '9' {
+			num += string(ch)
+			continue
+		}
+		if ch == 'T' {
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("wrong duration %q", s)
+		}
+		num = ""
+		switch {
+		case ch == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case ch == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case ch == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case ch == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case ch == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("wrong duration %q", s)
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("wrong duration %q", s)
+	}
+	return sign * d, nil
+}
@@ -0,0 +1,131 @@
+package ical
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:W. Europe Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"DTSTART;TZID=Europe/Riga:20210802T100000\r\n" +
+	"DURATION:PT15M\r\n" +
+	"SUMMARY:Daily\\, standup\r\n" +
+	"DESCRIPTION:A very long description which is folded by the client to fit in\r\n" +
+	"  seventy five octets\r\n" +
+	"RRULE:FREQ=DAILY;COUNT=5\r\n" +
+	"EXDATE;TZID=Europe/Riga:20210803T100000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Riga:20210804T100000\r\n" +
+	"DTSTART;TZID=Europe/Riga:20210804T110000\r\n" +
+	"DTEND;TZID=Europe/Riga:20210804T113000\r\n" +
+	"SUMMARY:Moved standup\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:outlook-1\r\n" +
+	"DTSTART;TZID=W. Europe Standard Time:20210701T090000\r\n" +
+	"DTEND;TZID=W. Europe Standard Time:20210701T100000\r\n" +
+	"SUMMARY:Summer meeting\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:broken\r\n" +
+	"SUMMARY:No start\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	events, errs, err := NewDecoder(strings.NewReader(testCalendar)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].UID != "broken" {
+		t.Errorf("Decode() entry errors = %v, want error for broken", errs)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Decode() returned %d events, want 2", len(events))
+	}
+
+	standup := events[0]
+	if standup.ID != EventId(uuid.Nil, "standup@example.com") || standup.Title != "Daily, standup" || standup.Duration != 15*time.Minute {
+		t.Errorf("Decode() standup = %v", standup)
+	}
+	if !strings.HasSuffix(standup.Description, "to fit in seventy five octets") {
+		t.Errorf("Decode() didn't unfold description %q", standup.Description)
+	}
+	occurrences, _ := standup.Occurrences(time.Time{}, time.Time{})
+	if len(occurrences) != 4 || occurrences[1].Title != "Moved standup" || occurrences[1].Duration != 30*time.Minute {
+		t.Errorf("Decode() standup occurrences = %v", occurrences)
+	}
+
+	summer := events[1]
+	want := time.Date(2021, time.July, 1, 7, 0, 0, 0, time.UTC)
+	if !summer.DateTime.Equal(want) || summer.Duration != time.Hour {
+		t.Errorf("Decode() summer meeting at %v for %v, want %v for 1h", summer.DateTime, summer.Duration, want)
+	}
+}
+
+func TestDecodeBroken(t *testing.T) {
+	for _, data := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nno colon\r\nEND:VCALENDAR\r\n",
+		"",
+	} {
+		if _, _, err := NewDecoder(strings.NewReader(data)).Decode(); err == nil {
+			t.Errorf("Decode(%q) didn't return error", data)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"PT1H30M", time.Hour + 30*time.Minute, false},
+		{"P1W", 7 * 24 * time.Hour, false},
+		{"-P1DT2H", -26 * time.Hour, false},
+		{"P1H", 0, true},
+		{"1H", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestEventIdOfUsers(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	if EventId(alice, "standup@example.com") == EventId(bob, "standup@example.com") {
+		t.Error("EventId() is the same for UID of two users")
+	}
+	if EventId(alice, "standup@example.com") != EventId(alice, "standup@example.com") {
+		t.Error("EventId() changes for the same UID of user")
+	}
+	id := uuid.New()
+	if EventId(alice, id.String()) != id || UserEventId(bob, id.String()) == id {
+		t.Error("EventId() must keep UUID UID and UserEventId() must hash it")
+	}
+}
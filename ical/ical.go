@@ -0,0 +1,265 @@
+package ical
+
+import (
+	"bufio"
+	"calendar/event"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ContentType  = "text/calendar; charset=utf-8"
+	ProdId       = "-//DamSpielerin//Calendar//EN"
+	dateTimeForm = "20060102T150405"
+	// lineLength is max length of content line in octets without CRLF
+	lineLength = 75
+)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// Encoder writes events as iCalendar (RFC 5545)
+type Encoder struct {
+	w   *bufio.Writer
+	now time.Time
+}
+
+// NewEncoder return encoder which writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w), now: time.Now()}
+}
+
+// Encode write VCALENDAR with VEVENT for every event, recurring events are written
+// as series with RRULE, EXDATE for cancelled occurrences and VEVENT for every modified one
+func (e *Encoder) Encode(events []event.Event) error {
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", ProdId)
+	e.line("CALSCALE", "GREGORIAN")
+	e.timezones(events)
+	for i := range events {
+		e.encodeEvent(&events[i])
+	}
+	e.line("END", "VCALENDAR")
+	return e.w.Flush()
+}
+
+func (e *Encoder) encodeEvent(ev *event.Event) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", ev.ID.String())
+	e.dtstamp(ev)
+	e.dateTime("DTSTART", ev.DateTime, ev.Timezone)
+	e.line("DURATION", FormatDuration(ev.Duration))
+	e.text("SUMMARY", ev.Title)
+	e.text("DESCRIPTION", ev.Description)
+	for _, a := range ev.Attendees {
+		e.attendee(a)
+	}
+	if ev.IsRecurring() {
+		e.line("RRULE", ev.RRule)
+		for _, o := range ev.Overrides {
+			if o.Cancelled {
+				e.dateTime("EXDATE", o.RecurrenceId, ev.Timezone)
+			}
+		}
+	}
+	e.line("END", "VEVENT")
+
+	for _, o := range ev.Overrides {
+		if o.Cancelled {
+			continue
+		}
+		e.line("BEGIN", "VEVENT")
+		e.line("UID", ev.ID.String())
+		e.dtstamp(ev)
+		e.dateTime("RECURRENCE-ID", o.RecurrenceId, ev.Timezone)
+		e.dateTime("DTSTART", o.DateTime, o.Timezone)
+		e.line("DURATION", FormatDuration(o.Duration))
+		e.text("SUMMARY", o.Title)
+		e.text("DESCRIPTION", o.Description)
+		e.line("END", "VEVENT")
+	}
+}
+
+// attendee write ATTENDEE with role and participation status, login of registered user is common name
+func (e *Encoder) attendee(a event.Attendee) {
+	if a.Email == "" {
+		return
+	}
+	params := ";ROLE=" + strings.ToUpper(string(a.Role)) + ";PARTSTAT=" + strings.ToUpper(string(a.Status))
+	if a.Login != "" {
+		params += `;CN="` + strings.ReplaceAll(a.Login, `"`, "") + `"`
+	}
+	e.line("ATTENDEE"+params, "mailto:"+a.Email)
+}
+
+func (e *Encoder) dtstamp(ev *event.Event) {
+	stamp := e.now
+	if !ev.UpdatedAt.IsZero() {
+		stamp = ev.UpdatedAt
+	}
+	e.line("DTSTAMP", stamp.UTC().Format(dateTimeForm)+"Z")
+}
+
+// dateTime write time with TZID parameter or in UTC form
+func (e *Encoder) dateTime(name string, t time.Time, timezone string) {
+	loc := tzLocation(timezone)
+	if loc == nil {
+		e.line(name, t.UTC().Format(dateTimeForm)+"Z")
+		return
+	}
+	e.line(name+";TZID="+loc.String(), t.In(loc).Format(dateTimeForm))
+}
+
+// tzLocation return location of timezone whose times are written with TZID, nil for times in UTC form
+func tzLocation(timezone string) *time.Location {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || loc == time.UTC || timezone == "" {
+		return nil
+	}
+	return loc
+}
+
+// timezones write VTIMEZONE for every TZID of events, RFC 5545 requires it for clients which don't know IANA names.
+// Observances cover years of times of events, series which go on cover the next year too
+func (e *Encoder) timezones(events []event.Event) {
+	type years struct {
+		loc      *time.Location
+		from, to int
+	}
+	used := map[string]*years{}
+	use := func(timezone string, t time.Time) {
+		loc := tzLocation(timezone)
+		if loc == nil {
+			return
+		}
+		year := t.In(loc).Year()
+		y, ok := used[loc.String()]
+		if !ok {
+			used[loc.String()] = &years{loc, year, year}
+			return
+		}
+		if year < y.from {
+			y.from = year
+		}
+		if year > y.to {
+			y.to = year
+		}
+	}
+	for i := range events {
+		ev := &events[i]
+		use(ev.Timezone, ev.DateTime)
+		if ev.IsRecurring() {
+			use(ev.Timezone, e.now.AddDate(1, 0, 0))
+		}
+		for _, o := range ev.Overrides {
+			use(ev.Timezone, o.RecurrenceId)
+			if !o.Cancelled {
+				use(o.Timezone, o.DateTime)
+			}
+		}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// the year before lets observances start before the first time of events
+		e.vtimezone(used[name].loc, used[name].from-1, used[name].to)
+	}
+}
+
+// vtimezone write observances of location in years from-to, years with the same transitions are one observance
+// with yearly RRULE. X-LIC-LOCATION lets clients which know the IANA name use their own rules
+func (e *Encoder) vtimezone(loc *time.Location, from, to int) {
+	e.line("BEGIN", "VTIMEZONE")
+	e.line("TZID", loc.String())
+	e.line("X-LIC-LOCATION", loc.String())
+	periods := tzPeriods(loc, from, to)
+	for i, p := range periods {
+		last := i == len(periods)-1
+		for j, tr := range p.first {
+			kind := "STANDARD"
+			if tr.dst {
+				kind = "DAYLIGHT"
+			}
+			e.line("BEGIN", kind)
+			e.line("DTSTART", tr.wall().Format(dateTimeForm))
+			e.line("TZOFFSETFROM", formatOffset(tr.from))
+			e.line("TZOFFSETTO", formatOffset(tr.to))
+			if tr.name != "" {
+				e.line("TZNAME", tr.name)
+			}
+			if !p.fixed && (last || p.last[j].at.Year() > tr.at.Year()) {
+				rule := "FREQ=YEARLY;" + tr.byDay()
+				if !last {
+					rule += ";UNTIL=" + p.last[j].at.UTC().Format(dateTimeForm) + "Z"
+				}
+				e.line("RRULE", rule)
+			}
+			e.line("END", kind)
+		}
+	}
+	e.line("END", "VTIMEZONE")
+}
+
+func (e *Encoder) text(name, value string) {
+	if value != "" {
+		e.line(name, textEscaper.Replace(value))
+	}
+}
+
+// line write content line folded by RFC 5545 rules
+func (e *Encoder) line(name, value string) {
+	line := name + ":" + value
+	// continuation lines start with space, so they have one octet less
+	limit := lineLength
+	for len(line) > limit {
+		cut := limit
+		// don't split multi-byte utf-8 characters
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		e.w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = lineLength - 1
+	}
+	e.w.WriteString(line + "\r\n")
+}
+
+// FormatDuration convert duration to RFC 5545 form like "PT1H30M" or "P1D"
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	if d == 0 {
+		return "PT0S"
+	}
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"bytes"
+	"calendar/event"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{time.Hour + 30*time.Minute, "PT1H30M"},
+		{24 * time.Hour, "P1D"},
+		{26*time.Hour + 15*time.Second, "P1DT2H15S"},
+		{-15 * time.Minute, "-PT15M"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%v) = %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Riga")
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	start := time.Date(2021, time.August, 2, 10, 0, 0, 0, loc)
+	ev := event.Event{
+		ID:          id,
+		Title:       "Standup, daily",
+		Description: "Line one\nline two; " + strings.Repeat("long ", 20),
+		DateTime:    start,
+		Timezone:    loc.String(),
+		Duration:    15 * time.Minute,
+		RRule:       "FREQ=DAILY;COUNT=5",
+	}
+	ev.CancelOccurrence(start.AddDate(0, 0, 1))
+	ev.Invite(event.Attendee{Login: "User2", Email: "user2@ukr.net", Role: event.RoleRequired})
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode([]event.Event{ev}); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:" + id.String() + "\r\n",
+		"DTSTART;TZID=Europe/Riga:20210802T100000\r\n",
+		"DURATION:PT15M\r\n",
+		"SUMMARY:Standup\\, daily\r\n",
+		`DESCRIPTION:Line one\nline two\; long`,
+		"RRULE:FREQ=DAILY;COUNT=5\r\n",
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;CN=\"User2\":mailto:",
+		"EXDATE;TZID=Europe/Riga:20210803T100000\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Encode() = %q, want it to contain %q", got, want)
+		}
+	}
+	for _, line := range strings.Split(got, "\r\n") {
+		if len(line) > lineLength {
+			t.Errorf("line %q is longer than %d octets", line, lineLength)
+		}
+	}
+}
+
+func TestEncodeTimezones(t *testing.T) {
+	riga, _ := time.LoadLocation("Europe/Riga")
+	events := []event.Event{
+		{ID: uuid.New(), Title: "Standup", DateTime: time.Date(2021, time.August, 2, 10, 0, 0, 0, riga), Timezone: "Europe/Riga",
+			Duration: 15 * time.Minute},
+		{ID: uuid.New(), Title: "Retro", DateTime: time.Date(2021, time.August, 6, 15, 0, 0, 0, riga), Timezone: "Europe/Riga",
+			Duration: time.Hour},
+		{ID: uuid.New(), Title: "Call", DateTime: time.Date(2021, time.August, 3, 9, 0, 0, 0, time.UTC), Timezone: "UTC",
+			Duration: time.Hour},
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(events); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if n := strings.Count(got, "BEGIN:VTIMEZONE\r\n"); n != 1 {
+		t.Fatalf("Encode() has %d VTIMEZONE, want one of Europe/Riga: %q", n, got)
+	}
+	for _, want := range []string{
+		"TZID:Europe/Riga\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20200329T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0300\r\nTZNAME:EEST\r\n" +
+			"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20201025T040000\r\nTZOFFSETFROM:+0300\r\nTZOFFSETTO:+0200\r\nTZNAME:EET\r\n" +
+			"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Encode() = %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Index(got, "BEGIN:VTIMEZONE") > strings.Index(got, "BEGIN:VEVENT") {
+		t.Error("VTIMEZONE is written after VEVENT")
+	}
+}
+
+func TestEncodedTimezonesConvertTimes(t *testing.T) {
+	for _, name := range []string{"Europe/Riga", "America/New_York", "Australia/Sydney", "Asia/Kolkata", "Asia/Tokyo"} {
+		t.Run(name, func(t *testing.T) {
+			loc, _ := time.LoadLocation(name)
+			ev := event.Event{ID: uuid.New(), Title: "Standup", DateTime: time.Date(2021, time.August, 2, 10, 0, 0, 0, loc),
+				Timezone: name, Duration: time.Hour}
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf).Encode([]event.Event{ev}); err != nil {
+				t.Fatal(err)
+			}
+			// without X-LIC-LOCATION times are converted by observances only
+			text := strings.Replace(buf.String(), "X-LIC-LOCATION:"+name+"\r\n", "", 1)
+			top, err := parse(strings.NewReader(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tz *vtimezone
+			for _, c := range top[0].components {
+				if c.name == "VTIMEZONE" {
+					if tz, err = newVTimezone(c); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if tz == nil || tz.location != nil {
+				t.Fatalf("VTIMEZONE is not written: %q", text)
+			}
+			for wall := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.UTC); wall.Year() == 2021; wall = wall.AddDate(0, 0, 5) {
+				want := time.Date(wall.Year(), wall.Month(), wall.Day(), 12, 0, 0, 0, loc)
+				if got := tz.toUTC(wall); !got.Equal(want) {
+					t.Fatalf("%s is converted to %s, want %s", wall.Format(dateTimeForm), got, want.UTC())
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeTimezoneWithChangedRules(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	events := []event.Event{
+		{ID: uuid.New(), Title: "Old", DateTime: time.Date(2005, time.June, 1, 10, 0, 0, 0, ny), Timezone: ny.String(), Duration: time.Hour},
+		{ID: uuid.New(), Title: "New", DateTime: time.Date(2021, time.June, 1, 10, 0, 0, 0, ny), Timezone: ny.String(), Duration: time.Hour},
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(events); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		// rules before 2007 end with the last transition by them
+		"RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T070000Z\r\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20061029T060000Z\r\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU\r\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Encode() = %q, want it to contain %q", got, want)
+		}
+	}
+}
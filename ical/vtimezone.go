@@ -0,0 +1,264 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vtimezone converts local times of VTIMEZONE block. If the block has
+// X-LIC-LOCATION known to time.LoadLocation, that location is used instead.
+type vtimezone struct {
+	location    *time.Location
+	observances []observance
+}
+
+// observance is STANDARD or DAYLIGHT part of VTIMEZONE
+type observance struct {
+	// start is local DTSTART kept as wall clock in UTC
+	start  time.Time
+	offset time.Duration
+	// month and weekday describe yearly "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU" rule
+	yearly  bool
+	month   time.Month
+	weekday time.Weekday
+	n       int
+}
+
+func newVTimezone(c *component) (*vtimezone, error) {
+	if c.value("TZID") == "" {
+		return nil, errors.New("VTIMEZONE without TZID")
+	}
+	tz := &vtimezone{}
+	if name := c.value("X-LIC-LOCATION"); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			tz.location = loc
+		}
+	}
+	for _, sub := range c.components {
+		if sub.name != "STANDARD" && sub.name != "DAYLIGHT" {
+			continue
+		}
+		o, err := newObservance(sub)
+		if err != nil {
+			return nil, err
+		}
+		tz.observances = append(tz.observances, o)
+	}
+	if tz.location == nil && len(tz.observances) == 0 {
+		return nil, fmt.Errorf("VTIMEZONE %s has no STANDARD or DAYLIGHT", c.value("TZID"))
+	}
+	return tz, nil
+}
+
+func newObservance(c *component) (observance, error) {
+	var o observance
+	var err error
+	o.start, err = time.Parse(dateTimeForm, c.value("DTSTART"))
+	if err != nil {
+		return o, fmt.Errorf("wrong DTSTART of %s", c.name)
+	}
+	o.offset, err = parseOffset(c.value("TZOFFSETTO"))
+	if err != nil {
+		return o, err
+	}
+	rule := c.value("RRULE")
+	if rule == "" {
+		return o, nil
+	}
+	for _, part := range strings.Split(strings.ToUpper(rule), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "FREQ":
+			o.yearly = kv[1] == "YEARLY"
+		case "BYMONTH":
+			m, err := strconv.Atoi(kv[1])
+			if err != nil || m < 1 || m > 12 {
+				return o, fmt.Errorf("wrong BYMONTH %q", kv[1])
+			}
+			o.month = time.Month(m)
+		case "BYDAY":
+			day := kv[1]
+			if len(day) < 2 {
+				return o, fmt.Errorf("wrong BYDAY %q", day)
+			}
+			weekday, ok := weekdayNames[day[len(day)-2:]]
+			if !ok {
+				return o, fmt.Errorf("wrong BYDAY %q", day)
+			}
+			o.weekday = weekday
+			if day[:len(day)-2] != "" {
+				if o.n, err = strconv.Atoi(day[:len(day)-2]); err != nil {
+					return o, fmt.Errorf("wrong BYDAY %q", day)
+				}
+			}
+		}
+	}
+	if o.yearly && (o.month == 0 || o.n == 0) {
+		return o, fmt.Errorf("unsupported VTIMEZONE rule %q", rule)
+	}
+	return o, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseOffset convert "+0200" or "-053000" to duration
+func parseOffset(s string) (time.Duration, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("wrong utc offset %q", s)
+	}
+	h, errH := strconv.Atoi(s[1:3])
+	m, errM := strconv.Atoi(s[3:5])
+	sec := 0
+	var errS error
+	if len(s) == 7 {
+		sec, errS = strconv.Atoi(s[5:7])
+	}
+	if errH != nil || errM != nil || errS != nil {
+		return 0, fmt.Errorf("wrong utc offset %q", s)
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	if s[0] == '-' {
+		d = -d
+	}
+	return d, nil
+}
+
+// onset return the latest start of observance which is not after wall clock
+func (o *observance) onset(wall time.Time) (time.Time, bool) {
+	if !o.yearly {
+		return o.start, !o.start.After(wall)
+	}
+	for year := wall.Year(); year >= wall.Year()-1; year-- {
+		t := nthWeekday(year, o.month, o.weekday, o.n, o.start)
+		if !t.After(wall) && !t.Before(o.start) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nthWeekday return n-th weekday of month, negative n counts from the end of month
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int, clock time.Time) time.Time {
+	h, m, s := clock.Clock()
+	if n > 0 {
+		first := time.Date(year, month, 1, h, m, s, 0, time.UTC)
+		shift := (int(weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, shift+7*(n-1))
+	}
+	last := time.Date(year, month+1, 0, h, m, s, 0, time.UTC)
+	shift := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -shift+7*(n+1))
+}
+
+// toUTC convert wall clock of timezone to UTC by the observance which started the last
+func (tz *vtimezone) toUTC(wall time.Time) time.Time {
+	var offset time.Duration
+	var latest time.Time
+	found := false
+	for i := range tz.observances {
+		if t, ok := tz.observances[i].onset(wall); ok && (!found || t.After(latest)) {
+			latest, offset, found = t, tz.observances[i].offset, true
+		}
+	}
+	if !found && len(tz.observances) > 0 {
+		offset = tz.observances[0].offset
+	}
+	return wall.Add(-offset)
+}
+
+// transition is change of utc offset of location
+type transition struct {
+	// at is the first instant of new offset
+	at       time.Time
+	from, to int
+	name     string
+	dst      bool
+}
+
+// wall return local time of transition by offset before it, like DTSTART of observance
+func (tr transition) wall() time.Time {
+	return tr.at.UTC().Add(time.Duration(tr.from) * time.Second)
+}
+
+// byDay return yearly rule of transition, like "BYMONTH=3;BYDAY=-1SU" for the last Sunday of March
+func (tr transition) byDay() string {
+	wall := tr.wall()
+	n := (wall.Day()-1)/7 + 1
+	if wall.Day()+7 > time.Date(wall.Year(), wall.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		n = -1
+	}
+	return fmt.Sprintf("BYMONTH=%d;BYDAY=%d%s", wall.Month(), n, strings.ToUpper(wall.Weekday().String()[:2]))
+}
+
+// tzPeriod is years of location with the same transitions, fixed period has only one offset
+type tzPeriod struct {
+	first, last []transition
+	fixed       bool
+	key         string
+}
+
+// yearTransitions return changes of offset of location in year, year without them has one fixed
+// transition at its start which keeps the offset
+func yearTransitions(loc *time.Location, year int) ([]transition, bool) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+	var trs []transition
+	for t := start; ; {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || !next.Before(end) {
+			break
+		}
+		_, from := t.Zone()
+		name, to := next.Zone()
+		trs = append(trs, transition{at: next, from: from, to: to, name: name, dst: next.IsDST()})
+		t = next
+	}
+	if len(trs) > 0 {
+		return trs, false
+	}
+	name, offset := start.Zone()
+	return []transition{{at: start, from: offset, to: offset, name: name, dst: start.IsDST()}}, true
+}
+
+// tzPeriods split years of location by their transitions
+func tzPeriods(loc *time.Location, from, to int) []tzPeriod {
+	var periods []tzPeriod
+	for year := from; year <= to; year++ {
+		trs, fixed := yearTransitions(loc, year)
+		key := fmt.Sprint(fixed)
+		for _, tr := range trs {
+			key += fmt.Sprint(";", tr.from, tr.to, tr.name, tr.dst, tr.wall().Format("150405"))
+			if !fixed {
+				key += tr.byDay()
+			}
+		}
+		if n := len(periods); n > 0 && periods[n-1].key == key {
+			periods[n-1].last = trs
+			continue
+		}
+		periods = append(periods, tzPeriod{first: trs, last: trs, fixed: fixed, key: key})
+	}
+	return periods
+}
+
+// formatOffset convert offset in seconds to "+0200" or "-053000"
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Manager runs servers and workers until SIGTERM/SIGINT or failure of one of them,
+// then stops everything in order of registration, every step within its own timeout
+type Manager struct {
+	steps  []step
+	failed chan error
+}
+
+type step struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// New return manager without steps
+func New() *Manager {
+	return &Manager{failed: make(chan error, 1)}
+}
+
+// Go run function in background, its error starts shutdown
+func (m *Manager) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil {
+			select {
+			case m.failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// OnStop add shutdown step, steps are run in order they are added
+func (m *Manager) OnStop(name string, timeout time.Duration, stop func(ctx context.Context) error) {
+	m.steps = append(m.steps, step{name, timeout, stop})
+}
+
+// Serve start http server and add step which drains it by http.Server.Shutdown
+func (m *Manager) Serve(name string, srv *http.Server, timeout time.Duration) {
+	m.Go(name, func() error {
+		log.Printf("%s is listening on %s", name, srv.Addr)
+		err := srv.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	})
+	m.OnStop(name, timeout, srv.Shutdown)
+}
+
+// Worker run background function until its context is cancelled by shutdown step,
+// the step waits for the function to return
+func (m *Manager) Worker(name string, timeout time.Duration, run func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.Go(name, func() error {
+		defer close(done)
+		return run(ctx)
+	})
+	m.OnStop(name, timeout, func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// Run wait for SIGTERM/SIGINT, cancel of ctx or failure of started function and stop all steps.
+// Second signal during shutdown kills process as usual
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	var cause error
+	select {
+	case <-ctx.Done():
+		log.Println("shutting down")
+	case cause = <-m.failed:
+		log.Println("shutting down after failure: ", cause)
+	}
+	stopSignals()
+	err := m.Stop()
+	if cause != nil {
+		return cause
+	}
+	return err
+}
+
+// Stop run shutdown steps in order, step which doesn't finish in time is abandoned
+func (m *Manager) Stop() error {
+	var errs []string
+	for _, s := range m.steps {
+		start := time.Now()
+		if err := runStep(s); err != nil {
+			log.Printf("%s is not stopped: %v", s.name, err)
+			errs = append(errs, s.name+": "+err.Error())
+			continue
+		}
+		log.Printf("%s is stopped in %v", s.name, time.Since(start).Round(time.Millisecond))
+	}
+	if len(errs) > 0 {
+		return errors.New("shutdown: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func runStep(s step) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStopsStepsInOrder(t *testing.T) {
+	m := New()
+	var stopped []string
+	for _, name := range []string{"server", "workers", "db"} {
+		name := name
+		m.OnStop(name, time.Second, func(ctx context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"server", "workers", "db"}
+	if !reflect.DeepEqual(stopped, want) {
+		t.Errorf("got order %v, want %v", stopped, want)
+	}
+}
+
+func TestRunStopsAfterFailure(t *testing.T) {
+	m := New()
+	stopped := false
+	m.OnStop("db", time.Second, func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+	m.Go("server", func() error {
+		return errors.New("address already in use")
+	})
+	err := m.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Errorf("got error %v, want failure of server", err)
+	}
+	if !stopped {
+		t.Error("step is not stopped after failure")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	m := New()
+	next := false
+	m.OnStop("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	m.OnStop("next", time.Second, func(ctx context.Context) error {
+		next = true
+		return nil
+	})
+	err := m.Stop()
+	if err == nil || !strings.Contains(err.Error(), "slow") {
+		t.Errorf("got error %v, want timeout of slow step", err)
+	}
+	if !next {
+		t.Error("step after timed out one is not stopped")
+	}
+}
+
+func TestWorkerIsCancelledOnStop(t *testing.T) {
+	m := New()
+	finished := false
+	m.Worker("worker", time.Second, func(ctx context.Context) error {
+		<-ctx.Done()
+		finished = true
+		return nil
+	})
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !finished {
+		t.Error("worker is not finished when its step is stopped")
+	}
+}
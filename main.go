@@ -1,22 +1,119 @@
 package main
 
 import (
+	"calendar/config"
+	"calendar/lifecycle"
+	"calendar/reminder"
 	"calendar/server"
 	"calendar/storage"
+	"calendar/stream"
+	"calendar/user"
+	"calendar/webhook"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
+	"os"
+	"time"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	user.JwtKey = []byte(cfg.Auth.JwtKey)
+	user.AccessTokenTTL = cfg.Auth.AccessTokenTTL
+	user.RefreshTokenTTL = cfg.Auth.RefreshTokenTTL
+
+	lc := lifecycle.New()
 
-	store, err := storage.NewDbStorage("root:123456@tcp(127.0.0.1:3307)/calendar?charset=utf8mb4", 60, 10)
+	var (
+		store        storage.EventStore
+		userStore    storage.UserStore
+		sessionStore storage.SessionStore
+		reminders    storage.ReminderStore
+		webhooks     storage.WebhookStore
+		calendars    storage.CalendarStore
+		changes      storage.ChangeLog
+		closeDb      func() error
+	)
+	switch cfg.Storage {
+	case config.StorageMemory:
+		memStore := storage.NewEventStorage()
+		store, reminders, calendars, changes = memStore, memStore, memStore, memStore
+		userStore = storage.NewUserStorage()
+		sessionStore = storage.NewSessionStorage()
+		webhooks = storage.NewWebhookStorage()
+	case config.StorageMySQL:
+		dbStore, err := storage.NewDbStorage(cfg.DB.DSN, cfg.DB.MaxIdleConns, cfg.DB.MaxOpenConns)
+		if err != nil {
+			log.Fatal("can't connect to db: ", err)
+		}
+		store, reminders, calendars, changes = dbStore, dbStore, dbStore, dbStore
+		userStore = storage.NewDbUserStorage(dbStore)
+		sessionStore = storage.NewDbSessionStorage(dbStore)
+		webhooks = storage.NewDbWebhookStorage(dbStore)
+		closeDb = dbStore.Close
+	}
+	log.Printf("using %s storage", cfg.Storage)
+
+	notifier, err := newNotifier(cfg.Reminders)
 	if err != nil {
-		log.Fatal("can't connect to db: ", err)
+		log.Fatal(err)
+	}
+	scheduler := reminder.NewScheduler(store, reminders, userStore, notifier)
+	scheduler.Interval = cfg.Reminders.Interval
+
+	dispatcher := webhook.NewDispatcher(webhooks)
+	broker := stream.NewBroker(changes)
+
+	eventServer := server.NewEventServer(store, userStore, sessionStore, reminders, calendars, webhooks, dispatcher, broker)
+	metricServer := server.NewMetricsServer(store, userStore)
+
+	// shutdown steps run in order: servers stop taking requests, then workers, then db pool is closed
+	eventHTTPServer := &http.Server{Addr: cfg.Server.Addr, Handler: eventServer}
+	// open streams never finish by themselves, so they are closed when shutdown starts
+	eventHTTPServer.RegisterOnShutdown(broker.Close)
+	lc.Serve("events server", eventHTTPServer, cfg.Shutdown.HTTPTimeout)
+	lc.Serve("metrics server", &http.Server{Addr: cfg.Server.MetricsAddr, Handler: metricServer}, cfg.Shutdown.HTTPTimeout)
+	lc.Worker("reminder scheduler", cfg.Shutdown.WorkersTimeout, scheduler.Run)
+	lc.Worker("webhook dispatcher", cfg.Shutdown.WorkersTimeout, dispatcher.Run)
+	lc.Worker("change stream", cfg.Shutdown.WorkersTimeout, broker.Run)
+	if closeDb != nil {
+		lc.OnStop("db connections", cfg.Shutdown.DBTimeout, func(ctx context.Context) error {
+			return closeDb()
+		})
+	}
+
+	if err := lc.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("stopped")
+}
+
+// newNotifier return channel of reminders chosen in config
+func newNotifier(cfg config.RemindersConfig) (reminder.Notifier, error) {
+	switch cfg.Notifier {
+	case config.NotifierSMTP:
+		var auth smtp.Auth
+		if cfg.SMTP.Username != "" {
+			host, _, err := net.SplitHostPort(cfg.SMTP.Addr)
+			if err != nil {
+				return nil, err
+			}
+			auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, host)
+		}
+		return reminder.SMTPNotifier{Addr: cfg.SMTP.Addr, From: cfg.SMTP.From, Auth: auth}, nil
+	case config.NotifierWebhook:
+		return reminder.WebhookNotifier{URL: cfg.WebhookURL, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	default:
+		return reminder.LogNotifier{}, nil
 	}
-	eventServer := server.NewEventServer(store)
-	metricServer := server.NewMetricsServer(store)
-	go func() {
-		log.Fatal(http.ListenAndServe(":5000", eventServer))
-	}()
-	log.Fatal(http.ListenAndServe(":5050", metricServer))
 }
@@ -0,0 +1,137 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notification is reminder about occurrence of event for its organizer and attendees who didn't decline
+type Notification struct {
+	// ID is id of trigger, it is the same when delivery is repeated, so receivers can drop duplicates
+	ID          uuid.UUID   `json:"id"`
+	EventId     uuid.UUID   `json:"eventId"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Start       time.Time   `json:"start"`
+	End         time.Time   `json:"end"`
+	Timezone    string      `json:"timezone"`
+	Recipients  []Recipient `json:"recipients"`
+}
+
+// Recipient is registered user or external attendee, who has only email
+type Recipient struct {
+	Login string `json:"login,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Notifier delivers reminders, error means that delivery must be repeated later
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier write reminders to log, it is useful for development
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+// Notify write reminder to log
+func (ln LogNotifier) Notify(ctx context.Context, n Notification) error {
+	logger := ln.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logins := make([]string, 0, len(n.Recipients))
+	for _, r := range n.Recipients {
+		if r.Login != "" {
+			logins = append(logins, r.Login)
+		} else {
+			logins = append(logins, r.Email)
+		}
+	}
+	logger.Printf("reminder %s: %q starts at %s for %s", n.ID, n.Title, n.Start.Format(time.RFC3339), strings.Join(logins, ", "))
+	return nil
+}
+
+// headerReplacer keep user input from adding mail headers
+var headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+// SMTPNotifier send reminders by email to recipients who have email
+type SMTPNotifier struct {
+	// Addr is host:port of SMTP server
+	Addr string
+	From string
+	// Auth is nil for server without authentication
+	Auth smtp.Auth
+}
+
+// Notify send one email to all recipients, Message-ID is made of trigger id
+func (sn SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	to := make([]string, 0, len(n.Recipients))
+	for _, r := range n.Recipients {
+		if r.Email != "" {
+			to = append(to, r.Email)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", sn.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+headerReplacer.Replace(n.Title)))
+	fmt.Fprintf(&msg, "Message-ID: <%s@calendar>\r\n", n.ID)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	loc, err := time.LoadLocation(n.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	fmt.Fprintf(&msg, "%s\r\n%s - %s (%s)\r\n", n.Title, n.Start.In(loc).Format("Mon, 02 Jan 2006 15:04"), n.End.In(loc).Format("15:04"), loc)
+	if n.Description != "" {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", n.Description)
+	}
+	return smtp.SendMail(sn.Addr, sn.Auth, sn.From, to, msg.Bytes())
+}
+
+// WebhookNotifier post reminders as JSON to URL, response other than 2xx is failure.
+// Idempotency-Key header is id of notification
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify post reminder to webhook
+func (wn WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", n.ID.String())
+	client := wn.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
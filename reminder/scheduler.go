@@ -0,0 +1,193 @@
+package reminder
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	defaultInterval    = 30 * time.Second
+	defaultLease       = 2 * time.Minute
+	defaultMaxAttempts = 5
+	defaultBatchSize   = 100
+	// retryDelay is delay after first failed attempt, it doubles with every next attempt
+	retryDelay   = time.Minute
+	maxErrorSize = 256
+)
+
+// Scheduler delivers due reminders through Notifier.
+// Trigger is claimed, delivered and only then marked as sent, so reminder is delivered at least once:
+// when server stops between delivery and update, trigger is delivered again after lease is over
+// with the same Notification.ID
+type Scheduler struct {
+	Events    storage.EventStore
+	Reminders storage.ReminderStore
+	Users     storage.UserStore
+	Notifier  Notifier
+	// Interval is time between checks of due triggers
+	Interval time.Duration
+	// Lease is time trigger is locked for one delivery attempt
+	Lease       time.Duration
+	MaxAttempts int
+	BatchSize   int
+}
+
+// NewScheduler return scheduler with default settings
+func NewScheduler(events storage.EventStore, reminders storage.ReminderStore, users storage.UserStore, notifier Notifier) *Scheduler {
+	return &Scheduler{
+		Events:      events,
+		Reminders:   reminders,
+		Users:       users,
+		Notifier:    notifier,
+		Interval:    defaultInterval,
+		Lease:       defaultLease,
+		MaxAttempts: defaultMaxAttempts,
+		BatchSize:   defaultBatchSize,
+	}
+}
+
+// Run deliver due triggers every Interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Println("reminders: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick deliver triggers due at now. Claimed triggers left after cancel of ctx are delivered after their lease
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) error {
+	triggers, err := s.Reminders.ClaimTriggers(ctx, now, s.Lease, s.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, t := range triggers {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err = s.deliver(ctx, t, now); err != nil {
+			log.Printf("reminders: trigger %s: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// deliver check trigger against current state of event, notify recipients and save result
+func (s *Scheduler) deliver(ctx context.Context, t event.Trigger, now time.Time) error {
+	// events are read in their own timezone and without user, so any event can be found
+	evCtx := context.WithValue(ctx, "timezone", nil)
+	exist, err := s.Events.IsExist(evCtx, t.EventId)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return s.finish(t, event.TriggerCancelled, "event is deleted", nil)
+	}
+	ev, err := s.Events.GetEventById(evCtx, t.EventId)
+	if err != nil {
+		return err
+	}
+	r := ev.Reminder(t.ReminderId)
+	if r == nil {
+		return s.finish(t, event.TriggerCancelled, "reminder is removed", nil)
+	}
+	// event could be changed after trigger was created, then save of event has created new trigger
+	occ, fireAt, ok, err := ev.NextReminder(*r, t.FireAt.Add(-time.Second))
+	if err != nil {
+		return err
+	}
+	if !ok || !occ.DateTime.Equal(t.Start) || !fireAt.Equal(t.FireAt) {
+		return s.finish(t, event.TriggerCancelled, "event is changed", nil)
+	}
+	next, err := ev.NextTrigger(*r, t.FireAt)
+	if errors.Is(err, event.ErrNoTrigger) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	var nextTrigger *event.Trigger
+	if next.ID != uuid.Nil {
+		nextTrigger = &next
+	}
+	// reminders which are late after downtime are still sent, unless occurrence is over
+	if now.After(occ.DateTime.Add(occ.Duration).Add(s.Interval)) {
+		return s.finish(t, event.TriggerCancelled, "occurrence is over", nextTrigger)
+	}
+
+	n, err := s.notification(ctx, t, &ev, &occ)
+	if err != nil {
+		return err
+	}
+	err = s.Notifier.Notify(ctx, n)
+	if ctx.Err() != nil {
+		// delivery is interrupted by shutdown, trigger is delivered again after lease
+		return ctx.Err()
+	}
+	t.Attempts++
+	if err != nil {
+		if t.Attempts >= s.MaxAttempts {
+			return s.finish(t, event.TriggerFailed, err.Error(), nextTrigger)
+		}
+		retryAt := now.Add(retryDelay << (t.Attempts - 1))
+		t.LockedUntil = &retryAt
+		t.LastError = truncate(err.Error())
+		return s.Reminders.UpdateTrigger(context.Background(), t, nil)
+	}
+	t.SentAt = &now
+	return s.finish(t, event.TriggerSent, "", nextTrigger)
+}
+
+// finish save final status of trigger, it is saved even when ctx is cancelled because notification may be already sent
+func (s *Scheduler) finish(t event.Trigger, status event.TriggerStatus, reason string, next *event.Trigger) error {
+	t.Status = status
+	t.LastError = truncate(reason)
+	t.LockedUntil = nil
+	return s.Reminders.UpdateTrigger(context.Background(), t, next)
+}
+
+// notification collect organizer and attendees who didn't decline the event
+func (s *Scheduler) notification(ctx context.Context, t event.Trigger, ev *event.Event, occ *event.Event) (Notification, error) {
+	n := Notification{
+		ID:          t.ID,
+		EventId:     ev.ID,
+		Title:       occ.Title,
+		Description: occ.Description,
+		Start:       occ.DateTime,
+		End:         occ.DateTime.Add(occ.Duration),
+		Timezone:    occ.Timezone,
+		Recipients:  make([]Recipient, 0, len(ev.Attendees)+1),
+	}
+	organizer, err := s.Users.GetUserById(ctx, ev.UserId)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return n, err
+	}
+	if err == nil {
+		n.Recipients = append(n.Recipients, Recipient{organizer.Login, organizer.Email})
+	}
+	for _, a := range ev.Attendees {
+		if a.Status != event.Declined && a.UserId != ev.UserId {
+			n.Recipients = append(n.Recipients, Recipient{a.Login, a.Email})
+		}
+	}
+	return n, nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorSize {
+		return s[:maxErrorSize]
+	}
+	return s
+}
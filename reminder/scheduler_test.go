@@ -0,0 +1,73 @@
+package reminder
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	sent []Notification
+	err  error
+}
+
+func (rn *recordingNotifier) Notify(ctx context.Context, n Notification) error {
+	if rn.err != nil {
+		return rn.err
+	}
+	rn.sent = append(rn.sent, n)
+	return nil
+}
+
+func TestSchedulerDeliversAndSchedulesNext(t *testing.T) {
+	ctx := context.Background()
+	events := storage.NewEventStorage()
+	users := storage.NewUserStorage()
+	organizer, err := users.Save(ctx, user.User{ID: uuid.New(), Login: "organizer", Email: "organizer@example.com", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(time.Hour).Truncate(time.Minute).UTC()
+	ev := event.Event{ID: uuid.New(), Title: "Standup", DateTime: start, Timezone: "UTC", Duration: 15 * time.Minute,
+		RRule: "FREQ=DAILY;COUNT=2", UserId: organizer.ID}
+	ev.Reminders = []event.Reminder{{ID: uuid.New(), EventId: ev.ID, Before: 10 * time.Minute}}
+	if _, err = events.Save(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{err: errors.New("unavailable")}
+	s := NewScheduler(events, events, users, notifier)
+	fireAt := start.Add(-10 * time.Minute)
+	if err = s.Tick(ctx, fireAt.Add(-time.Minute)); err != nil || len(notifier.sent) != 0 {
+		t.Fatalf("reminder is sent before time, err %v", err)
+	}
+	if err = s.Tick(ctx, fireAt); err != nil {
+		t.Fatal(err)
+	}
+	triggers, _ := events.GetTriggers(ctx, ev.ID)
+	if len(triggers) != 1 || triggers[0].Status != event.TriggerPending || triggers[0].Attempts != 1 {
+		t.Fatalf("failed delivery is not kept for retry: %+v", triggers)
+	}
+
+	notifier.err = nil
+	if err = s.Tick(ctx, fireAt.Add(retryDelay+time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Recipients[0].Login != "organizer" || !notifier.sent[0].Start.Equal(start) {
+		t.Fatalf("notifications = %+v", notifier.sent)
+	}
+	// repeated tick doesn't send the same reminder again
+	if err = s.Tick(ctx, fireAt.Add(retryDelay+time.Second)); err != nil || len(notifier.sent) != 1 {
+		t.Fatalf("reminder is sent twice, err %v", err)
+	}
+	triggers, _ = events.GetTriggers(ctx, ev.ID)
+	if len(triggers) != 2 || triggers[1].Status != event.TriggerSent || triggers[0].Status != event.TriggerPending ||
+		!triggers[0].Start.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("triggers after delivery = %+v", triggers)
+	}
+}
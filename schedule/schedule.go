@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"calendar/event"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	hoursForm = "15:04"
+	// maxOutside is time outside working hours after which slot has no comfort for attendee
+	maxOutside = 3 * time.Hour
+	// nightStart and nightEnd are local hours which are never comfortable
+	nightStart = 22
+	nightEnd   = 7
+)
+
+var ErrInvalidWorkHours = errors.New("working hours must be HH:MM and start before end")
+
+// DefaultWorkHours is used for attendees who have no working hours in profile
+var DefaultWorkHours = WorkHours{9 * time.Hour, 18 * time.Hour}
+
+// WorkHours is daily working time from Monday to Friday, as offsets from local midnight
+type WorkHours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWorkHours parse working hours like "09:00" and "18:00", empty values mean DefaultWorkHours
+func ParseWorkHours(start, end string) (WorkHours, error) {
+	if start == "" && end == "" {
+		return DefaultWorkHours, nil
+	}
+	s, err := time.Parse(hoursForm, start)
+	if err != nil {
+		return WorkHours{}, ErrInvalidWorkHours
+	}
+	e, err := time.Parse(hoursForm, end)
+	if err != nil || !s.Before(e) {
+		return WorkHours{}, ErrInvalidWorkHours
+	}
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return WorkHours{s.Sub(midnight), e.Sub(midnight)}, nil
+}
+
+// Attendee is person whose busy time and local time are considered
+type Attendee struct {
+	Login     string
+	Location  *time.Location
+	WorkHours WorkHours
+	// Busy are sorted merged intervals, like result of event.Busy
+	Busy []event.Interval
+}
+
+// Request is search of meeting time
+type Request struct {
+	Attendees []Attendee
+	Duration  time.Duration
+	From      time.Time
+	To        time.Time
+	// Step is distance between starts of candidate slots
+	Step  time.Duration
+	Limit int
+}
+
+// SlotAttendee tells how slot suits one attendee
+type SlotAttendee struct {
+	Login       string    `json:"login"`
+	Free        bool      `json:"free"`
+	LocalStart  time.Time `json:"localStart"`
+	InWorkHours bool      `json:"inWorkHours"`
+}
+
+// Slot is candidate meeting time
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Free is number of attendees who have no events in slot
+	Free int `json:"free"`
+	// Comfort is from 0 to 1, average of how sensible local time of slot is for attendees
+	Comfort   float64        `json:"comfort"`
+	Attendees []SlotAttendee `json:"attendees"`
+}
+
+// Suggest return slots ranked by number of free attendees, then by comfort of their local time, then by start.
+// Slots don't overlap each other and at least one attendee is free in every slot
+func Suggest(req Request) []Slot {
+	if req.Duration <= 0 || req.Step <= 0 || len(req.Attendees) == 0 {
+		return []Slot{}
+	}
+	var candidates []Slot
+	for start := req.From; !start.Add(req.Duration).After(req.To); start = start.Add(req.Step) {
+		slot := evaluate(req.Attendees, start, start.Add(req.Duration))
+		if slot.Free > 0 {
+			candidates = append(candidates, slot)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Free != b.Free {
+			return a.Free > b.Free
+		}
+		if a.Comfort != b.Comfort {
+			return a.Comfort > b.Comfort
+		}
+		return a.Start.Before(b.Start)
+	})
+
+	slots := make([]Slot, 0, req.Limit)
+	for _, c := range candidates {
+		if len(slots) == req.Limit {
+			break
+		}
+		overlaps := false
+		for _, s := range slots {
+			if c.Start.Before(s.End) && s.Start.Before(c.End) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			slots = append(slots, c)
+		}
+	}
+	return slots
+}
+
+func evaluate(attendees []Attendee, start, end time.Time) Slot {
+	slot := Slot{Start: start, End: end, Attendees: make([]SlotAttendee, 0, len(attendees))}
+	for _, a := range attendees {
+		free := isFree(a.Busy, start, end)
+		c := comfort(a, start, end)
+		if free {
+			slot.Free++
+		}
+		slot.Comfort += c
+		slot.Attendees = append(slot.Attendees, SlotAttendee{a.Login, free, start.In(a.Location), c == 1})
+	}
+	slot.Comfort /= float64(len(attendees))
+	return slot
+}
+
+// isFree check that no busy interval overlaps [start, end)
+func isFree(busy []event.Interval, start, end time.Time) bool {
+	i := sort.Search(len(busy), func(i int) bool { return busy[i].End.After(start) })
+	return i == len(busy) || !busy[i].Start.Before(end)
+}
+
+// comfort is 1 inside working hours on weekday, it goes down to 0 with time outside working hours.
+// Nights are never comfortable and weekends are less comfortable than any weekday time
+func comfort(a Attendee, start, end time.Time) float64 {
+	ls, le := start.In(a.Location), end.In(a.Location)
+	// end of slot is not part of it, so slot till 22:00 is not at night
+	if atNight(ls) || atNight(le.Add(-time.Nanosecond)) {
+		return 0
+	}
+	day := midnight(ls)
+	workStart, workEnd := day.Add(a.WorkHours.Start), day.Add(a.WorkHours.End)
+	var outside time.Duration
+	if ls.Before(workStart) {
+		outside += workStart.Sub(ls)
+	}
+	if le.After(workEnd) {
+		outside += le.Sub(workEnd)
+	}
+	c := 1.0
+	if outside > 0 {
+		if outside > maxOutside {
+			outside = maxOutside
+		}
+		c = 0.5 * (1 - float64(outside)/float64(maxOutside))
+	}
+	if ls.Weekday() == time.Saturday || ls.Weekday() == time.Sunday {
+		c *= 0.3
+	}
+	return c
+}
+
+func atNight(t time.Time) bool {
+	return t.Hour() >= nightStart || t.Hour() < nightEnd
+}
+
+// midnight return start of local day of t
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
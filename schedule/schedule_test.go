@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"calendar/event"
+	"testing"
+	"time"
+)
+
+func TestParseWorkHours(t *testing.T) {
+	wh, err := ParseWorkHours("08:30", "17:00")
+	if err != nil || wh.Start != 8*time.Hour+30*time.Minute || wh.End != 17*time.Hour {
+		t.Errorf("ParseWorkHours() = %v, %v", wh, err)
+	}
+	if wh, err = ParseWorkHours("", ""); err != nil || wh != DefaultWorkHours {
+		t.Errorf("empty working hours = %v, %v, want default", wh, err)
+	}
+	for _, bad := range [][2]string{{"18:00", "09:00"}, {"9", "18:00"}, {"09:00", ""}} {
+		if _, err = ParseWorkHours(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseWorkHours(%q, %q) is not an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	riga, _ := time.LoadLocation("Europe/Riga")
+	ny, _ := time.LoadLocation("America/New_York")
+	// Monday, Riga is UTC+3 and New York is UTC-4
+	day := time.Date(2021, time.August, 2, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+
+	req := Request{
+		Attendees: []Attendee{
+			{Login: "riga", Location: riga, WorkHours: DefaultWorkHours, Busy: []event.Interval{{Start: at(13), End: at(14)}}},
+			{Login: "ny", Location: ny, WorkHours: DefaultWorkHours},
+		},
+		Duration: time.Hour,
+		From:     at(0),
+		To:       at(24),
+		Step:     30 * time.Minute,
+		Limit:    3,
+	}
+	slots := Suggest(req)
+	if len(slots) != 3 {
+		t.Fatalf("got %d slots, want 3", len(slots))
+	}
+	// working hours overlap only from 13:00 to 15:00 UTC, riga is busy from 13:00 to 14:00
+	best := slots[0]
+	if !best.Start.Equal(at(14)) || best.Free != 2 || best.Comfort != 1 {
+		t.Errorf("best slot = %+v, want 14:00 UTC for both attendees in working hours", best)
+	}
+	for _, s := range slots {
+		if s.Free != 2 {
+			t.Errorf("slot %v has %d free attendees, want 2", s.Start, s.Free)
+		}
+		for _, o := range slots {
+			if s.Start != o.Start && s.Start.Before(o.End) && o.Start.Before(s.End) {
+				t.Errorf("slots %v and %v overlap", s.Start, o.Start)
+			}
+		}
+	}
+	if slots[1].Comfort >= best.Comfort {
+		t.Errorf("second slot comfort %v is not lower than best %v", slots[1].Comfort, best.Comfort)
+	}
+}
+
+func TestComfort(t *testing.T) {
+	a := Attendee{Location: time.UTC, WorkHours: DefaultWorkHours}
+	monday := time.Date(2021, time.August, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		start time.Time
+		want  float64
+	}{
+		{"working hours", monday.Add(10 * time.Hour), 1},
+		{"ends at night start", monday.Add(21 * time.Hour), 0},
+		{"night", monday.Add(23 * time.Hour), 0},
+		{"hour after work", monday.Add(18 * time.Hour), 0.5 * (1 - 1.0/3)},
+		{"saturday", monday.AddDate(0, 0, 5).Add(10 * time.Hour), 0.3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := comfort(a, c.start, c.start.Add(time.Hour))
+			if got < c.want-1e-9 || got > c.want+1e-9 {
+				t.Errorf("comfort() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"calendar/storage"
+	"errors"
+	"net/http"
+)
+
+// writeStoreError write error of event store: missing event is 404, event without access of user is 403,
+// event changed meanwhile is 412
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrStaleVersion):
+		writeStaleVersion(w)
+	case errors.Is(err, storage.ErrEventNotFound):
+		writeProblem(w, http.StatusNotFound, "Event not found")
+	case errors.Is(err, storage.ErrForbidden):
+		writeProblem(w, http.StatusForbidden, "No access to event")
+	default:
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+	}
+}
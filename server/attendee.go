@@ -0,0 +1,160 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/webhook"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"net/mail"
+)
+
+// invitation is request of organizer to invite registered user by login or external email
+type invitation struct {
+	Login string `json:"login,omitempty"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
+
+type rsvp struct {
+	Status string `json:"status"`
+}
+
+// ServeAttendees list attendees of event (GET), invite people (POST) and remove attendee (DELETE .../attendees/{attendeeId}).
+// Only organizer can change attendees, invited users and users with read access to calendar can see them
+func (es *EventServer) ServeAttendees(w http.ResponseWriter, r *http.Request, eventId uuid.UUID) {
+	ctx := seriesContext(r.Context())
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+	ev, err := es.Store.GetEventById(ctx, eventId)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	access, err := storage.AccessTo(ctx, es.Calendars, &ev)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	organizer := ev.UserId == userId
+	if !organizer && (!readOnly(r) || !access.Allows(event.AccessRead)) {
+		writeProblem(w, http.StatusForbidden, "Only organizer can change attendees")
+		return
+	}
+	if !readOnly(r) && !matchVersion(r, ev.Version) {
+		writeStaleVersion(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var invitations []invitation
+		err = json.NewDecoder(r.Body).Decode(&invitations)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong entity")
+			return
+		}
+		for _, inv := range invitations {
+			attendee, err := es.newAttendee(r, inv)
+			if err != nil {
+				writeProblem(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			ev.Invite(attendee)
+		}
+		if ev, err = es.Store.Save(ctx, ev); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		es.publish(ctx, webhook.EventUpdated, &ev)
+	case http.MethodDelete:
+		id, err := uuid.Parse(pathParam(r, "attendeeId"))
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong attendee id")
+			return
+		}
+		if !ev.Uninvite(id) {
+			writeProblem(w, http.StatusNotFound, "Attendee not found")
+			return
+		}
+		if ev, err = es.Store.Save(ctx, ev); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		es.publish(ctx, webhook.EventUpdated, &ev)
+		w.Header().Set("ETag", versionETag(ev.Version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	attendees := ev.Attendees
+	if attendees == nil {
+		attendees = []event.Attendee{}
+	}
+	w.Header().Set("content-type", jsonContentType)
+	w.Header().Set("ETag", versionETag(ev.Version))
+	err = json.NewEncoder(w).Encode(attendees)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// newAttendee find invited user by login or check external email
+func (es *EventServer) newAttendee(r *http.Request, inv invitation) (event.Attendee, error) {
+	role, err := event.ParseRole(inv.Role)
+	if err != nil {
+		return event.Attendee{}, err
+	}
+	if inv.Login != "" {
+		u, err := es.UserStore.GetUserByLogin(r.Context(), inv.Login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return event.Attendee{}, errors.New("unknown login " + inv.Login)
+		}
+		if err != nil {
+			return event.Attendee{}, err
+		}
+		return event.Attendee{UserId: u.ID, Login: u.Login, Email: u.Email, Role: role}, nil
+	}
+	addr, err := mail.ParseAddress(inv.Email)
+	if err != nil || addr.Address != inv.Email {
+		return event.Attendee{}, errors.New("wrong email " + inv.Email)
+	}
+	return event.Attendee{Email: inv.Email, Role: role}, nil
+}
+
+// Respond set participation status of invited user (PUT /api/event/{id}/rsvp)
+func (es *EventServer) Respond(w http.ResponseWriter, r *http.Request, eventId uuid.UUID) {
+	var answer rsvp
+	err := json.NewDecoder(r.Body).Decode(&answer)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	status, err := event.ParsePartStat(answer.Status)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	// attendee can't edit event, only own answer is changed
+	attendee, err := es.Store.Respond(r.Context(), eventId, userId, status)
+	if errors.Is(err, event.ErrNotAttendee) {
+		writeProblem(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	// organizer's webhooks get event with the new answer
+	if ev, err := es.Store.GetEventById(r.Context(), eventId); err == nil {
+		es.publish(r.Context(), webhook.EventUpdated, &ev)
+	}
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(attendee)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,443 @@
+package server
+
+import (
+	"bytes"
+	"calendar/caldav"
+	"calendar/event"
+	"calendar/ical"
+	"calendar/storage"
+	"calendar/user"
+	"calendar/webhook"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	davPrefix = "/dav/"
+	// davCalendar is name of the only calendar collection of user
+	davCalendar = "events"
+
+	davPrivileges = "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>" +
+		"<D:privilege><D:write-content/></D:privilege><D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"
+	davReports = "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+		"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"
+)
+
+// WellKnownCalDAV redirect CalDAV clients to the service root (RFC 6764)
+func (es *EventServer) WellKnownCalDAV(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, davPrefix, http.StatusMovedPermanently)
+}
+
+// ServeDAV serve events of user as CalDAV calendar collection /dav/{login}/events/
+// with one {id}.ics resource for every event
+func (es *EventServer) ServeDAV(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		return
+	}
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	userEntity, err := es.UserStore.GetUserById(r.Context(), userId)
+	if err != nil {
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, davPrefix), "/")
+	segments := strings.Split(path, "/")
+	switch {
+	case path == "":
+		es.davRoot(w, r, userEntity)
+	case segments[0] != userEntity.Login:
+		writeProblem(w, http.StatusForbidden, "Forbidden")
+	case len(segments) == 1:
+		es.davPrincipal(w, r, userEntity)
+	case len(segments) == 2 && segments[1] == davCalendar:
+		es.davCollection(w, r, userEntity)
+	case len(segments) == 3 && segments[1] == davCalendar && strings.HasSuffix(segments[2], ".ics"):
+		es.davItem(w, r, userEntity, segments[2])
+	default:
+		writeProblem(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (es *EventServer) davRoot(w http.ResponseWriter, r *http.Request, u user.User) {
+	if r.Method != "PROPFIND" {
+		writeProblem(w, http.StatusMethodNotAllowed, "Wrong method type")
+		return
+	}
+	pf, err := caldav.ParsePropfind(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong body")
+		return
+	}
+	var ms caldav.Multistatus
+	ms.Add(davPrefix, caldav.Props{
+		caldav.ResourceType:         "<D:collection/>",
+		caldav.CurrentUserPrincipal: caldav.Href(principalHref(u)),
+	}, pf.Props)
+	_ = ms.WriteTo(w)
+}
+
+func (es *EventServer) davPrincipal(w http.ResponseWriter, r *http.Request, u user.User) {
+	if r.Method != "PROPFIND" {
+		writeProblem(w, http.StatusMethodNotAllowed, "Wrong method type")
+		return
+	}
+	pf, err := caldav.ParsePropfind(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong body")
+		return
+	}
+	var ms caldav.Multistatus
+	ms.Add(principalHref(u), principalProps(u), pf.Props)
+	if r.Header.Get("Depth") != "0" {
+		ctag, err := es.davCTag(r.Context())
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		ms.Add(collectionHref(u), collectionProps(u, ctag), pf.Props)
+	}
+	_ = ms.WriteTo(w)
+}
+
+func (es *EventServer) davCollection(w http.ResponseWriter, r *http.Request, u user.User) {
+	switch r.Method {
+	case "PROPFIND":
+		pf, err := caldav.ParsePropfind(r.Body)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong body")
+			return
+		}
+		evs, err := es.davEvents(r.Context())
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		var ms caldav.Multistatus
+		ms.Add(collectionHref(u), collectionProps(u, collectionTag(evs)), pf.Props)
+		if r.Header.Get("Depth") != "0" {
+			for i := range evs {
+				ms.Add(itemHref(u, &evs[i]), itemProps(&evs[i], pf.Props), pf.Props)
+			}
+		}
+		_ = ms.WriteTo(w)
+	case "REPORT":
+		report, err := caldav.ParseReport(r.Body)
+		var davErr *caldav.Error
+		if errors.As(err, &davErr) {
+			caldav.WriteError(w, davErr)
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong body")
+			return
+		}
+		es.davReport(w, r, u, report)
+	default:
+		writeProblem(w, http.StatusMethodNotAllowed, "Wrong method type")
+	}
+}
+
+func (es *EventServer) davReport(w http.ResponseWriter, r *http.Request, u user.User, report caldav.Report) {
+	var ms caldav.Multistatus
+	if report.Multiget {
+		for _, href := range report.Hrefs {
+			// href is escaped like in itemHref, path of it is unescaped
+			ref, err := url.Parse(href)
+			if err != nil {
+				ms.AddStatus(href, http.StatusNotFound)
+				continue
+			}
+			name := ref.Path[strings.LastIndex(ref.Path, "/")+1:]
+			ev, ok, err := es.davEvent(r.Context(), ical.EventId(u.ID, strings.TrimSuffix(name, ".ics")))
+			if err != nil {
+				writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+				return
+			}
+			if !ok {
+				ms.AddStatus(href, http.StatusNotFound)
+				continue
+			}
+			ms.Add(href, itemProps(&ev, report.Props), report.Props)
+		}
+		_ = ms.WriteTo(w)
+		return
+	}
+
+	evs, err := es.davEvents(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	for i := range evs {
+		if overlaps(&evs[i], report.Start, report.End) {
+			ms.Add(itemHref(u, &evs[i]), itemProps(&evs[i], report.Props), report.Props)
+		}
+	}
+	_ = ms.WriteTo(w)
+}
+
+// davItem serve event resource, name of resource is its id or name given by client when it created the event
+func (es *EventServer) davItem(w http.ResponseWriter, r *http.Request, u user.User, name string) {
+	id := ical.EventId(u.ID, strings.TrimSuffix(name, ".ics"))
+	ctx := seriesContext(r.Context())
+	old, exist, err := es.davEvent(ctx, id)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	etag := ""
+	if exist {
+		etag = eventETag(&old)
+	}
+	if match := r.Header.Get("If-Match"); match != "" && (!exist || (match != "*" && match != etag)) {
+		writeProblem(w, http.StatusPreconditionFailed, "Precondition failed")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exist {
+			writeProblem(w, http.StatusNotFound, "Not found")
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("content-type", ical.ContentType)
+		if r.Method == http.MethodGet {
+			_ = ical.NewEncoder(w).Encode([]event.Event{old})
+		}
+	case http.MethodPut:
+		if exist && r.Header.Get("If-None-Match") == "*" {
+			writeProblem(w, http.StatusPreconditionFailed, "Precondition failed")
+			return
+		}
+		if !exist {
+			// the id can be taken by event of another user which user can see, Save refuses the others
+			if taken, err := es.Store.IsExist(ctx, id); err != nil || taken {
+				writeProblem(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+		}
+		decoder := ical.NewDecoder(r.Body)
+		decoder.UserId = u.ID
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			decoder.Location = loc
+		}
+		evs, entryErrors, err := decoder.Decode()
+		if err != nil || len(entryErrors) > 0 || len(evs) != 1 {
+			caldav.WriteError(w, caldav.ErrInvalidCalendarData)
+			return
+		}
+		// resource name is the id of event, so href of resource is stable
+		ev := evs[0]
+		ev.ID = id
+		for i := range ev.Overrides {
+			ev.Overrides[i].EventId = id
+		}
+		ev.UserId = u.ID
+		ev.ResourceName = name
+		if exist {
+			ev.Notes = old.Notes
+			ev.Attendees = old.Attendees
+			ev.Reminders = old.Reminders
+			ev.Version = old.Version
+		}
+		saved, err := es.Store.Save(ctx, ev)
+		if errors.Is(err, storage.ErrStaleVersion) {
+			writeProblem(w, http.StatusPreconditionFailed, "Precondition failed")
+			return
+		}
+		// event of other user which user can't see is not found
+		if errors.Is(err, storage.ErrForbidden) || errors.Is(err, storage.ErrEventNotFound) {
+			writeProblem(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		w.Header().Set("ETag", eventETag(&saved))
+		if exist {
+			es.publish(ctx, webhook.EventUpdated, &saved)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			es.publish(ctx, webhook.EventCreated, &saved)
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		if !exist {
+			writeProblem(w, http.StatusNotFound, "Not found")
+			return
+		}
+		err = es.Store.Delete(ctx, id, old.Version)
+		if errors.Is(err, storage.ErrStaleVersion) {
+			writeProblem(w, http.StatusPreconditionFailed, "Precondition failed")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		es.publish(ctx, webhook.EventDeleted, &old)
+		w.WriteHeader(http.StatusNoContent)
+	case "PROPFIND":
+		if !exist {
+			writeProblem(w, http.StatusNotFound, "Not found")
+			return
+		}
+		pf, err := caldav.ParsePropfind(r.Body)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong body")
+			return
+		}
+		var ms caldav.Multistatus
+		ms.Add(itemHref(u, &old), itemProps(&old, pf.Props), pf.Props)
+		_ = ms.WriteTo(w)
+	default:
+		writeProblem(w, http.StatusMethodNotAllowed, "Wrong method type")
+	}
+}
+
+// davEvent return event of logged in user in its own timezone
+func (es *EventServer) davEvent(ctx context.Context, id uuid.UUID) (event.Event, bool, error) {
+	ev, err := es.Store.GetEventById(seriesContext(ctx), id)
+	if errors.Is(err, storage.ErrEventNotFound) || errors.Is(err, storage.ErrForbidden) {
+		return event.Event{}, false, nil
+	}
+	if err != nil {
+		return ev, false, err
+	}
+	if userId, _ := ctx.Value("user_id").(uuid.UUID); ev.UserId != userId {
+		return event.Event{}, false, nil
+	}
+	return ev, true, nil
+}
+
+// davEvents return all events and series of logged in user in their own timezones, invitations are not
+// in collection because only owner can read them by davEvent
+func (es *EventServer) davEvents(ctx context.Context) ([]event.Event, error) {
+	found, err := es.Store.GetEvents(seriesContext(ctx), event.EventFilter{Series: true})
+	if err != nil {
+		return nil, err
+	}
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+	evs := found[:0]
+	for _, ev := range found {
+		if ev.UserId == userId {
+			evs = append(evs, ev)
+		}
+	}
+	sort.Slice(evs, func(i, j int) bool { return evs[i].ID.String() < evs[j].ID.String() })
+	return evs, nil
+}
+
+func (es *EventServer) davCTag(ctx context.Context) (string, error) {
+	evs, err := es.davEvents(ctx)
+	if err != nil {
+		return "", err
+	}
+	return collectionTag(evs), nil
+}
+
+// overlaps check if any occurrence of event is inside of [start, end)
+func overlaps(ev *event.Event, start, end time.Time) bool {
+	from := start
+	if !from.IsZero() {
+		from = from.Add(-ev.Duration)
+	}
+	occurrences, err := ev.Occurrences(from, end)
+	if err != nil {
+		return false
+	}
+	for _, occ := range occurrences {
+		occEnd := occ.DateTime.Add(occ.Duration)
+		if (end.IsZero() || occ.DateTime.Before(end)) && (start.IsZero() || occEnd.After(start) || (occ.Duration == 0 && !occ.DateTime.Before(start))) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventETag is version of event, the same as ETag of event in REST API, it changes with every change of event or its occurrences
+func eventETag(ev *event.Event) string {
+	return versionETag(ev.Version)
+}
+
+// collectionTag is hash of etags of all events sorted by id
+func collectionTag(evs []event.Event) string {
+	h := sha1.New()
+	for i := range evs {
+		h.Write([]byte(evs[i].ID.String() + eventETag(&evs[i])))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+func principalHref(u user.User) string {
+	return davPrefix + u.Login + "/"
+}
+
+func collectionHref(u user.User) string {
+	return principalHref(u) + davCalendar + "/"
+}
+
+// itemHref return href of event resource, name given by client is kept, so client doesn't see it as a new resource
+func itemHref(u user.User, ev *event.Event) string {
+	if ev.ResourceName != "" {
+		return collectionHref(u) + url.PathEscape(ev.ResourceName)
+	}
+	return collectionHref(u) + ev.ID.String() + ".ics"
+}
+
+func principalProps(u user.User) caldav.Props {
+	return caldav.Props{
+		caldav.ResourceType:           "<D:collection/><D:principal/>",
+		caldav.DisplayName:            caldav.Text(u.Login),
+		caldav.CurrentUserPrincipal:   caldav.Href(principalHref(u)),
+		caldav.PrincipalURL:           caldav.Href(principalHref(u)),
+		caldav.CalendarHomeSet:        caldav.Href(principalHref(u)),
+		caldav.CalendarUserAddressSet: caldav.Href("mailto:" + u.Email),
+	}
+}
+
+func collectionProps(u user.User, ctag string) caldav.Props {
+	return caldav.Props{
+		caldav.ResourceType:                  "<D:collection/><C:calendar/>",
+		caldav.DisplayName:                   caldav.Text("Events"),
+		caldav.Owner:                         caldav.Href(principalHref(u)),
+		caldav.CurrentUserPrincipal:          caldav.Href(principalHref(u)),
+		caldav.CurrentUserPrivilegeSet:       davPrivileges,
+		caldav.SupportedCalendarComponentSet: `<C:comp name="VEVENT"/>`,
+		caldav.SupportedReportSet:            davReports,
+		caldav.GetCTag:                       caldav.Text(ctag),
+	}
+}
+
+// itemProps return properties of event resource, calendar-data is added only if it is requested
+func itemProps(ev *event.Event, names []caldav.Name) caldav.Props {
+	props := caldav.Props{
+		caldav.ResourceType:            "",
+		caldav.DisplayName:             caldav.Text(ev.Title),
+		caldav.GetETag:                 caldav.Text(eventETag(ev)),
+		caldav.GetContentType:          caldav.Text("text/calendar; charset=utf-8; component=vevent"),
+		caldav.CurrentUserPrivilegeSet: davPrivileges,
+	}
+	for _, name := range names {
+		if name == caldav.CalendarData {
+			var buf bytes.Buffer
+			if err := ical.NewEncoder(&buf).Encode([]event.Event{*ev}); err == nil {
+				props[caldav.CalendarData] = caldav.Text(buf.String())
+			}
+		}
+	}
+	return props
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"calendar/caldav"
+	"calendar/event"
+	"calendar/ical"
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDavMultigetUnescapesHref(t *testing.T) {
+	es, _ := newTestAPI()
+	u := user.User{ID: uuid.New(), Login: "dav"}
+	ctx := context.WithValue(context.Background(), "user_id", u.ID)
+	ctx = context.WithValue(ctx, "timezone", "UTC")
+	ev := event.Event{ID: ical.EventId(u.ID, "team meeting"), ResourceName: "team meeting.ics", Title: "Team meeting",
+		DateTime: time.Now().UTC(), Timezone: "UTC", Duration: time.Hour, UserId: u.ID}
+	if _, err := storage.NewEventStorage().Save(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	href := itemHref(u, &ev)
+	if !strings.Contains(href, "team%20meeting.ics") {
+		t.Fatalf("href = %s", href)
+	}
+	missing := collectionHref(u) + "other%20meeting.ics"
+	report := caldav.Report{Multiget: true, Props: []caldav.Name{caldav.DisplayName}, Hrefs: []string{href, missing}}
+	w := httptest.NewRecorder()
+	es.davReport(w, httptest.NewRequest("REPORT", collectionHref(u), nil).WithContext(ctx), u, report)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "Team meeting") {
+		t.Errorf("multiget doesn't return event of escaped href: %s", body)
+	}
+	if !strings.Contains(body, http.StatusText(http.StatusNotFound)) {
+		t.Errorf("multiget doesn't return 404 of missing href: %s", body)
+	}
+}
+
+func TestDavEventsListSeries(t *testing.T) {
+	es, _ := newTestAPI()
+	userId := uuid.New()
+	ctx := context.WithValue(context.Background(), "user_id", userId)
+	ctx = context.WithValue(ctx, "timezone", "UTC")
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	series := event.Event{ID: uuid.New(), Title: "Standup", DateTime: start, Timezone: "Europe/Riga", Duration: time.Hour,
+		RRule: "FREQ=DAILY;COUNT=2", UserId: userId}
+	// every occurrence is cancelled, the series is still in collection
+	for _, rid := range []time.Time{start, start.AddDate(0, 0, 1)} {
+		series.Overrides = append(series.Overrides, event.Override{EventId: series.ID, RecurrenceId: rid, Cancelled: true})
+	}
+	single := event.Event{ID: uuid.New(), Title: "Review", DateTime: start, Timezone: "UTC", Duration: time.Hour, UserId: userId}
+	for _, ev := range []event.Event{series, single} {
+		if _, err := storage.NewEventStorage().Save(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evs, err := es.davEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("davEvents() = %d events, want 2", len(evs))
+	}
+	for _, ev := range evs {
+		if ev.ID == series.ID && (len(ev.Overrides) != 2 || ev.Timezone != "Europe/Riga") {
+			t.Errorf("series = %+v", ev)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/user"
+	"calendar/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+type shareRequest struct {
+	Access string `json:"access"`
+}
+
+// ListCalendars list calendars of logged in user and then calendars shared with user (GET /api/calendars)
+func (es *EventServer) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	calendars, err := es.calendarsOf(r.Context(), userId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, calendars)
+}
+
+// CreateCalendar create calendar of logged in user (POST /api/calendars)
+func (es *EventServer) CreateCalendar(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	var c event.Calendar
+	if !decodeCalendar(w, r, &c) {
+		return
+	}
+	c.ID = uuid.New()
+	c.UserId = userId
+	c.CreatedAt = time.Now().UTC()
+	if err := es.Calendars.SaveCalendar(r.Context(), c); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	c.Access = event.AccessOwner
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// calendarHandler find calendar {id} of path with access of logged in user for handler.
+// Calendars shared with user can be read, only owner can change calendar and its shares
+func (es *EventServer) calendarHandler(need event.Access, handler func(w http.ResponseWriter, r *http.Request, c *event.Calendar)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(pathParam(r, "id"))
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong calendar id")
+			return
+		}
+		userId, _ := r.Context().Value("user_id").(uuid.UUID)
+		c, err := es.calendarOf(r.Context(), userId, id)
+		if errors.Is(err, storage.ErrCalendarNotFound) {
+			writeProblem(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		if !c.Access.Allows(need) {
+			writeProblem(w, http.StatusForbidden, "Only owner can change calendar")
+			return
+		}
+		handler(w, r, &c)
+	}
+}
+
+// GetCalendar return calendar with access of user (GET /api/calendars/{id})
+func (es *EventServer) GetCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	writeJSON(w, http.StatusOK, c)
+}
+
+// UpdateCalendar change name, color, timezone and default reminders of calendar (PUT /api/calendars/{id})
+func (es *EventServer) UpdateCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	update := *c
+	if !decodeCalendar(w, r, &update) {
+		return
+	}
+	if err := es.Calendars.SaveCalendar(r.Context(), update); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, update)
+}
+
+func decodeCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) bool {
+	err := json.NewDecoder(r.Body).Decode(c)
+	if errors.Is(err, event.ErrInvalidCalendar) || errors.Is(err, event.ErrInvalidReminder) || errors.Is(err, event.ErrTooManyReminders) {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return false
+	}
+	return true
+}
+
+// ListShares list users calendar is shared with (GET /api/calendars/{id}/shares)
+func (es *EventServer) ListShares(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	shares, err := es.Calendars.GetShares(r.Context(), c.ID)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, shares)
+}
+
+// ShareCalendar share calendar with user by login or change access of user (PUT /api/calendars/{id}/shares/{login})
+func (es *EventServer) ShareCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	u, ok := es.shareUser(w, r)
+	if !ok {
+		return
+	}
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	access, err := event.ParseAccess(req.Access)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if u.ID == c.UserId {
+		writeProblem(w, http.StatusBadRequest, "Calendar can't be shared with its owner")
+		return
+	}
+	s := event.Share{CalendarId: c.ID, UserId: u.ID, Login: u.Login, Access: access, CreatedAt: time.Now().UTC()}
+	if old, err := es.Calendars.GetShare(r.Context(), c.ID, u.ID); err == nil {
+		s.CreatedAt = old.CreatedAt
+	}
+	if err = es.Calendars.SaveShare(r.Context(), s); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// UnshareCalendar stop sharing calendar with user (DELETE /api/calendars/{id}/shares/{login})
+func (es *EventServer) UnshareCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	u, ok := es.shareUser(w, r)
+	if !ok {
+		return
+	}
+	err := es.Calendars.DeleteShare(r.Context(), c.ID, u.ID)
+	if errors.Is(err, storage.ErrShareNotFound) {
+		writeProblem(w, http.StatusNotFound, "Calendar is not shared with user")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// shareUser find user by {login} of path
+func (es *EventServer) shareUser(w http.ResponseWriter, r *http.Request) (user.User, bool) {
+	u, err := es.UserStore.GetUserByLogin(r.Context(), pathParam(r, "login"))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		writeProblem(w, http.StatusNotFound, "User not found")
+		return u, false
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return u, false
+	}
+	return u, true
+}
+
+// DeleteCalendar delete events of calendar one by one, so they get to change log and webhooks, and then calendar
+// (DELETE /api/calendars/{id})
+func (es *EventServer) DeleteCalendar(w http.ResponseWriter, r *http.Request, c *event.Calendar) {
+	if c.IsDefault() {
+		writeProblem(w, http.StatusBadRequest, "Default calendar can't be deleted")
+		return
+	}
+	ids, err := es.Calendars.CalendarEvents(r.Context(), c.ID)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	for _, id := range ids {
+		ev, err := es.Store.GetEventById(r.Context(), id)
+		if err == nil {
+			err = es.Store.Delete(r.Context(), id, 0)
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		es.publish(r.Context(), webhook.EventDeleted, &ev)
+	}
+	err = es.Calendars.DeleteCalendar(r.Context(), c.ID)
+	if err != nil && !errors.Is(err, storage.ErrCalendarNotFound) {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// calendarOf return calendar by id with access of user to it, uuid.Nil is default calendar of user.
+// Default calendar is created when it is used first time, calendar of other user which is not shared is not found
+func (es *EventServer) calendarOf(ctx context.Context, userId, id uuid.UUID) (event.Calendar, error) {
+	if id == uuid.Nil {
+		id = userId
+	}
+	if id == userId {
+		_, err := es.Calendars.GetCalendar(ctx, id)
+		if errors.Is(err, storage.ErrCalendarNotFound) {
+			err = es.Calendars.SaveCalendar(ctx, event.DefaultCalendar(userId, ""))
+		}
+		if err != nil {
+			return event.Calendar{}, err
+		}
+	}
+	return storage.CalendarAccess(context.WithValue(ctx, "user_id", userId), es.Calendars, id)
+}
+
+// calendarsOf return calendars of user and then calendars shared with user,
+// default calendar is created when user doesn't have it yet
+func (es *EventServer) calendarsOf(ctx context.Context, userId uuid.UUID) ([]event.Calendar, error) {
+	calendars, err := es.Calendars.GetCalendars(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(calendars) == 0 || !calendars[0].IsDefault() {
+		c, err := es.calendarOf(ctx, userId, uuid.Nil)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append([]event.Calendar{c}, calendars...)
+	}
+	for i := range calendars {
+		calendars[i].Access = event.AccessOwner
+	}
+	shares, err := es.Calendars.SharedWith(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range shares {
+		c, err := es.Calendars.GetCalendar(ctx, s.CalendarId)
+		if errors.Is(err, storage.ErrCalendarNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Access = s.Access
+		calendars = append(calendars, c)
+	}
+	return calendars, nil
+}
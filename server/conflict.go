@@ -0,0 +1,59 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// conflictsOf find events of owner, including accepted invitations, which overlap event.
+// Conflicting events which logged in user can't read, like other calendars of owner, are shown as busy
+func (es *EventServer) conflictsOf(ctx context.Context, ev *event.Event) ([]event.Conflict, error) {
+	userId := ev.UserId
+	if userId == uuid.Nil {
+		userId, _ = ctx.Value("user_id").(uuid.UUID)
+	}
+	ownerCtx := context.WithValue(ctx, "user_id", userId)
+	ownerCtx = context.WithValue(ownerCtx, "timezone", "UTC")
+	from, to := ev.ConflictWindow()
+	dateFrom, err := es.lookbackDate(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	filter := event.EventFilter{
+		Timezone: "UTC",
+		DateFrom: dateFrom,
+		DateTo:   to.UTC().Format(filterDateForm),
+	}
+	others, err := es.Store.GetEvents(ownerCtx, filter)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := ev.FindConflicts(others, from, to)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]*event.Event, len(others))
+	for i := range others {
+		byId[others[i].ID] = &others[i]
+	}
+	for i, c := range conflicts {
+		access, err := storage.AccessTo(ctx, es.Calendars, byId[c.ID])
+		if err != nil {
+			return nil, err
+		}
+		if !access.Allows(event.AccessRead) {
+			conflicts[i] = c.BusyOnly()
+		}
+	}
+	return conflicts, nil
+}
+
+// writeConflicts reject saving of event which overlaps other events in strict mode
+func writeConflicts(w http.ResponseWriter, conflicts []event.Conflict) {
+	p := newProblem(http.StatusConflict, "event overlaps other events")
+	p.Conflicts = conflicts
+	p.write(w)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+)
+
+type feedLinks struct {
+	Token  string `json:"token"`
+	Url    string `json:"url"`
+	Webcal string `json:"webcal"`
+}
+
+// ServeFeedToken generate or rotate (POST) and revoke (DELETE) secret url of calendar feed
+func (es *EventServer) ServeFeedToken(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	switch r.Method {
+	case http.MethodPost:
+		token, hash, err := user.NewFeedToken()
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+			return
+		}
+		err = es.UserStore.UpdateFeedHash(r.Context(), userId, hash)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			writeProblem(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		path := r.Host + "/feed/" + token + ".ics"
+		w.Header().Set("content-type", jsonContentType)
+		err = json.NewEncoder(w).Encode(feedLinks{token, scheme + "://" + path, "webcal://" + path})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	case http.MethodDelete:
+		err := es.UserStore.UpdateFeedHash(r.Context(), userId, "")
+		if errors.Is(err, storage.ErrUserNotFound) {
+			writeProblem(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		}
+	}
+}
+
+// ServeFeed serve read-only iCalendar feed of user found by secret token from url,
+// calendar clients can't login, so this handler is not behind AuthMiddleware
+func (es *EventServer) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(pathParam(r, "file"), ".ics")
+	userEntity, err := es.UserStore.GetUserByFeedHash(r.Context(), user.HashFeedToken(token))
+	if token == "" || errors.Is(err, storage.ErrUserNotFound) {
+		writeProblem(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if userEntity.Timezone == "" {
+		userEntity.Timezone = "UTC"
+	}
+	ctx := context.WithValue(r.Context(), "timezone", userEntity.Timezone)
+	ctx = context.WithValue(ctx, "user_id", userEntity.ID)
+
+	// feed is polled by every subscribed client, so series are read as stored, without expansion
+	evs, err := es.storedSeries(ctx, event.EventFilter{})
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeICS(w, evs)
+}
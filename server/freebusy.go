@@ -0,0 +1,142 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// filterDateForm is date format of event.EventFilter
+	filterDateForm   = "2006-01-02"
+	maxFreeBusyUsers = 50
+	maxFreeBusyRange = 62 * 24 * time.Hour
+)
+
+type freeBusyRequest struct {
+	Users []string  `json:"users"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
+
+type userBusy struct {
+	Login    string           `json:"login"`
+	Timezone string           `json:"timezone,omitempty"`
+	Busy     []event.Interval `json:"busy"`
+	Error    string           `json:"error,omitempty"`
+}
+
+type freeBusyResponse struct {
+	From  time.Time  `json:"from"`
+	To    time.Time  `json:"to"`
+	Users []userBusy `json:"users"`
+}
+
+// FreeBusy return merged busy intervals of users by their logins (POST /api/freebusy),
+// only start and end of busy time are returned, never title or description of events
+func (es *EventServer) FreeBusy(w http.ResponseWriter, r *http.Request) {
+	var req freeBusyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	if err = validateRange(req.From, req.To); err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Users) == 0 || len(req.Users) > maxFreeBusyUsers {
+		writeProblem(w, http.StatusBadRequest, "from 1 to 50 users can be requested")
+		return
+	}
+
+	resp := freeBusyResponse{From: req.From, To: req.To, Users: make([]userBusy, 0, len(req.Users))}
+	for _, login := range req.Users {
+		ub := userBusy{Login: login, Busy: []event.Interval{}}
+		u, err := es.UserStore.GetUserByLogin(r.Context(), login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			ub.Error = "user not found"
+			resp.Users = append(resp.Users, ub)
+			continue
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		ub.Timezone = userLocation(u).String()
+		ub.Busy, err = es.busyOf(r.Context(), u, req.From, req.To)
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		resp.Users = append(resp.Users, ub)
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// validateRange check that time range is not empty and not too long
+func validateRange(from, to time.Time) error {
+	if from.IsZero() || !to.After(from) {
+		return errors.New("from must be before to")
+	}
+	if to.Sub(from) > maxFreeBusyRange {
+		return errors.New("range must not be longer than 62 days")
+	}
+	return nil
+}
+
+// userLocation return timezone of user, UTC if it is not set or unknown
+func userLocation(u user.User) *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil || u.Timezone == "" {
+		return time.UTC
+	}
+	return loc
+}
+
+// lookbackDate return the first date of filter for events which last at from. Events are found by start,
+// so events started before from by the longest duration of events can still last at it
+func (es *EventServer) lookbackDate(ctx context.Context, from time.Time) (string, error) {
+	longest, err := es.Store.MaxDuration(ctx)
+	if err != nil {
+		return "", err
+	}
+	return from.UTC().Add(-longest).Format(filterDateForm), nil
+}
+
+// busyOf return busy intervals of user in [from, to) in timezone of user,
+// events of user and accepted or not yet answered invitations are busy
+func (es *EventServer) busyOf(ctx context.Context, u user.User, from, to time.Time) ([]event.Interval, error) {
+	ctx = context.WithValue(ctx, "user_id", u.ID)
+	ctx = context.WithValue(ctx, "timezone", "UTC")
+	dateFrom, err := es.lookbackDate(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	filter := event.EventFilter{
+		Timezone: "UTC",
+		DateFrom: dateFrom,
+		DateTo:   to.UTC().Format(filterDateForm),
+	}
+	evs, err := es.Store.GetEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	loc := userLocation(u)
+	busy := event.Busy(evs, from, to)
+	for i := range busy {
+		busy[i].Start = busy[i].Start.In(loc)
+		busy[i].End = busy[i].End.In(loc)
+	}
+	return busy, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"calendar/storage"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAPI return server with in-memory stores and routes of /api which are served without login
+func newTestAPI() (*EventServer, http.Handler) {
+	mem := storage.NewEventStorage()
+	es := NewEventServer(mem, storage.NewUserStorage(), storage.NewSessionStorage(), mem, mem, storage.NewWebhookStorage(), nil, nil)
+	return es, es.apiRouter()
+}
+
+// serveAs send request of logged in user, header is pairs of name and value
+func serveAs(h http.Handler, userId uuid.UUID, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	ctx := context.WithValue(r.Context(), "user_id", userId)
+	ctx = context.WithValue(ctx, "timezone", "UTC")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r.WithContext(ctx))
+	return w
+}
+
+// createTestEvent create event by API and return its id
+func createTestEvent(t *testing.T, h http.Handler, userId uuid.UUID, body string) string {
+	t.Helper()
+	w := serveAs(h, userId, http.MethodPost, "/api/event", body, "content-type", jsonContentType)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create event: %d %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+// assertProblem check status and problem+json body of error response
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	var p problem
+	if w.Code != status || w.Header().Get("content-type") != problemContentType {
+		t.Fatalf("got %d %s, want %d problem", w.Code, w.Header().Get("content-type"), status)
+	}
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil || p.Status != status || p.Title != http.StatusText(status) {
+		t.Fatalf("problem = %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/ical"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// ServeEventsICS export events filtered by GET parameters as iCalendar
+func (es *EventServer) ServeEventsICS(w http.ResponseWriter, r *http.Request) {
+	filter, err := decodeFilter(r)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Error in GET parameters")
+		return
+	}
+	filter.IncludeShared = true
+	evs, err := es.Store.GetEvents(r.Context(), filter)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	series, err := es.seriesOf(r.Context(), evs, filter)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeICS(w, series)
+}
+
+// writeICS write events as iCalendar response
+func writeICS(w http.ResponseWriter, evs []event.Event) {
+	w.Header().Set("content-type", ical.ContentType)
+	err := ical.NewEncoder(w).Encode(evs)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// seriesOf replace expanded occurrences by their recurring series, so every UID is written once.
+// Series are read by one query with the criteria of filter occurrences are found by
+func (es *EventServer) seriesOf(ctx context.Context, evs []event.Event, ef event.EventFilter) ([]event.Event, error) {
+	recurring := make(map[uuid.UUID]event.Event)
+	// series are read once, when the first occurrence is found
+	for _, ev := range evs {
+		if !ev.IsRecurring() {
+			continue
+		}
+		stored, err := es.storedSeries(ctx, ef)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stored {
+			if s.IsRecurring() {
+				recurring[s.ID] = s
+			}
+		}
+		break
+	}
+	series := make([]event.Event, 0, len(evs))
+	seen := make(map[uuid.UUID]bool, len(evs))
+	for _, ev := range evs {
+		if seen[ev.ID] {
+			continue
+		}
+		seen[ev.ID] = true
+		if ev.IsRecurring() {
+			var ok bool
+			if ev, ok = recurring[ev.ID]; !ok {
+				// series is deleted after its occurrences are read
+				continue
+			}
+		}
+		series = append(series, ev)
+	}
+	return series, nil
+}
+
+// storedSeries return events found by filter without expansion of recurring events.
+// Series keep their own timezone, other events are moved to timezone of user
+func (es *EventServer) storedSeries(ctx context.Context, ef event.EventFilter) ([]event.Event, error) {
+	ef.Series, ef.Timezone = true, ""
+	evs, err := es.Store.GetEvents(seriesContext(ctx), ef)
+	if err != nil {
+		return nil, err
+	}
+	for i := range evs {
+		if evs[i].IsRecurring() {
+			continue
+		}
+		if err = evs[i].ChangeTimezoneFromContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return evs, nil
+}
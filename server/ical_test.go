@@ -0,0 +1,100 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/ical"
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// saveSeriesAndSingle save weekly series with cancelled occurrence and single event of user
+func saveSeriesAndSingle(t *testing.T, userId uuid.UUID) (event.Event, event.Event) {
+	t.Helper()
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	series := event.Event{ID: uuid.New(), Title: "Standup", DateTime: start, Timezone: "Europe/Riga", Duration: time.Hour,
+		RRule: "FREQ=WEEKLY;COUNT=3", UserId: userId}
+	series.Overrides = []event.Override{{EventId: series.ID, RecurrenceId: start.AddDate(0, 0, 7), Cancelled: true}}
+	single := event.Event{ID: uuid.New(), Title: "Review", DateTime: start.AddDate(0, 0, 1), Timezone: "UTC",
+		Duration: time.Hour, UserId: userId}
+	for _, ev := range []event.Event{series, single} {
+		if _, err := storage.NewEventStorage().Save(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return series, single
+}
+
+// decodeSeries decode iCalendar response, every UID must be written once
+func decodeSeries(t *testing.T, w *httptest.ResponseRecorder) map[uuid.UUID]event.Event {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	evs, errs, err := ical.NewDecoder(w.Body).Decode()
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("decode: %v %v", errs, err)
+	}
+	byId := make(map[uuid.UUID]event.Event, len(evs))
+	for _, ev := range evs {
+		byId[ev.ID] = ev
+	}
+	return byId
+}
+
+func TestServeFeedWritesSeries(t *testing.T) {
+	es, _ := newTestAPI()
+	token, hash, err := user.NewFeedToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := es.UserStore.Save(context.Background(), user.User{Login: "feed-" + uuid.NewString(), FeedHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, single := saveSeriesAndSingle(t, u.ID)
+
+	w := httptest.NewRecorder()
+	es.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed/"+token+".ics", nil))
+	evs := decodeSeries(t, w)
+	if len(evs) != 2 {
+		t.Fatalf("feed has %d events, want 2", len(evs))
+	}
+	if got := evs[series.ID]; got.RRule != series.RRule || len(got.Overrides) != 1 || !got.Overrides[0].Cancelled {
+		t.Errorf("series = %+v", got)
+	}
+	if got := evs[single.ID]; !got.DateTime.Equal(single.DateTime) {
+		t.Errorf("single = %+v", got)
+	}
+}
+
+func TestSeriesOfOccurrences(t *testing.T) {
+	es, _ := newTestAPI()
+	userId := uuid.New()
+	series, _ := saveSeriesAndSingle(t, userId)
+	ctx := context.WithValue(context.Background(), "user_id", userId)
+	ctx = context.WithValue(ctx, "timezone", "UTC")
+
+	// the last occurrence only is in the window
+	filter := event.EventFilter{DateFrom: "2026-03-16", DateTo: "2026-03-31", IncludeShared: true}
+	occurrences, err := es.Store.GetEvents(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs, err := es.seriesOf(ctx, occurrences, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 {
+		t.Fatalf("seriesOf() = %d events, want series only", len(evs))
+	}
+	if got := evs[0]; got.ID != series.ID || got.RRule != series.RRule || got.Timezone != "Europe/Riga" ||
+		!got.DateTime.Equal(series.DateTime) || len(got.Overrides) != 1 {
+		t.Errorf("series = %+v", got)
+	}
+}
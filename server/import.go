@@ -0,0 +1,141 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/ical"
+	"calendar/storage"
+	"calendar/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxImportSize limits size of uploaded calendar
+const maxImportSize = 10 << 20
+
+type importResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Skipped int               `json:"skipped"`
+	Errors  []ical.EntryError `json:"errors"`
+}
+
+// ImportEvents create or update events from iCalendar body or "file" field of multipart form,
+// events are matched by UID, so the same file can be imported again
+func (es *EventServer) ImportEvents(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("content-type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong body")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	decoder := ical.NewDecoder(body)
+	decoder.UserId, _ = r.Context().Value("user_id").(uuid.UUID)
+	if v := r.Context().Value("timezone"); v != nil {
+		if loc, err := time.LoadLocation(v.(string)); err == nil {
+			decoder.Location = loc
+		}
+	}
+	events, entryErrors, err := decoder.Decode()
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong calendar: "+err.Error())
+		return
+	}
+
+	result := importResult{Errors: entryErrors}
+	if result.Errors == nil {
+		result.Errors = []ical.EntryError{}
+	}
+	for _, ev := range events {
+		created, updated, err := es.importEvent(r.Context(), ev)
+		switch {
+		case err != nil:
+			result.Errors = append(result.Errors, ical.EntryError{UID: ev.ID.String(), Error: err.Error()})
+		case created:
+			result.Created++
+		case updated:
+			result.Updated++
+		default:
+			result.Skipped++
+		}
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// errForeignId is returned when UID of imported event is id of event of another user
+var errForeignId = errors.New("id is taken by event of another user")
+
+// importEvent save event if it is new or changed since the last import. When UID is id of event of another user,
+// like in calendar exported by this user, user gets own copy of it
+func (es *EventServer) importEvent(ctx context.Context, ev event.Event) (created, updated bool, err error) {
+	ctx = seriesContext(ctx)
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+	created, updated, err = es.importAs(ctx, userId, ev)
+	if errors.Is(err, errForeignId) {
+		return es.importAs(ctx, userId, userCopy(userId, ev))
+	}
+	return created, updated, err
+}
+
+// importAs save imported event with its id, errForeignId is returned when it belongs to another user,
+// events which user can't see are found only by Save
+func (es *EventServer) importAs(ctx context.Context, userId uuid.UUID, ev event.Event) (created, updated bool, err error) {
+	old, err := es.Store.GetEventById(ctx, ev.ID)
+	if err == nil && old.UserId != userId {
+		return false, false, errForeignId
+	}
+	if err != nil && !errors.Is(err, storage.ErrEventNotFound) {
+		return false, false, err
+	}
+	exist := err == nil
+	if exist {
+		// notes, attendees and reminders are not imported from iCalendar and are kept from the stored event
+		ev.Notes = old.Notes
+		ev.Attendees = old.Attendees
+		ev.Reminders = old.Reminders
+		ev.UserId = old.UserId
+		ev.Version = old.Version
+		ev.ResourceName = old.ResourceName
+		if old.Equal(&ev) {
+			return false, false, nil
+		}
+	}
+	saved, err := es.Store.Save(ctx, ev)
+	if errors.Is(err, storage.ErrEventNotFound) && !exist {
+		return false, false, errForeignId
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if exist {
+		es.publish(ctx, webhook.EventUpdated, &saved)
+	} else {
+		es.publish(ctx, webhook.EventCreated, &saved)
+	}
+	return !exist, exist, nil
+}
+
+// userCopy return event with id of own copy of user, its UID is id of event of another user
+func userCopy(userId uuid.UUID, ev event.Event) event.Event {
+	ev.ID = ical.UserEventId(userId, ev.ID.String())
+	for i := range ev.Overrides {
+		ev.Overrides[i].EventId = ev.ID
+	}
+	return ev
+}
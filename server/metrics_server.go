@@ -9,11 +9,11 @@ import (
 	"strconv"
 )
 
-func NewMetricsServer(store storage.EventStore) *EventServer {
+func NewMetricsServer(store storage.EventStore, userStore storage.UserStore) *EventServer {
 	ms := new(EventServer)
 
 	ms.Store = store
-	ms.UserStore = &storage.Users
+	ms.UserStore = userStore
 
 	router := http.NewServeMux()
 	router.HandleFunc("/metrics/events", ms.TotalEvents)
@@ -34,7 +34,7 @@ func (ms *EventServer) Empty(w http.ResponseWriter, r *http.Request) {
 func (ms *EventServer) TotalEvents(w http.ResponseWriter, r *http.Request) {
 	cnt, err := ms.Store.Count(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
 		return
 	}
 	_, err = w.Write([]byte("Number of events: " + strconv.Itoa(cnt)))
@@ -45,7 +45,12 @@ func (ms *EventServer) TotalEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ms *EventServer) TotalUsers(w http.ResponseWriter, r *http.Request) {
-	_, err := w.Write([]byte("Number of users: " + strconv.Itoa(ms.UserStore.Count())))
+	cnt, err := ms.UserStore.Count(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	_, err = w.Write([]byte("Number of users: " + strconv.Itoa(cnt)))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -4,6 +4,7 @@ import (
 	"calendar/storage"
 	"calendar/user"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"net/http"
@@ -16,47 +17,37 @@ func PanicMiddleware(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				fmt.Println("recovered", err)
-				http.Error(w, "Internal server error", 500)
+				writeProblem(w, http.StatusInternalServerError, "Internal server error")
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+func AuthMiddleware(users storage.UserStore, sessions storage.SessionStore, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c, err := r.Cookie("token")
 		if err != nil {
-			if err == http.ErrNoCookie {
-				http.Error(w, "error: not authorized", http.StatusUnauthorized)
-				return
-			}
-			http.Error(w, "error: not authorized", http.StatusUnauthorized)
+			writeProblem(w, http.StatusUnauthorized, "not authorized")
 			return
 		}
-		tknStr := c.Value
-		claims := &user.Claims{}
-
-		tkn, err := jwt.ParseWithClaims(tknStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return user.JwtKey, nil
-		})
+		claims, err := parseToken(c.Value)
 		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				http.Error(w, "error: not authorized", http.StatusUnauthorized)
-				return
-			}
-			http.Error(w, "error: not authorized", http.StatusUnauthorized)
+			writeProblem(w, http.StatusUnauthorized, "not authorized")
 			return
 		}
 
-		if !tkn.Valid {
-			http.Error(w, "error: not authorized", http.StatusUnauthorized)
+		// token stays valid after logout until it expires, so session is checked on every request
+		session, err := sessions.GetSession(r.Context(), claims.SessionId)
+		if err != nil || session.UserId != claims.ID || !session.IsActive(time.Now()) {
+			writeProblem(w, http.StatusUnauthorized, "session is revoked")
 			return
 		}
 
-		userEntity, ok := storage.Users.GetUserByLogin(claims.Username)
-		if !ok {
-			http.Error(w, "error: not authorized", http.StatusUnauthorized)
+		userEntity, err := users.GetUserByLogin(r.Context(), claims.Username)
+		if err != nil {
+			writeProblem(w, http.StatusUnauthorized, "not authorized")
+			return
 		}
 
 		if userEntity.Timezone != claims.Timezone {
@@ -65,17 +56,95 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 			tokenString, err := token.SignedString(user.JwtKey)
 			if err != nil {
-				http.Error(w, "error: can't update user's timezone", http.StatusInternalServerError)
+				writeProblem(w, http.StatusInternalServerError, "can't update user's timezone")
 				return
 			}
-			http.SetCookie(w, &http.Cookie{
-				Name:    "token",
-				Value:   tokenString,
-				Expires: time.Unix(claims.StandardClaims.ExpiresAt, 0),
-			})
+			http.SetCookie(w, tokenCookie("token", tokenString, time.Unix(claims.StandardClaims.ExpiresAt, 0)))
+		}
+		ctx := context.WithValue(r.Context(), "timezone", userEntity.Timezone)
+		ctx = context.WithValue(ctx, "user_id", userEntity.ID)
+		ctx = context.WithValue(ctx, "session_id", session.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// parseToken check signature and expiration of access token
+func parseToken(tknStr string) (*user.Claims, error) {
+	claims := &user.Claims{}
+	tkn, err := jwt.ParseWithClaims(tknStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return user.JwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !tkn.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return claims, nil
+}
+
+// BasicAuthMiddleware authenticate clients which can't use token cookie, like CalDAV clients.
+// Every client is a session of user, so it is listed and revoked like logins
+func BasicAuthMiddleware(users storage.UserStore, sessions storage.SessionStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, password, ok := r.BasicAuth()
+		// password of unknown user is checked against empty hash, which is never valid
+		userEntity, _ := users.GetUserByLogin(r.Context(), login)
+		if !ok || !user.CheckPassword(userEntity.Password, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Calendar", charset="UTF-8"`)
+			writeProblem(w, http.StatusUnauthorized, "not authorized")
+			return
+		}
+		session, err := basicSession(r.Context(), sessions, userEntity, r.UserAgent())
+		if errors.Is(err, errSessionRevoked) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Calendar", charset="UTF-8"`)
+			writeProblem(w, http.StatusUnauthorized, "session is revoked")
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		if userEntity.Timezone == "" {
+			userEntity.Timezone = "UTC"
 		}
 		ctx := context.WithValue(r.Context(), "timezone", userEntity.Timezone)
-		ctx = context.WithValue(r.Context(), "user_id", userEntity.ID)
+		ctx = context.WithValue(ctx, "user_id", userEntity.ID)
+		ctx = context.WithValue(ctx, "session_id", session.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+var errSessionRevoked = errors.New("session is revoked")
+
+// basicSession return session of client which logs in with password on every request. Session is found by hash
+// of user, hash of password and user agent, so revoked client is refused until password is changed
+// by PUT /api/user/password, then the client logs in with the new password and gets a new session.
+// Session lives while client uses it, like session which is refreshed
+func basicSession(ctx context.Context, sessions storage.SessionStore, u user.User, userAgent string) (user.Session, error) {
+	hash := user.HashRefreshToken("basic:" + u.ID.String() + ":" + u.Password + ":" + userAgent)
+	s, err := sessions.GetSessionByRefreshHash(ctx, hash)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		s, _, err = user.NewSession(u.ID, userAgent)
+		if err != nil {
+			return s, err
+		}
+		s.RefreshHash = hash
+		return s, sessions.CreateSession(ctx, s)
+	}
+	if err != nil {
+		return s, err
+	}
+	if s.RevokedAt != nil || s.UserId != u.ID {
+		return s, errSessionRevoked
+	}
+	now := time.Now()
+	// use of session is written at most once a minute, clients send many requests while they sync
+	if now.Sub(s.LastUsedAt) > time.Minute || !s.IsActive(now) {
+		err = sessions.RotateSession(ctx, s.ID, hash, hash, now.Add(user.RefreshTokenTTL))
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return s, errSessionRevoked
+		}
+	}
+	return s, err
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"calendar/storage"
+	"calendar/user"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestLogin save user with session and return access token cookie issued for timezone
+func newTestLogin(t *testing.T, users storage.UserStore, sessions storage.SessionStore, timezone string) (user.User, *http.Cookie) {
+	t.Helper()
+	if user.JwtKey == nil {
+		user.JwtKey = []byte("0123456789abcdef")
+	}
+	ctx := context.Background()
+	u, err := users.Save(ctx, user.User{Login: "user-" + uuid.NewString()[:8], Timezone: "Europe/Riga"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, refresh, err := user.NewSession(u.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sessions.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	issued := u
+	issued.Timezone = timezone
+	if err = setTokenCookies(w, issued, session, refresh); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" {
+			return u, c
+		}
+	}
+	t.Fatal("token cookie is not set")
+	return u, nil
+}
+
+func TestAuthMiddlewareReissuesTokenOfChangedTimezone(t *testing.T) {
+	users, sessions := storage.NewUserStorage(), storage.NewSessionStorage()
+	_, token := newTestLogin(t, users, sessions, "UTC")
+
+	var timezone interface{}
+	h := AuthMiddleware(users, sessions, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timezone = r.Context().Value("timezone")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	r.AddCookie(token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || timezone != "Europe/Riga" {
+		t.Fatalf("request is served with %d and timezone %v", w.Code, timezone)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "token" || cookies[0].Path != "/" || !cookies[0].HttpOnly ||
+		cookies[0].Value == token.Value {
+		t.Fatalf("re-issued cookies = %+v", cookies)
+	}
+}
+
+func TestBasicAuthMiddlewareChecksSession(t *testing.T) {
+	ctx := context.Background()
+	users, sessions := storage.NewUserStorage(), storage.NewSessionStorage()
+	hash, err := user.HashPassword("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := users.Save(ctx, user.User{Login: "user-" + uuid.NewString()[:8], Password: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := BasicAuthMiddleware(users, sessions, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	password := "password1"
+	serve := func(userAgent string) int {
+		r := httptest.NewRequest("PROPFIND", davPrefix, nil)
+		r.SetBasicAuth(u.Login, password)
+		r.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("Thunderbird"); code != http.StatusOK {
+		t.Fatalf("first request of client = %d", code)
+	}
+	if code := serve("Thunderbird"); code != http.StatusOK {
+		t.Fatalf("next request of client = %d", code)
+	}
+	active, _ := sessions.GetSessions(ctx, u.ID)
+	if len(active) != 1 || active[0].UserAgent != "Thunderbird" {
+		t.Fatalf("sessions of client = %+v", active)
+	}
+	if err = sessions.RevokeSession(ctx, u.ID, active[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve("Thunderbird"); code != http.StatusUnauthorized {
+		t.Fatalf("request of revoked client = %d", code)
+	}
+	if code := serve("Apple Calendar"); code != http.StatusOK {
+		t.Fatalf("request of other client = %d", code)
+	}
+
+	// revoked client logs in again after password is changed
+	es := &EventServer{UserStore: users, SessionStore: sessions}
+	change := func(body string) int {
+		r := httptest.NewRequest(http.MethodPut, "/api/user/password", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		es.ChangePassword(w, r.WithContext(context.WithValue(r.Context(), "user_id", u.ID)))
+		return w.Code
+	}
+	if code := change(`{"password":"wrong password","newPassword":"password2"}`); code != http.StatusForbidden {
+		t.Fatalf("change with wrong password = %d", code)
+	}
+	if code := change(`{"password":"password1","newPassword":"short"}`); code != http.StatusBadRequest {
+		t.Fatalf("change to short password = %d", code)
+	}
+	if code := change(`{"password":"password1","newPassword":"password2"}`); code != http.StatusNoContent {
+		t.Fatalf("change of password = %d", code)
+	}
+	if code := serve("Apple Calendar"); code != http.StatusUnauthorized {
+		t.Fatalf("request with old password = %d", code)
+	}
+	password = "password2"
+	if code := serve("Thunderbird"); code != http.StatusOK {
+		t.Fatalf("request of revoked client with new password = %d", code)
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/webhook"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// rangeThisAndFuture applies occurrence change to the rest of series as RANGE=THISANDFUTURE in RFC 5545
+const rangeThisAndFuture = "thisandfuture"
+
+// ServeOccurrence get, modify or cancel one occurrence {date} of recurring event,
+// with ?range=thisandfuture PUT and DELETE split the series at the occurrence
+func (es *EventServer) ServeOccurrence(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	date := pathParam(r, "date")
+	ctx := seriesContext(r.Context())
+	// series seen only as free/busy has busy occurrences, changes are checked by store
+	ev, err := es.Store.GetEventById(ctx, id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	rid, err := ev.FindOccurrence(date)
+	if err == event.ErrNotRecurring {
+		writeProblem(w, http.StatusBadRequest, "Event is not recurring")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusNotFound, "Occurrence not found")
+		return
+	}
+	following := r.URL.Query().Get("range") == rangeThisAndFuture
+	// occurrence is part of series, so changes are checked against version of series
+	if !readOnly(r) && !matchVersion(r, ev.Version) {
+		writeStaleVersion(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		occ := ev.Occurrence(rid)
+		if err = occ.ChangeTimezoneFromContext(r.Context()); err != nil {
+			writeProblem(w, http.StatusInternalServerError, "Wrong timezone")
+			return
+		}
+		writeEvent(w, http.StatusOK, &occ)
+	case http.MethodPut:
+		var changed event.Event
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong body")
+			return
+		}
+		if err = changed.UnmarshalJSON(body); err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong entity")
+			return
+		}
+		if following {
+			es.splitSeries(w, r, ev, rid, changed)
+			return
+		}
+		ev.ModifyOccurrence(rid, changed)
+		if ev, err = es.Store.Save(ctx, ev); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		es.publish(ctx, webhook.EventUpdated, &ev)
+		occ := ev.Occurrence(rid)
+		_ = occ.ChangeTimezoneFromContext(r.Context())
+		writeEvent(w, http.StatusOK, &occ)
+	case http.MethodDelete:
+		if following && rid.Equal(ev.DateTime) {
+			if err = es.Store.Delete(ctx, id, ev.Version); err == nil {
+				es.publish(ctx, webhook.EventDeleted, &ev)
+			}
+		} else {
+			if following {
+				_, err = ev.SplitAt(rid)
+			} else {
+				ev.CancelOccurrence(rid)
+			}
+			if err == nil {
+				ev, err = es.Store.Save(ctx, ev)
+			}
+			if err == nil {
+				es.publish(ctx, webhook.EventUpdated, &ev)
+				w.Header().Set("ETag", versionETag(ev.Version))
+			}
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+}
+
+// splitSeries finish series before occurrence rid and save changed event as a new series
+// which inherits the rest of recurrence rule if it has no own rule. New series is saved first
+// and removed again when the old one can't be finished, so following occurrences are never lost
+func (es *EventServer) splitSeries(w http.ResponseWriter, r *http.Request, ev event.Event, rid time.Time, changed event.Event) {
+	old := ev
+	rest, err := ev.SplitAt(rid)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	changed.ID = uuid.New()
+	changed.Version = 0
+	changed.UserId = ev.UserId
+	changed.CalendarId = ev.CalendarId
+	changed.CopyAttendees(&old)
+	if len(changed.Reminders) == 0 {
+		changed.CopyReminders(&old)
+	}
+	if changed.RRule == "" {
+		changed.RRule = rest.String()
+	}
+	changed, err = es.Store.Save(r.Context(), changed)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	ctx := seriesContext(r.Context())
+	typ := webhook.EventUpdated
+	if rid.Equal(ev.DateTime) {
+		typ = webhook.EventDeleted
+		err = es.Store.Delete(ctx, ev.ID, ev.Version)
+	} else {
+		ev, err = es.Store.Save(ctx, ev)
+	}
+	if err != nil {
+		if delErr := es.Store.Delete(ctx, changed.ID, changed.Version); delErr != nil {
+			log.Println("new series of split is not removed: ", changed.ID, delErr)
+		}
+		writeStoreError(w, err)
+		return
+	}
+	if typ == webhook.EventDeleted {
+		ev = old
+	}
+	es.publish(ctx, typ, &ev)
+	es.publish(r.Context(), webhook.EventCreated, &changed)
+	writeEvent(w, http.StatusCreated, &changed)
+}
+
+// seriesContext keep events in their own timezone, so occurrences of recurring
+// series stay on the same wall clock
+func seriesContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "timezone", nil)
+}
+
+// writeEvent write event as JSON response with status
+func writeEvent(w http.ResponseWriter, status int, ev *event.Event) {
+	jsonEvent, err := json.Marshal(ev)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "Wrong json response")
+		return
+	}
+	w.Header().Set("content-type", jsonContentType)
+	if ev.Version != 0 {
+		w.Header().Set("ETag", versionETag(ev.Version))
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(jsonEvent)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "Wrong json response")
+	}
+}
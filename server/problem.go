@@ -0,0 +1,39 @@
+package server
+
+import (
+	"calendar/event"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is error response in format of RFC 7807 problem details. Type is about:blank,
+// so title is the text of status and detail explains this occurrence of error
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Conflicts are overlapping events which rejected event in strict mode
+	Conflicts []event.Conflict `json:"conflicts,omitempty"`
+}
+
+func newProblem(status int, detail string) problem {
+	return problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// writeProblem write error response with status and detail as problem+json
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	newProblem(status, detail).write(w)
+}
+
+func (p problem) write(w http.ResponseWriter) {
+	w.Header().Set("content-type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println("can't write problem: ", err)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRegisterReturnsOnlyPublicFields(t *testing.T) {
+	es, _ := newTestAPI()
+	login := "user-" + uuid.NewString()[:8]
+	body := `{"login":"` + login + `","email":"user@ukr.net","password":"password1","timezone":"Europe/Riga"}`
+	w := serveAs(es.Handler, uuid.Nil, http.MethodPost, "/register", body, "content-type", jsonContentType)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", w.Code, w.Body)
+	}
+	var got map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(got))
+	for k := range got {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "email,id,login,timezone" || got["login"] != login {
+		t.Errorf("registered user = %v", got)
+	}
+
+	w = serveAs(es.Handler, uuid.Nil, http.MethodPost, "/register",
+		`{"login":"team/user","email":"user@ukr.net","password":"password1"}`, "content-type", jsonContentType)
+	assertProblem(t, w, http.StatusBadRequest)
+}
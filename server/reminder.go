@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// ServeReminders list deliveries of reminders of event, the latest first (GET /api/event/{id}/reminders).
+// Only organizer can see them
+func (es *EventServer) ServeReminders(w http.ResponseWriter, r *http.Request, eventId uuid.UUID) {
+	ctx := seriesContext(r.Context())
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+	ev, err := es.Store.GetEventById(ctx, eventId)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if ev.UserId != userId {
+		writeProblem(w, http.StatusForbidden, "Only organizer can see reminders")
+		return
+	}
+	triggers, err := es.Reminders.GetTriggers(ctx, eventId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(triggers)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
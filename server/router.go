@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+)
+
+// router dispatch requests by patterns of http.ServeMux, like "GET /api/event/{id}", GET routes serve HEAD too.
+// Unknown path and method are answered with problem instead of plain text of ServeMux
+type router struct {
+	mux *http.ServeMux
+}
+
+func newRouter() *router {
+	return &router{mux: http.NewServeMux()}
+}
+
+// Handle register handler of pattern, pattern without method serves all methods, like CalDAV
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// HandleFunc register handler function of pattern
+func (rt *router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP call handler of matching route, path without routes is 404
+// and path with routes of other methods is 405 with Allow header
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := rt.mux.Handler(r)
+	if pattern != "" {
+		// mux sets path parameters when it serves request itself
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+	rec := &statusRecorder{header: http.Header{}}
+	h.ServeHTTP(rec, r)
+	if allow := rec.header.Get("Allow"); allow != "" {
+		w.Header().Set("Allow", allow)
+	}
+	if rec.status == http.StatusMethodNotAllowed {
+		writeProblem(w, http.StatusMethodNotAllowed, "Wrong method type")
+		return
+	}
+	writeProblem(w, http.StatusNotFound, "Path not found")
+}
+
+// statusRecorder keep status and headers of error of mux and drop its body
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (sr *statusRecorder) Header() http.Header {
+	return sr.header
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+}
+
+// pathParam return parameter of route pattern, like id of /api/event/{id}
+func pathParam(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
+
+// readOnly report whether request only reads, HEAD is served by GET routes
+func readOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
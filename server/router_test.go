@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"testing"
+)
+
+func TestRouterErrors(t *testing.T) {
+	es, api := newTestAPI()
+	userId := uuid.New()
+
+	tests := []struct {
+		name      string
+		handler   http.Handler
+		method    string
+		path      string
+		status    int
+		wantAllow string
+	}{
+		{"unknown path", api, http.MethodGet, "/api/nothing", http.StatusNotFound, ""},
+		{"wrong method", api, http.MethodDelete, "/api/events", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"wrong method of path with parameter", api, http.MethodPost, "/api/event/" + uuid.NewString(), http.StatusMethodNotAllowed,
+			"DELETE, GET, HEAD, PATCH, PUT"},
+		{"wrong parameter", api, http.MethodGet, "/api/event/42", http.StatusBadRequest, ""},
+		{"wrong method without login", es.Handler, http.MethodGet, "/register", http.StatusMethodNotAllowed, "POST"},
+		{"unknown path without login", es.Handler, http.MethodGet, "/nothing", http.StatusNotFound, ""},
+		{"head of feed", es.Handler, http.MethodHead, "/feed/unknown.ics", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(tt.handler, userId, tt.method, tt.path, "")
+			if allow := w.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", allow, tt.wantAllow)
+			}
+			if tt.method != http.MethodHead {
+				assertProblem(t, w, tt.status)
+			} else if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestHeadOfGetRoutes(t *testing.T) {
+	_, api := newTestAPI()
+	userId := uuid.New()
+	id := createTestEvent(t, api, userId, testEvent)
+
+	for _, path := range []string{"/api/event/" + id, "/api/event/" + id + "/attendees", "/api/events"} {
+		get := serveAs(api, userId, http.MethodGet, path, "")
+		head := serveAs(api, userId, http.MethodHead, path, "")
+		if head.Code != http.StatusOK || head.Code != get.Code || head.Header().Get("ETag") != get.Header().Get("ETag") ||
+			head.Header().Get("content-type") != get.Header().Get("content-type") {
+			t.Errorf("HEAD %s = %d %v, GET = %d %v", path, head.Code, head.Header(), get.Code, get.Header())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"calendar/schedule"
+	"calendar/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultSuggestStep  = 15 * time.Minute
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+// suggestAttendee is attendee of meeting, working hours replace ones from profile of user
+type suggestAttendee struct {
+	Login     string `json:"login"`
+	WorkStart string `json:"workStart,omitempty"`
+	WorkEnd   string `json:"workEnd,omitempty"`
+}
+
+type suggestRequest struct {
+	Attendees []suggestAttendee `json:"attendees"`
+	Duration  string            `json:"duration"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Step      string            `json:"step,omitempty"`
+	Limit     int               `json:"limit,omitempty"`
+}
+
+// SuggestMeeting return ranked meeting slots for attendees (POST /api/schedule/suggest)
+func (es *EventServer) SuggestMeeting(w http.ResponseWriter, r *http.Request) {
+	var req suggestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	sr, err := req.toRequest()
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, a := range req.Attendees {
+		u, err := es.UserStore.GetUserByLogin(r.Context(), a.Login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			writeProblem(w, http.StatusBadRequest, "unknown login "+a.Login)
+			return
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		workStart, workEnd := u.WorkStart, u.WorkEnd
+		if a.WorkStart != "" || a.WorkEnd != "" {
+			workStart, workEnd = a.WorkStart, a.WorkEnd
+		}
+		wh, err := schedule.ParseWorkHours(workStart, workEnd)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, a.Login+": "+err.Error())
+			return
+		}
+		busy, err := es.busyOf(r.Context(), u, sr.From, sr.To)
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		sr.Attendees = append(sr.Attendees, schedule.Attendee{
+			Login:     u.Login,
+			Location:  userLocation(u),
+			WorkHours: wh,
+			Busy:      busy,
+		})
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(schedule.Suggest(sr))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// toRequest check values of request, attendees are added later
+func (req suggestRequest) toRequest() (schedule.Request, error) {
+	sr := schedule.Request{From: req.From, To: req.To, Step: defaultSuggestStep, Limit: defaultSuggestLimit}
+	if len(req.Attendees) == 0 || len(req.Attendees) > maxFreeBusyUsers {
+		return sr, errors.New("from 1 to 50 attendees can be requested")
+	}
+	if err := validateRange(req.From, req.To); err != nil {
+		return sr, err
+	}
+	var err error
+	if sr.Duration, err = time.ParseDuration(req.Duration); err != nil || sr.Duration <= 0 || sr.Duration > req.To.Sub(req.From) {
+		return sr, errors.New("duration must be positive and fit in range")
+	}
+	if req.Step != "" {
+		if sr.Step, err = time.ParseDuration(req.Step); err != nil || sr.Step < 5*time.Minute {
+			return sr, errors.New("step must be at least 5m")
+		}
+	}
+	if req.Limit < 0 || req.Limit > maxSuggestLimit {
+		return sr, errors.New("limit must be from 1 to 50")
+	}
+	if req.Limit > 0 {
+		sr.Limit = req.Limit
+	}
+	return sr, nil
+}
@@ -2,229 +2,476 @@ package server
 
 import (
 	"calendar/event"
+	"calendar/schedule"
 	"calendar/storage"
+	"calendar/stream"
 	"calendar/user"
-	"context"
+	"calendar/webhook"
 	"encoding/json"
-	"fmt"
-	"github.com/dgrijalva/jwt-go"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/gorilla/schema"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"strings"
-	"time"
 )
 
-const jsonContentType = "application/json"
+const (
+	jsonContentType = "application/json"
+	// mergePatchContentType is media type of JSON Merge Patch (RFC 7396)
+	mergePatchContentType = "application/merge-patch+json"
+)
 
 type EventServer struct {
-	Store     storage.EventStore
-	UserStore *storage.UserStorage
+	Store        storage.EventStore
+	UserStore    storage.UserStore
+	SessionStore storage.SessionStore
+	Reminders    storage.ReminderStore
+	Calendars    storage.CalendarStore
+	Webhooks     storage.WebhookStore
+	// Dispatcher sends changes of events to webhooks in background
+	Dispatcher *webhook.Dispatcher
+	// Broker sends changes of events to clients of /api/events/stream
+	Broker *stream.Broker
 	http.Handler
 }
 
-func NewEventServer(store storage.EventStore) *EventServer {
+func NewEventServer(store storage.EventStore, userStore storage.UserStore, sessionStore storage.SessionStore, reminders storage.ReminderStore,
+	calendars storage.CalendarStore, webhooks storage.WebhookStore, dispatcher *webhook.Dispatcher, broker *stream.Broker) *EventServer {
 
 	es := new(EventServer)
 
-	es.Store = store
-	es.UserStore = &storage.Users
+	// handlers see and change only events which logged in user has access to
+	es.Store = storage.NewAuthorizedEventStore(store, calendars)
+	es.UserStore = userStore
+	es.SessionStore = sessionStore
+	es.Reminders = reminders
+	es.Calendars = calendars
+	es.Webhooks = webhooks
+	es.Dispatcher = dispatcher
+	es.Broker = broker
 
-	privateRouter := http.NewServeMux()
-	privateRouter.HandleFunc("/api/event/", es.ServeEvent)
-	privateRouter.HandleFunc("/api/events", es.ServeEvents)
-	privateRouter.HandleFunc("/api/user", es.ServeUser)
+	api := es.apiRouter()
 
-	privatHandler := AuthMiddleware(privateRouter)
-	router := http.NewServeMux()
-	router.Handle("/api/", privatHandler)
-	router.HandleFunc("/login", es.Login)
-	router.HandleFunc("/logout", es.Logout)
+	router := newRouter()
+	router.Handle("/api/", AuthMiddleware(es.UserStore, es.SessionStore, api))
+	router.HandleFunc("POST /register", es.Register)
+	router.HandleFunc("POST /login", es.Login)
+	router.HandleFunc("POST /refresh", es.Refresh)
+	router.HandleFunc("GET /logout", es.Logout)
+	router.HandleFunc("POST /logout", es.Logout)
+	router.HandleFunc("GET /feed/{file}", es.ServeFeed)
+	// CalDAV has its own methods, like PROPFIND and REPORT
+	router.Handle(davPrefix, BasicAuthMiddleware(es.UserStore, es.SessionStore, http.HandlerFunc(es.ServeDAV)))
+	router.HandleFunc("/.well-known/caldav", es.WellKnownCalDAV)
 
 	es.Handler = router
 	es.Handler = PanicMiddleware(es.Handler)
 	return es
 }
 
+// apiRouter return routes of /api, they are served for logged in user
+func (es *EventServer) apiRouter() *router {
+	api := newRouter()
+	api.HandleFunc("POST /api/event", es.CreateEvent)
+	api.HandleFunc("GET /api/event/{id}", eventHandler(es.GetEvent))
+	api.HandleFunc("PUT /api/event/{id}", eventHandler(es.SaveEvent))
+	api.HandleFunc("PATCH /api/event/{id}", eventHandler(es.PatchEvent))
+	api.HandleFunc("DELETE /api/event/{id}", eventHandler(es.DeleteEvent))
+	api.HandleFunc("GET /api/event/{id}/occurrences/{date}", eventHandler(es.ServeOccurrence))
+	api.HandleFunc("PUT /api/event/{id}/occurrences/{date}", eventHandler(es.ServeOccurrence))
+	api.HandleFunc("DELETE /api/event/{id}/occurrences/{date}", eventHandler(es.ServeOccurrence))
+	api.HandleFunc("GET /api/event/{id}/attendees", eventHandler(es.ServeAttendees))
+	api.HandleFunc("POST /api/event/{id}/attendees", eventHandler(es.ServeAttendees))
+	api.HandleFunc("DELETE /api/event/{id}/attendees/{attendeeId}", eventHandler(es.ServeAttendees))
+	api.HandleFunc("PUT /api/event/{id}/rsvp", eventHandler(es.Respond))
+	api.HandleFunc("GET /api/event/{id}/reminders", eventHandler(es.ServeReminders))
+	api.HandleFunc("GET /api/events", es.ServeEvents)
+	api.HandleFunc("GET /api/events.ics", es.ServeEventsICS)
+	api.HandleFunc("POST /api/events/import", es.ImportEvents)
+	api.HandleFunc("GET /api/events/stream", es.StreamEvents)
+	api.HandleFunc("GET /api/events/changes", es.SyncEvents)
+	api.HandleFunc("GET /api/calendars", es.ListCalendars)
+	api.HandleFunc("POST /api/calendars", es.CreateCalendar)
+	api.HandleFunc("GET /api/calendars/{id}", es.calendarHandler(event.AccessFreeBusy, es.GetCalendar))
+	api.HandleFunc("PUT /api/calendars/{id}", es.calendarHandler(event.AccessOwner, es.UpdateCalendar))
+	api.HandleFunc("DELETE /api/calendars/{id}", es.calendarHandler(event.AccessOwner, es.DeleteCalendar))
+	api.HandleFunc("GET /api/calendars/{id}/shares", es.calendarHandler(event.AccessOwner, es.ListShares))
+	api.HandleFunc("PUT /api/calendars/{id}/shares/{login}", es.calendarHandler(event.AccessOwner, es.ShareCalendar))
+	api.HandleFunc("DELETE /api/calendars/{id}/shares/{login}", es.calendarHandler(event.AccessOwner, es.UnshareCalendar))
+	api.HandleFunc("PUT /api/user", es.ServeUser)
+	api.HandleFunc("PUT /api/user/password", es.ChangePassword)
+	api.HandleFunc("POST /api/user/feed", es.ServeFeedToken)
+	api.HandleFunc("DELETE /api/user/feed", es.ServeFeedToken)
+	api.HandleFunc("GET /api/sessions", es.ListSessions)
+	api.HandleFunc("DELETE /api/sessions", es.RevokeSessions)
+	api.HandleFunc("DELETE /api/sessions/{id}", es.RevokeSession)
+	api.HandleFunc("POST /api/freebusy", es.FreeBusy)
+	api.HandleFunc("POST /api/schedule/suggest", es.SuggestMeeting)
+	api.HandleFunc("GET /api/webhooks", es.ListWebhooks)
+	api.HandleFunc("POST /api/webhooks", es.CreateWebhook)
+	api.HandleFunc("DELETE /api/webhooks/{id}", webhookHandler(es.DeleteWebhook))
+	api.HandleFunc("GET /api/webhooks/{id}/deliveries", webhookHandler(es.ListDeliveries))
+	api.HandleFunc("POST /api/webhooks/{id}/deliveries/{deliveryId}/replay", webhookHandler(es.ReplayDelivery))
+	return api
+}
+
 func (es *EventServer) ServeEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		var filter event.EventFilter
-		var decoder = schema.NewDecoder()
-		fmt.Printf("%#v", r.URL.Query())
-		err := decoder.Decode(&filter, r.URL.Query())
-		if err != nil {
-			http.Error(w, "Error in GET parameters", http.StatusBadRequest)
-		} else {
-			log.Println("GET parameters : ", filter)
-		}
-		evs, err := es.Store.GetEvents(r.Context(), filter)
-		if err != nil {
-			http.Error(w, "something bad happen with db", http.StatusInternalServerError)
-		}
-		w.Header().Set("content-type", jsonContentType)
-		err = json.NewEncoder(w).Encode(evs)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-	} else {
-		http.Error(w, "Wrong Method Used!", http.StatusBadRequest)
+	filter, err := decodeFilter(r)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Error in GET parameters")
+		return
+	}
+	filter.IncludeShared = true
+	evs, err := es.Store.GetEvents(r.Context(), filter)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(evs)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 	}
-
 }
 
-func (es *EventServer) ServeEvent(w http.ResponseWriter, r *http.Request) {
-	eventId, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/api/event/"))
-	if err != nil {
-		es.SaveEvent(w, r, uuid.New())
+// decodeFilter read event.EventFilter from GET parameters
+func decodeFilter(r *http.Request) (event.EventFilter, error) {
+	var filter event.EventFilter
+	var decoder = schema.NewDecoder()
+	err := decoder.Decode(&filter, r.URL.Query())
+	if err == nil {
+		log.Println("GET parameters : ", filter)
 	}
-	switch r.Method {
-	case http.MethodPut:
-		es.SaveEvent(w, r, eventId)
-	case http.MethodGet:
-		es.GetEvent(r.Context(), w, eventId)
-	case http.MethodDelete:
-		es.DeleteEvent(r.Context(), w, eventId)
+	return filter, err
+}
+
+// eventHandler parse id of event from path for handler
+func eventHandler(handler func(w http.ResponseWriter, r *http.Request, id uuid.UUID)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(pathParam(r, "id"))
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong event id")
+			return
+		}
+		handler(w, r, id)
 	}
 }
 
 func (es *EventServer) ServeUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Wrong method type", http.StatusBadRequest)
-		return
-	}
 	var userEntity user.User
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Wrong body", http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, "Wrong body")
 		return
 	}
 	err = json.Unmarshal(body, &userEntity)
 	if err != nil {
-		http.Error(w, "Wrong entity", http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	if userEntity.WorkStart != "" || userEntity.WorkEnd != "" {
+		if _, err = schedule.ParseWorkHours(userEntity.WorkStart, userEntity.WorkEnd); err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong working hours")
+			return
+		}
+		err = es.UserStore.UpdateWorkHours(r.Context(), userId, userEntity.WorkStart, userEntity.WorkEnd)
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		// timezone is kept when only working hours are changed
+		if userEntity.Timezone == "" {
+			return
+		}
+	}
+	err = es.UserStore.UpdateTimezone(r.Context(), userId, userEntity.Timezone)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		writeProblem(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong Timezone")
+		return
+	}
+}
+
+// CreateEvent save new event, id of event is generated when JSON doesn't have it
+func (es *EventServer) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	ev, ok := decodeEvent(w, r)
+	if !ok {
 		return
 	}
-	err = es.UserStore.UpdateTimezone(userEntity.Login, userEntity.Timezone)
+	exists, err := es.Store.IsExist(r.Context(), ev.ID)
 	if err != nil {
-		http.Error(w, "Wrong Timezone", http.StatusBadRequest)
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	if exists {
+		writeProblem(w, http.StatusConflict, "Event already exists")
 		return
 	}
+	es.saveEvent(w, r, ev, nil)
 }
 
+// SaveEvent replace event by JSON of request
 func (es *EventServer) SaveEvent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
-	exists, err := es.Store.IsExist(r.Context(), id)
-	if err != nil {
-		http.Error(w, "something wrong happen", http.StatusInternalServerError)
+	ev, ok := decodeEvent(w, r)
+	if !ok {
 		return
 	}
-	if (exists && r.Method == http.MethodPost) || (!exists && r.Method == http.MethodPut) {
-		http.Error(w, "Wrong method type", http.StatusBadRequest)
+	ev.ID = id
+	old, err := es.Store.GetEventById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
-	var ev event.Event
+	es.saveEvent(w, r, ev, &old)
+}
 
+// PatchEvent change event by JSON Merge Patch (RFC 7396): fields of patch replace fields of event
+// and null removes field. Time of patch is in timezone of event
+func (es *EventServer) PatchEvent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+	if contentType != mergePatchContentType && contentType != jsonContentType {
+		writeProblem(w, http.StatusUnsupportedMediaType, "patch must be "+mergePatchContentType)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Wrong body", http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, "Wrong body")
 		return
 	}
-	err = ev.UnmarshalJSON(body)
+	old, err := es.Store.GetEventById(seriesContext(r.Context()), id)
 	if err != nil {
-		http.Error(w, "Wrong entity", http.StatusBadRequest)
+		writeStoreError(w, err)
 		return
 	}
-	ev, err = es.Store.Save(r.Context(), ev)
-	if err != nil {
-		http.Error(w, "something wrong happen", http.StatusInternalServerError)
+	ev, err := old.MergePatch(body)
+	if errors.Is(err, event.ErrNeverRepeats) {
+		writeProblem(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	jsonEvent, err := json.Marshal(&ev)
 	if err != nil {
-		w.WriteHeader(http.StatusInsufficientStorage)
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
 	}
-	w.Header().Set("content-type", jsonContentType)
+	es.saveEvent(w, r, ev, &old)
+}
 
-	_, err = w.Write(jsonEvent)
+// decodeEvent read event from JSON of request
+func decodeEvent(w http.ResponseWriter, r *http.Request) (event.Event, bool) {
+	var ev event.Event
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Wrong json response", http.StatusInternalServerError)
+		writeProblem(w, http.StatusBadRequest, "Wrong body")
+		return ev, false
+	}
+	err = ev.UnmarshalJSON(body)
+	if errors.Is(err, event.ErrNeverRepeats) {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return ev, false
 	}
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return ev, false
+	}
+	return ev, true
 }
 
-func (es *EventServer) GetEvent(ctx context.Context, w http.ResponseWriter, id uuid.UUID) {
-	ev, err := es.Store.GetEventById(ctx, id)
+// saveEvent save new event, or changed event when old is its stored version, to calendar of request.
+// Overlapping events are reported, with strict=true they are not allowed, like for room calendars
+func (es *EventServer) saveEvent(w http.ResponseWriter, r *http.Request, ev event.Event, old *event.Event) {
+	// store rejects the save when event is changed after old was read
+	ev.Version = 0
+	if old != nil {
+		if !matchVersion(r, old.Version) {
+			writeStaleVersion(w)
+			return
+		}
+		ev.Version = old.Version
+		// occurrence overrides and attendees are not read from JSON and must survive update of series
+		ev.Overrides = old.Overrides
+		ev.Attendees = old.Attendees
+		// event stays with its owner when it is changed by editor of shared calendar
+		ev.UserId = old.UserId
+		ev.ResourceName = old.ResourceName
+		if ev.CalendarId == uuid.Nil {
+			ev.CalendarId = old.CalendarOf()
+		}
+	}
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	cal, err := es.calendarOf(r.Context(), userId, ev.CalendarId)
+	if errors.Is(err, storage.ErrCalendarNotFound) || (old != nil && cal.UserId != ev.UserId) {
+		writeProblem(w, http.StatusBadRequest, "Wrong calendar")
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
 		return
 	}
-	w.Header().Set("content-type", jsonContentType)
-	jsonEvent, err := json.Marshal(&ev)
+	ev.CalendarId = cal.ID
+	if old == nil {
+		// event created in shared calendar belongs to owner of calendar
+		ev.UserId = cal.UserId
+		// new event gets timezone and reminders of calendar when it doesn't have them
+		if err = ev.ApplyCalendar(&cal); err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong entity")
+			return
+		}
+	}
+	conflicts, err := es.conflictsOf(r.Context(), &ev)
 	if err != nil {
-		w.WriteHeader(http.StatusInsufficientStorage)
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	if len(conflicts) > 0 && r.URL.Query().Get("strict") == "true" {
+		writeConflicts(w, conflicts)
+		return
 	}
-	_, err = w.Write(jsonEvent)
+	ev, err = es.Store.Save(r.Context(), ev)
 	if err != nil {
-		http.Error(w, "Wrong json response", http.StatusInternalServerError)
+		writeStoreError(w, err)
+		return
+	}
+	ev.Conflicts = conflicts
+	if old != nil {
+		es.publish(r.Context(), webhook.EventUpdated, &ev)
+		writeEvent(w, http.StatusOK, &ev)
+	} else {
+		es.publish(r.Context(), webhook.EventCreated, &ev)
+		writeEvent(w, http.StatusCreated, &ev)
 	}
 }
 
-func (es *EventServer) DeleteEvent(ctx context.Context, w http.ResponseWriter, id uuid.UUID) {
-	exist, err := es.Store.IsExist(ctx, id)
-	if err != nil || !exist {
-		http.Error(w, "Wrong json response", http.StatusNotFound)
+func (es *EventServer) GetEvent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	ev, err := es.Store.GetEventById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
-	err = es.Store.Delete(ctx, id)
+	writeEvent(w, http.StatusOK, &ev)
+}
+
+func (es *EventServer) DeleteEvent(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	ctx := r.Context()
+	ev, err := es.Store.GetEventById(ctx, id)
 	if err != nil {
-		http.Error(w, "something wrong happen", http.StatusInternalServerError)
+		writeStoreError(w, err)
+		return
+	}
+	if !matchVersion(r, ev.Version) {
+		writeStaleVersion(w)
+		return
+	}
+	if err = es.Store.Delete(ctx, id, ev.Version); err != nil {
+		writeStoreError(w, err)
 		return
 	}
+	es.publish(ctx, webhook.EventDeleted, &ev)
 }
 
 func (es *EventServer) Login(w http.ResponseWriter, r *http.Request) {
 	var creds user.Credentials
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	userEntity, err := es.UserStore.GetUserByLogin(r.Context(), creds.Username)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	// unknown user has empty hash, which is checked in the same time as real one
+	if !user.CheckPassword(userEntity.Password, creds.Password) {
+		writeProblem(w, http.StatusUnauthorized, "wrong login or password")
+		return
+	}
+	session, refreshToken, err := user.NewSession(userEntity.ID, r.UserAgent())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
 		return
 	}
-	userEntity, ok := storage.Users.GetUserByLogin(creds.Username)
+	err = es.SessionStore.CreateSession(r.Context(), session)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	err = setTokenCookies(w, userEntity, session, refreshToken)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+}
 
-	if !ok || userEntity.Password != creds.Password {
-		w.WriteHeader(http.StatusUnauthorized)
+// registeredUser is response of Register, hash of password and fields of db are not shown
+type registeredUser struct {
+	ID       uuid.UUID `json:"id"`
+	Login    string    `json:"login"`
+	Email    string    `json:"email"`
+	Timezone string    `json:"timezone"`
+}
+
+// Register create new user, only bcrypt hash of password is stored
+func (es *EventServer) Register(w http.ResponseWriter, r *http.Request) {
+	var reg user.Registration
+	err := json.NewDecoder(r.Body).Decode(&reg)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
 		return
 	}
-	if userEntity.Timezone == "" {
-		userEntity.Timezone = "UTC"
+	err = reg.Validate()
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	expirationTime := time.Now().Add(5 * time.Minute)
-	claims := &user.Claims{
-		ID:       userEntity.ID,
-		Username: creds.Username,
-		Timezone: userEntity.Timezone,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	exists, err := es.UserStore.IsExist(r.Context(), reg.Login)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if exists {
+		writeProblem(w, http.StatusConflict, "login is already taken")
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(user.JwtKey)
+	hash, err := user.HashPassword(reg.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
 		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Expires:  expirationTime,
-		HttpOnly: true,
+	userEntity, err := es.UserStore.Save(r.Context(), user.User{
+		Login:    reg.Login,
+		Email:    reg.Email,
+		Password: hash,
+		Timezone: reg.Timezone,
+	})
+	if errors.Is(err, storage.ErrUserExists) {
+		writeProblem(w, http.StatusConflict, "login is already taken")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusCreated, registeredUser{
+		ID:       userEntity.ID,
+		Login:    userEntity.Login,
+		Email:    userEntity.Email,
+		Timezone: userEntity.Timezone,
 	})
 }
 
+// Logout revoke current session and clear cookies, so neither access nor refresh token can be used again
 func (es *EventServer) Logout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Expires:  time.Now(),
-		HttpOnly: true,
-	})
+	if session, err := es.currentSession(r); err == nil {
+		err = es.SessionStore.RevokeSession(r.Context(), session.UserId, session.ID)
+		if err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+	}
+	clearTokenCookies(w)
 }
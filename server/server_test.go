@@ -3,74 +3,84 @@ package server
 import (
 	"bytes"
 	"calendar/event"
+	"calendar/storage"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/magiconair/properties/assert"
 	"net/http"
-	"net/http/httptest"
-	"reflect"
-	"sync"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
 
-type StubEventStorage struct {
-	store map[uuid.UUID]event.Event
-	lock  sync.RWMutex
-}
-
-func (s *StubEventStorage) GetEvents(ef event.EventFilter) []event.Event {
-	evs := make([]event.Event, len(s.store))
-	idx := 0
-	for _, ev := range s.store {
-		evs[idx] = ev
-		idx++
+// saveStubEvents save five events of user directly to store and return their ids sorted
+func saveStubEvents(t *testing.T, userId uuid.UUID) []uuid.UUID {
+	t.Helper()
+	ids := make([]uuid.UUID, 0, 5)
+	for i := 1; i <= 5; i++ {
+		ev, err := storage.NewEventStorage().Save(context.Background(), getStubEvent(i, userId))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ev.ID)
 	}
-	return evs
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
 }
 
-func (s *StubEventStorage) GetEventById(id uuid.UUID) event.Event {
-	return getStubEventById(int(id.ID()))
-}
-
-func (s *StubEventStorage) IsExist(id uuid.UUID) bool {
-	_, exist := s.store[id]
-	return exist
-}
-
-func (s *StubEventStorage) Delete(id uuid.UUID) {
-	delete(s.store, id)
-}
-
-func (s *StubEventStorage) Save(ev event.Event) {
-	s.store[ev.ID] = ev
+func getStubEvent(i int, userId uuid.UUID) event.Event {
+	loc, _ := time.LoadLocation("America/New_York")
+	return event.Event{
+		ID:          uuid.New(),
+		Title:       fmt.Sprintf("Test title %d", i),
+		Description: "Some description",
+		DateTime:    time.Date(2021, time.August, i, i, i, i, 0, loc),
+		Timezone:    loc.String(),
+		Duration:    time.Hour,
+		Notes:       "test note",
+		UserId:      userId,
+	}
 }
 
 func TestEvenServeEvent(t *testing.T) {
-	storage := NewStubEventStorage()
-	server := NewEventServer(storage)
+	_, api := newTestAPI()
+	userId := uuid.New()
+	ids := saveStubEvents(t, userId)
 	t.Run("test get event by id", func(t *testing.T) {
-		request := newGetEventByIdRequest("3")
-		response := httptest.NewRecorder()
-		server.ServeHTTP(response, request)
-		got := DecodeEventFromResponse(t, response.Body)
+		response := serveAs(api, userId, http.MethodGet, "/api/event/"+ids[2].String(), "")
 		assertStatus(t, response.Code, http.StatusOK)
-		assert.Equal(t, got, getStubEventById(3))
+		got := DecodeEventFromResponse(t, response.Body)
+		if got.ID != ids[2] || got.Description != "Some description" || got.Timezone != "UTC" {
+			t.Errorf("got event %+v", got)
+		}
+	})
+	t.Run("test event of other user", func(t *testing.T) {
+		response := serveAs(api, uuid.New(), http.MethodGet, "/api/event/"+ids[2].String(), "")
+		assertProblem(t, response, http.StatusNotFound)
 	})
 }
+
 func TestEvenServeEvents(t *testing.T) {
-	storage := NewStubEventStorage()
-	server := NewEventServer(storage)
+	_, api := newTestAPI()
+	userId := uuid.New()
+	ids := saveStubEvents(t, userId)
 	t.Run("test all events", func(t *testing.T) {
-		request := newGetEventsRequest()
-		response := httptest.NewRecorder()
-		server.ServeHTTP(response, request)
-		got := DecodeEventsFromResponse(t, response.Body)
+		response := serveAs(api, userId, http.MethodGet, "/api/events", "")
 		assertStatus(t, response.Code, http.StatusOK)
-		assertEqualEvents(t, got, storage.GetEvents(event.EventFilter{}))
+		got := DecodeEventsFromResponse(t, response.Body)
+		gotIds := make([]uuid.UUID, 0, len(got))
+		for _, ev := range got {
+			gotIds = append(gotIds, ev.ID)
+		}
+		sort.Slice(gotIds, func(i, j int) bool { return gotIds[i].String() < gotIds[j].String() })
+		if fmt.Sprint(gotIds) != fmt.Sprint(ids) {
+			t.Errorf("got events %v, want %v", gotIds, ids)
+		}
 	})
 }
+
 func DecodeEventFromResponse(t *testing.T, body *bytes.Buffer) (ev event.Event) {
 	t.Helper()
 	err := json.NewDecoder(body).Decode(&ev)
@@ -79,6 +89,7 @@ func DecodeEventFromResponse(t *testing.T, body *bytes.Buffer) (ev event.Event)
 	}
 	return
 }
+
 func DecodeEventsFromResponse(t *testing.T, body *bytes.Buffer) (evs []event.Event) {
 	t.Helper()
 	err := json.NewDecoder(body).Decode(&evs)
@@ -87,48 +98,6 @@ func DecodeEventsFromResponse(t *testing.T, body *bytes.Buffer) (evs []event.Eve
 	}
 	return
 }
-func newGetEventsRequest() *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, "/api/events/", nil)
-	return req
-}
-func newGetEventByIdRequest(id string) *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, "/api/event/"+id, nil)
-	return req
-}
-func NewStubEventStorage() *StubEventStorage {
-	return &StubEventStorage{FillNewStubEvents(), sync.RWMutex{}}
-}
-
-func FillNewStubEvents() map[uuid.UUID]event.Event {
-	s := map[uuid.UUID]event.Event{}
-	for i := 1; i <= 5; i++ {
-		s[createUUIDFromInt(i)] = getStubEventById(i)
-	}
-	return s
-}
-func createUUIDFromInt(i int) uuid.UUID {
-	arr := make([]byte, 16)
-	arr[16] = byte(i)
-	id, err := uuid.FromBytes(arr)
-	if err != nil {
-		panic("cant create uuid")
-	}
-	return id
-}
-func getStubEventById(i int) event.Event {
-	loc, _ := time.LoadLocation("America/New_York")
-	id := createUUIDFromInt(i)
-	return event.Event{
-		ID:          id,
-		Title:       fmt.Sprintf("Test title %d", i),
-		Description: "Some description",
-		DateTime:    time.Date(2021, time.August, i, i, i, i, 0, loc),
-		Timezone:    loc.String(),
-		Duration:    time.Hour,
-		Notes:       []string{"test note", "test note2"},
-		Unmarshaler: nil,
-	}
-}
 
 func assertStatus(t testing.TB, got, want int) {
 	t.Helper()
@@ -136,7 +105,27 @@ func assertStatus(t testing.TB, got, want int) {
 		t.Errorf("did not get correct status, got %d, want %d", got, want)
 	}
 }
-func assertEqualEvents(t testing.TB, a, b []event.Event) bool {
-	t.Helper()
-	return reflect.DeepEqual(a, b)
+
+func TestEventOfOtherUserIsNotFound(t *testing.T) {
+	_, api := newTestAPI()
+	owner, stranger := uuid.New(), uuid.New()
+	id := createTestEvent(t, api, owner, testEvent)
+	for _, path := range []string{"/api/event/" + id, "/api/event/" + uuid.New().String()} {
+		assertProblem(t, serveAs(api, stranger, http.MethodGet, path, ""), http.StatusNotFound)
+		assertProblem(t, serveAs(api, stranger, http.MethodPut, path, testEvent, "content-type", jsonContentType), http.StatusNotFound)
+		assertProblem(t, serveAs(api, stranger, http.MethodPut, path+"/rsvp", `{"status":"accepted"}`), http.StatusNotFound)
+		assertProblem(t, serveAs(api, stranger, http.MethodDelete, path, ""), http.StatusNotFound)
+	}
+
+	// import of event with the same UID gives stranger own copy
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + id + "\r\nDTSTART:20210802T090000Z\r\n" +
+		"DURATION:PT1H\r\nSUMMARY:Copy\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	w := serveAs(api, stranger, http.MethodPost, "/api/events/import", ics, "content-type", "text/calendar")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"created":1`) {
+		t.Fatalf("import: %d %s", w.Code, w.Body)
+	}
+	w = serveAs(api, owner, http.MethodGet, "/api/event/"+id, "")
+	if got := DecodeEventFromResponse(t, w.Body); got.Title != "Planning" {
+		t.Errorf("event of owner is changed by import: %+v", got)
+	}
 }
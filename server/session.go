@@ -0,0 +1,233 @@
+package server
+
+import (
+	"calendar/storage"
+	"calendar/user"
+	"encoding/json"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+const refreshCookie = "refresh_token"
+
+// setTokenCookies set short-lived access token of session and its refresh token
+func setTokenCookies(w http.ResponseWriter, userEntity user.User, session user.Session, refreshToken string) error {
+	if userEntity.Timezone == "" {
+		userEntity.Timezone = "UTC"
+	}
+	expirationTime := time.Now().Add(user.AccessTokenTTL)
+	claims := &user.Claims{
+		ID:        userEntity.ID,
+		SessionId: session.ID,
+		Username:  userEntity.Login,
+		Timezone:  userEntity.Timezone,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expirationTime.Unix(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(user.JwtKey)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, tokenCookie("token", tokenString, expirationTime))
+	http.SetCookie(w, tokenCookie(refreshCookie, refreshToken, session.ExpiresAt))
+	return nil
+}
+
+// tokenCookie return cookie of access or refresh token which scripts can't read, refresh token
+// is sent only from the site itself
+func tokenCookie(name, value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+	}
+	if name == refreshCookie {
+		c.SameSite = http.SameSiteStrictMode
+	}
+	return c
+}
+
+func clearTokenCookies(w http.ResponseWriter) {
+	for _, name := range []string{"token", refreshCookie} {
+		http.SetCookie(w, tokenCookie(name, "", time.Now()))
+	}
+}
+
+// currentSession find session of request by refresh token or, if there is no refresh cookie, by access token
+func (es *EventServer) currentSession(r *http.Request) (user.Session, error) {
+	if c, err := r.Cookie(refreshCookie); err == nil {
+		return es.SessionStore.GetSessionByRefreshHash(r.Context(), user.HashRefreshToken(c.Value))
+	}
+	c, err := r.Cookie("token")
+	if err != nil {
+		return user.Session{}, storage.ErrSessionNotFound
+	}
+	claims, err := parseToken(c.Value)
+	if err != nil {
+		return user.Session{}, storage.ErrSessionNotFound
+	}
+	return es.SessionStore.GetSession(r.Context(), claims.SessionId)
+}
+
+// Refresh exchange refresh token for new access and refresh tokens, every refresh token can be used once.
+// Reuse of already rotated token means that it was stolen, so the whole session is revoked
+func (es *EventServer) Refresh(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(refreshCookie)
+	if err != nil {
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+	hash := user.HashRefreshToken(c.Value)
+	session, err := es.SessionStore.GetSessionByRefreshHash(r.Context(), hash)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if session.RefreshHash != hash {
+		log.Println("reuse of rotated refresh token, session is revoked: ", session.ID)
+		err = es.SessionStore.RevokeSession(r.Context(), session.UserId, session.ID)
+		if err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+			log.Println("can't revoke session: ", err)
+		}
+		clearTokenCookies(w)
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+	if !session.IsActive(time.Now()) {
+		clearTokenCookies(w)
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+	userEntity, err := es.UserStore.GetUserById(r.Context(), session.UserId)
+	if err != nil {
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+
+	refreshToken, newHash, err := user.NewRefreshToken()
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	session.ExpiresAt = time.Now().UTC().Add(user.RefreshTokenTTL)
+	err = es.SessionStore.RotateSession(r.Context(), session.ID, hash, newHash, session.ExpiresAt)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		// concurrent refresh with the same token has won
+		writeProblem(w, http.StatusUnauthorized, "not authorized")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	err = setTokenCookies(w, userEntity, session, refreshToken)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+	}
+}
+
+// ListSessions list active sessions of user (GET /api/sessions)
+func (es *EventServer) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	currentId, _ := r.Context().Value("session_id").(uuid.UUID)
+	sessions, err := es.SessionStore.GetSessions(r.Context(), userId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentId
+	}
+	w.Header().Set("content-type", jsonContentType)
+	err = json.NewEncoder(w).Encode(sessions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ChangePassword check current password and set the new one (PUT /api/user/password). Other sessions are revoked,
+// CalDAV clients log in again with the new password and get new sessions
+func (es *EventServer) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var pc user.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&pc); err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	if err := pc.Validate(); err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	currentId, _ := r.Context().Value("session_id").(uuid.UUID)
+	u, err := es.UserStore.GetUserById(r.Context(), userId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if !user.CheckPassword(u.Password, pc.Password) {
+		writeProblem(w, http.StatusForbidden, "Wrong password")
+		return
+	}
+	hash, err := user.HashPassword(pc.NewPassword)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	if err = es.UserStore.UpdatePassword(r.Context(), userId, hash); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if err = es.SessionStore.RevokeSessions(r.Context(), userId, currentId); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions revoke all sessions of user except the current one (DELETE /api/sessions)
+func (es *EventServer) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	currentId, _ := r.Context().Value("session_id").(uuid.UUID)
+	err := es.SessionStore.RevokeSessions(r.Context(), userId, currentId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSession revoke one session of user (DELETE /api/sessions/{id}), cookies are cleared when it is the current one
+func (es *EventServer) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	currentId, _ := r.Context().Value("session_id").(uuid.UUID)
+	id, err := uuid.Parse(pathParam(r, "id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong session id")
+		return
+	}
+	err = es.SessionStore.RevokeSession(r.Context(), userId, id)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		writeProblem(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if id == currentId {
+		clearTokenCookies(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	// retryMillis is time client waits before reconnect
+	retryMillis     = 3000
+	catchUpPageSize = 500
+)
+
+// streamMessage is data of message of change stream, Event is omitted for deleted events
+type streamMessage struct {
+	Type    event.ChangeType `json:"type"`
+	EventId uuid.UUID        `json:"eventId"`
+	Event   *event.Event     `json:"event,omitempty"`
+}
+
+// StreamEvents send changes of events of logged in user as Server-Sent Events.
+// Message id is Seq of change, client which reconnects with Last-Event-ID gets changes it missed,
+// when they are already dropped from change log it gets "reset" message and must load events again
+func (es *EventServer) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || es.Broker == nil {
+		writeProblem(w, http.StatusNotImplemented, "Streaming is not supported")
+		return
+	}
+	lastId := int64(-1)
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastId, _ = strconv.ParseInt(v, 10, 64)
+	} else if v = r.URL.Query().Get("lastEventId"); v != "" {
+		lastId, _ = strconv.ParseInt(v, 10, 64)
+	}
+	ctx := r.Context()
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+
+	// subscription is made before reading the log, so changes between them are not lost
+	sub, pos, err := es.Broker.Subscribe(ctx, userId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	defer es.Broker.Unsubscribe(sub)
+	first, _, err := es.Broker.Log.ChangeBounds(ctx)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+
+	switch {
+	case lastId < 0 || lastId > pos:
+		// new client or id from other log starts from now
+		lastId = pos
+	case first > 0 && lastId < first-1:
+		// changes after lastId are dropped from log
+		fmt.Fprintf(w, "event: reset\nid: %d\ndata: {}\n\n", pos)
+		lastId = pos
+	}
+catchUp:
+	for lastId < pos {
+		changes, err := es.Broker.Log.GetChanges(ctx, userId, lastId, catchUpPageSize)
+		if err != nil {
+			log.Println("change stream: ", err)
+			return
+		}
+		if len(changes) == 0 {
+			break
+		}
+		for _, c := range changes {
+			if c.Seq > pos {
+				break catchUp
+			}
+			if !es.writeChange(w, r, c) {
+				return
+			}
+			lastId = c.Seq
+		}
+		if len(changes) < catchUpPageSize {
+			break
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c, ok := <-sub.C:
+			if !ok {
+				// subscriber is too slow or server stops, client reconnects with Last-Event-ID
+				return
+			}
+			if c.Seq <= lastId {
+				continue
+			}
+			if !es.writeChange(w, r, c) {
+				return
+			}
+			lastId = c.Seq
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeChange write change as message with the current state of event, false means connection is lost
+func (es *EventServer) writeChange(w http.ResponseWriter, r *http.Request, c event.Change) bool {
+	msg := streamMessage{Type: c.Type, EventId: c.EventId}
+	if c.Type != event.ChangeDeleted {
+		ev, err := es.Store.GetEventById(r.Context(), c.EventId)
+		if errors.Is(err, storage.ErrEventNotFound) || errors.Is(err, storage.ErrForbidden) {
+			// event is deleted later, its own change comes next, or user can't see it anymore
+			return true
+		}
+		if err != nil {
+			log.Println("change stream: ", err)
+			return false
+		}
+		msg.Event = &ev
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("change stream: ", err)
+		return false
+	}
+	_, err = fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", c.Type, c.Seq, data)
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/stream"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// maxSyncChanges is number of changes read by one sync request, client asks again while More is true
+const maxSyncChanges = 1000
+
+// syncResponse is result of sync. Events are created or updated events, Deleted are ids of events
+// which are deleted or which user doesn't see anymore. FullSync means Events are all events of user
+// and local copy must be replaced
+type syncResponse struct {
+	Events    []event.Event `json:"events"`
+	Deleted   []uuid.UUID   `json:"deleted"`
+	SyncToken string        `json:"syncToken"`
+	FullSync  bool          `json:"fullSync"`
+	More      bool          `json:"more"`
+}
+
+// SyncEvents return events changed since sync token from change log,
+// or all events of user when there is no token or changes after it are already dropped from log
+func (es *EventServer) SyncEvents(w http.ResponseWriter, r *http.Request) {
+	if es.Broker == nil {
+		writeProblem(w, http.StatusNotImplemented, "Sync is not supported")
+		return
+	}
+	since := int64(-1)
+	if token := r.URL.Query().Get("since"); token != "" {
+		var err error
+		if since, err = stream.ParseToken(token); err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong sync token")
+			return
+		}
+	}
+	ctx := r.Context()
+	// changes are read only up to position of broker, changes after it can be still uncommitted
+	pos, err := es.Broker.Position(ctx)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	first, _, err := es.Broker.Log.ChangeBounds(ctx)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if since < 0 || since > pos || (first > 0 && since < first-1) {
+		es.fullSync(w, r, pos)
+		return
+	}
+
+	userId, _ := ctx.Value("user_id").(uuid.UUID)
+	changes, err := es.Broker.Log.GetChanges(ctx, userId, since, maxSyncChanges)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	resp := syncResponse{Events: []event.Event{}, Deleted: []uuid.UUID{}}
+	// the latest change of every event wins
+	latest := map[uuid.UUID]event.ChangeType{}
+	order := make([]uuid.UUID, 0, len(changes))
+	next := pos
+	for _, c := range changes {
+		if c.Seq > pos {
+			break
+		}
+		if _, ok := latest[c.EventId]; !ok {
+			order = append(order, c.EventId)
+		}
+		latest[c.EventId] = c.Type
+		next = c.Seq
+	}
+	resp.More = len(changes) == maxSyncChanges && next < pos
+	if !resp.More {
+		next = pos
+	}
+
+	resp.Events = make([]event.Event, 0, len(order))
+	for _, id := range order {
+		if latest[id] == event.ChangeDeleted {
+			resp.Deleted = append(resp.Deleted, id)
+			continue
+		}
+		// series is read as it is, in its own timezone
+		ev, err := es.Store.GetEventById(seriesContext(ctx), id)
+		if errors.Is(err, storage.ErrEventNotFound) || errors.Is(err, storage.ErrForbidden) {
+			// event is deleted after position, its change comes with the next sync too,
+			// or user can't see it anymore
+			resp.Deleted = append(resp.Deleted, id)
+			continue
+		}
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+			return
+		}
+		if !ev.IsRecurring() {
+			if err = ev.ChangeTimezoneFromContext(ctx); err != nil {
+				writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+				return
+			}
+		}
+		resp.Events = append(resp.Events, ev)
+	}
+	resp.SyncToken = stream.EncodeToken(next)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// fullSync return all events of user, recurring events as series. Token is position read before events,
+// so changes made meanwhile are returned again by the next sync
+func (es *EventServer) fullSync(w http.ResponseWriter, r *http.Request, pos int64) {
+	series, err := es.storedSeries(r.Context(), event.EventFilter{IncludeShared: true})
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, syncResponse{
+		Events:    series,
+		Deleted:   []uuid.UUID{},
+		SyncToken: stream.EncodeToken(pos),
+		FullSync:  true,
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag return strong entity tag of event version
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchVersion check If-Match header of request against stored version of event.
+// Request without If-Match matches any version, weak tags never match
+func matchVersion(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	etag := versionETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStaleVersion write 412, event was changed since client read it
+func writeStaleVersion(w http.ResponseWriter) {
+	writeProblem(w, http.StatusPreconditionFailed, "Event is changed, reload it")
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"calendar/event"
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testEvent = `{"title":"Planning","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"1h"}`
+
+func TestEventVersions(t *testing.T) {
+	_, api := newTestAPI()
+	owner := uuid.New()
+	id := createTestEvent(t, api, owner, testEvent)
+	path := "/api/event/" + id
+
+	steps := []struct {
+		method  string
+		body    string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{http.MethodGet, "", "", http.StatusOK, `"1"`},
+		{http.MethodPut, testEvent, `"1"`, http.StatusOK, `"2"`},
+		{http.MethodPatch, `{"title":"Review"}`, `"1"`, http.StatusPreconditionFailed, ""},
+		{http.MethodPatch, `{"title":"Review"}`, `"2"`, http.StatusOK, `"3"`},
+		{http.MethodPut, testEvent, "", http.StatusOK, `"4"`},
+		{http.MethodPut, testEvent, `"3", W/"4"`, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, "", `"3"`, http.StatusPreconditionFailed, ""},
+		{http.MethodGet, "", "", http.StatusOK, `"4"`},
+		{http.MethodDelete, "", `"1", "4"`, http.StatusOK, ""},
+		{http.MethodGet, "", "", http.StatusNotFound, ""},
+	}
+	for i, s := range steps {
+		header := []string{"content-type", jsonContentType}
+		if s.ifMatch != "" {
+			header = append(header, "If-Match", s.ifMatch)
+		}
+		w := serveAs(api, owner, s.method, path, s.body, header...)
+		if s.status >= http.StatusBadRequest {
+			assertProblem(t, w, s.status)
+			continue
+		}
+		if w.Code != s.status || w.Header().Get("ETag") != s.etag {
+			t.Fatalf("step %d %s If-Match %s: %d ETag %s, want %d ETag %s", i, s.method, s.ifMatch, w.Code,
+				w.Header().Get("ETag"), s.status, s.etag)
+		}
+	}
+}
+
+func TestOccurrenceVersions(t *testing.T) {
+	_, api := newTestAPI()
+	owner := uuid.New()
+	id := createTestEvent(t, api, owner, `{"title":"Standup","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"15m","rrule":"FREQ=DAILY;COUNT=3"}`)
+	path := "/api/event/" + id + "/occurrences/20210803"
+	moved := `{"title":"Moved standup","time":"2021-08-03 10:00:00","timezone":"UTC","duration":"15m"}`
+
+	assertProblem(t, serveAs(api, owner, http.MethodPut, path, moved, "If-Match", `"2"`), http.StatusPreconditionFailed)
+	w := serveAs(api, owner, http.MethodPut, path, moved, "If-Match", `"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("change of occurrence: %d ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	// ETag of response is version of saved series, so the next conditional change succeeds
+	assertProblem(t, serveAs(api, owner, http.MethodDelete, path, "", "If-Match", `"1"`), http.StatusPreconditionFailed)
+	w = serveAs(api, owner, http.MethodDelete, path, "", "If-Match", w.Header().Get("ETag"))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("cancel of occurrence: %d ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	if w = serveAs(api, owner, http.MethodGet, "/api/event/"+id, ""); w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("ETag of series = %s", w.Header().Get("ETag"))
+	}
+}
+
+func TestSplitOfStaleSeriesKeepsNoNewSeries(t *testing.T) {
+	es, api := newTestAPI()
+	owner := uuid.New()
+	id := createTestEvent(t, api, owner, `{"title":"Standup","time":"2021-08-02 09:00:00","timezone":"UTC","duration":"15m","rrule":"FREQ=DAILY;COUNT=3"}`)
+	r := httptest.NewRequest(http.MethodPut, "/api/event/"+id+"/occurrences/20210803?range=thisandfuture", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "user_id", owner))
+	stale, err := es.Store.GetEventById(seriesContext(r.Context()), uuid.MustParse(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// series is changed after it is read, so it can't be finished
+	if w := serveAs(api, owner, http.MethodPatch, "/api/event/"+id, `{"title":"Daily"}`, "content-type", jsonContentType); w.Code != http.StatusOK {
+		t.Fatalf("change of series: %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	changed := event.Event{Title: "Moved standup", DateTime: time.Date(2021, time.August, 3, 10, 0, 0, 0, time.UTC), Timezone: "UTC", Duration: 15 * time.Minute}
+	es.splitSeries(w, r, stale, time.Date(2021, time.August, 3, 9, 0, 0, 0, time.UTC), changed)
+	assertProblem(t, w, http.StatusPreconditionFailed)
+	if cnt, _ := es.Store.Count(r.Context()); cnt != 1 {
+		t.Fatalf("events after failed split = %d, want 1", cnt)
+	}
+}
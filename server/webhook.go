@@ -0,0 +1,172 @@
+package server
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"calendar/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+type webhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// ListWebhooks list webhooks of logged in user without their secrets (GET /api/webhooks)
+func (es *EventServer) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	hooks, err := es.Webhooks.GetWebhooks(r.Context(), userId)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	// secret is shown only once, when webhook is created
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// CreateWebhook register webhook of logged in user (POST /api/webhooks)
+func (es *EventServer) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userId, _ := r.Context().Value("user_id").(uuid.UUID)
+	var req webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong entity")
+		return
+	}
+	hook, err := webhook.NewWebhook(r.Context(), userId, req.URL, req.Secret)
+	if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrPrivateURL) || errors.Is(err, webhook.ErrUnknownHost) ||
+		errors.Is(err, webhook.ErrInvalidSecret) {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something wrong happen")
+		return
+	}
+	if err = es.Webhooks.CreateWebhook(r.Context(), hook); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+// webhookHandler parse id of webhook from path for handler, handler gets id of logged in user too
+func webhookHandler(handler func(w http.ResponseWriter, r *http.Request, userId, hookId uuid.UUID)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hookId, err := uuid.Parse(pathParam(r, "id"))
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "Wrong webhook id")
+			return
+		}
+		userId, _ := r.Context().Value("user_id").(uuid.UUID)
+		handler(w, r, userId, hookId)
+	}
+}
+
+// DeleteWebhook delete webhook of logged in user (DELETE /api/webhooks/{id})
+func (es *EventServer) DeleteWebhook(w http.ResponseWriter, r *http.Request, userId, hookId uuid.UUID) {
+	err := es.Webhooks.DeleteWebhook(r.Context(), userId, hookId)
+	if err != nil {
+		webhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries list deliveries of webhook, the latest first (GET /api/webhooks/{id}/deliveries)
+func (es *EventServer) ListDeliveries(w http.ResponseWriter, r *http.Request, userId, hookId uuid.UUID) {
+	limit := defaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			writeProblem(w, http.StatusBadRequest, "limit must be from 1 to 200")
+			return
+		}
+		limit = n
+	}
+	if _, err := es.Webhooks.GetWebhook(r.Context(), userId, hookId); err != nil {
+		webhookError(w, err)
+		return
+	}
+	deliveries, err := es.Webhooks.GetDeliveries(r.Context(), userId, hookId, limit)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery send payload of delivery again as new delivery (POST /api/webhooks/{id}/deliveries/{deliveryId}/replay)
+func (es *EventServer) ReplayDelivery(w http.ResponseWriter, r *http.Request, userId, hookId uuid.UUID) {
+	id, err := uuid.Parse(pathParam(r, "deliveryId"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Wrong delivery id")
+		return
+	}
+	if _, err = es.Webhooks.GetWebhook(r.Context(), userId, hookId); err != nil {
+		webhookError(w, err)
+		return
+	}
+	delivery, err := es.Webhooks.GetDelivery(r.Context(), userId, id)
+	if err != nil || delivery.WebhookId != hookId {
+		webhookError(w, storage.ErrDeliveryNotFound)
+		return
+	}
+	replay := delivery.Replay(time.Now())
+	if err = es.Webhooks.CreateDeliveries(r.Context(), []webhook.Delivery{replay}); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+		return
+	}
+	if es.Dispatcher != nil {
+		es.Dispatcher.Wake()
+	}
+	writeJSON(w, http.StatusAccepted, replay)
+}
+
+func webhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrWebhookNotFound):
+		writeProblem(w, http.StatusNotFound, "Webhook not found")
+	case errors.Is(err, storage.ErrDeliveryNotFound):
+		writeProblem(w, http.StatusNotFound, "Delivery not found")
+	default:
+		writeProblem(w, http.StatusInternalServerError, "something bad happen with db")
+	}
+}
+
+// publish queue change of event for webhooks of its owner, failure doesn't fail request
+func (es *EventServer) publish(ctx context.Context, typ string, ev *event.Event) {
+	if es.Dispatcher == nil {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err == nil {
+		err = es.Dispatcher.Publish(ctx, ev.UserId, typ, body)
+	}
+	if err != nil {
+		log.Printf("webhooks: %s of event %s is not published: %v", typ, ev.ID, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("content-type", jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("can't write response: ", err)
+	}
+}
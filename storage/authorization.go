@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+// ErrForbidden is returned when logged in user has no access needed for event or calendar
+var ErrForbidden = errors.New("no access")
+
+// AuthorizedEventStore checks access of logged in user before events are read or changed.
+// Events seen only as free/busy are returned as busy blocks. Without user in context,
+// like in background workers, everything is allowed
+type AuthorizedEventStore struct {
+	EventStore
+	Calendars CalendarStore
+}
+
+// NewAuthorizedEventStore wrap events, shares of calendars are used to find access of user
+func NewAuthorizedEventStore(events EventStore, calendars CalendarStore) *AuthorizedEventStore {
+	return &AuthorizedEventStore{EventStore: events, Calendars: calendars}
+}
+
+// GetEventById return event which user can see. Event which user can't see at all is ErrEventNotFound
+// like missing one, so ids of events of other users are not revealed
+func (as *AuthorizedEventStore) GetEventById(ctx context.Context, id uuid.UUID) (event.Event, error) {
+	ev, err := as.EventStore.GetEventById(ctx, id)
+	if err != nil {
+		return ev, err
+	}
+	access, err := AccessTo(ctx, as.Calendars, &ev)
+	if err != nil {
+		return event.Event{}, err
+	}
+	if !access.Allows(event.AccessFreeBusy) {
+		return event.Event{}, ErrEventNotFound
+	}
+	if access == event.AccessFreeBusy {
+		ev = ev.BusyOnly()
+	}
+	return ev, nil
+}
+
+// GetEvents return events of user with events of shared calendars seen only as free/busy replaced by busy blocks.
+// Stores filter by title before access is known, so with title filter such events are dropped, their titles are hidden
+func (as *AuthorizedEventStore) GetEvents(ctx context.Context, ef event.EventFilter) ([]event.Event, error) {
+	evs, err := as.EventStore.GetEvents(ctx, ef)
+	if err != nil {
+		return nil, err
+	}
+	userId, ok := userIdFromContext(ctx)
+	if !ok {
+		return evs, nil
+	}
+	shares, err := as.Calendars.SharedWith(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	granted := make(map[uuid.UUID]event.Access, len(shares))
+	for _, s := range shares {
+		granted[s.CalendarId] = s.Access
+	}
+	visible := evs[:0]
+	for _, ev := range evs {
+		if ev.AccessOf(userId, granted[ev.CalendarOf()]) == event.AccessFreeBusy {
+			if ef.Title != "" {
+				continue
+			}
+			ev = ev.BusyOnly()
+		}
+		visible = append(visible, ev)
+	}
+	return visible, nil
+}
+
+// IsExist report whether event exists and user can see it, events of other users which user can't see
+// are reported as missing, so their ids are not revealed
+func (as *AuthorizedEventStore) IsExist(ctx context.Context, id uuid.UUID) (bool, error) {
+	if _, ok := userIdFromContext(ctx); !ok {
+		return as.EventStore.IsExist(ctx, id)
+	}
+	_, err := as.GetEventById(ctx, id)
+	if errors.Is(err, ErrEventNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Count return number of events of user, stores count only events of user in context
+func (as *AuthorizedEventStore) Count(ctx context.Context) (int, error) {
+	return as.EventStore.Count(ctx)
+}
+
+// Save event when user can edit it and its calendar. Event stays with its owner,
+// new event without owner belongs to user. Event which user can't see is ErrEventNotFound
+func (as *AuthorizedEventStore) Save(ctx context.Context, ev event.Event) (event.Event, error) {
+	userId, ok := userIdFromContext(ctx)
+	if !ok {
+		return as.EventStore.Save(ctx, ev)
+	}
+	old, err := as.EventStore.GetEventById(ctx, ev.ID)
+	switch {
+	case err == nil:
+		access, err := AccessTo(ctx, as.Calendars, &old)
+		if err != nil {
+			return ev, err
+		}
+		if !access.Allows(event.AccessFreeBusy) {
+			return ev, ErrEventNotFound
+		}
+		if !access.Allows(event.AccessEdit) || ev.UserId != old.UserId {
+			return ev, ErrForbidden
+		}
+	case errors.Is(err, ErrEventNotFound):
+		if ev.UserId == uuid.Nil {
+			ev.UserId = userId
+		}
+	default:
+		return ev, err
+	}
+	c, err := CalendarAccess(ctx, as.Calendars, ev.CalendarOf())
+	if err != nil {
+		return ev, err
+	}
+	// event can be only in calendar of its owner
+	if c.UserId != ev.UserId || !c.Access.Allows(event.AccessEdit) {
+		return ev, ErrForbidden
+	}
+	return as.EventStore.Save(ctx, ev)
+}
+
+// Delete event when user can edit it, event which user can't see is ErrEventNotFound
+func (as *AuthorizedEventStore) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	if _, ok := userIdFromContext(ctx); !ok {
+		return as.EventStore.Delete(ctx, id, version)
+	}
+	ev, err := as.EventStore.GetEventById(ctx, id)
+	if err != nil {
+		return err
+	}
+	access, err := AccessTo(ctx, as.Calendars, &ev)
+	if err != nil {
+		return err
+	}
+	if !access.Allows(event.AccessFreeBusy) {
+		return ErrEventNotFound
+	}
+	if !access.Allows(event.AccessEdit) {
+		return ErrForbidden
+	}
+	return as.EventStore.Delete(ctx, id, version)
+}
+
+// Respond change answer to invitation, user can answer only for itself. Event which user can't see
+// is ErrEventNotFound, not event.ErrNotAttendee
+func (as *AuthorizedEventStore) Respond(ctx context.Context, eventId, userId uuid.UUID, status event.PartStat) (event.Attendee, error) {
+	id, ok := userIdFromContext(ctx)
+	if !ok {
+		return as.EventStore.Respond(ctx, eventId, userId, status)
+	}
+	if id != userId {
+		return event.Attendee{}, ErrForbidden
+	}
+	if _, err := as.GetEventById(ctx, eventId); err != nil {
+		return event.Attendee{}, err
+	}
+	return as.EventStore.Respond(ctx, eventId, userId, status)
+}
+
+// AccessTo return access of logged in user to event: as owner, attendee or by share of calendar of event.
+// Without user in context it is access of owner
+func AccessTo(ctx context.Context, calendars CalendarStore, ev *event.Event) (event.Access, error) {
+	userId, ok := userIdFromContext(ctx)
+	if !ok || ev.UserId == userId {
+		return event.AccessOwner, nil
+	}
+	s, err := calendars.GetShare(ctx, ev.CalendarOf(), userId)
+	if err != nil && !errors.Is(err, ErrShareNotFound) {
+		return "", err
+	}
+	return ev.AccessOf(userId, s.Access), nil
+}
+
+// CalendarAccess return calendar by id with access of logged in user to it, default calendar
+// which isn't saved yet is returned too. Calendar of other user which is not shared is not found
+func CalendarAccess(ctx context.Context, calendars CalendarStore, id uuid.UUID) (event.Calendar, error) {
+	userId, scoped := userIdFromContext(ctx)
+	c, err := calendars.GetCalendar(ctx, id)
+	if errors.Is(err, ErrCalendarNotFound) && id == userId {
+		c, err = event.DefaultCalendar(userId, ""), nil
+	}
+	if err != nil {
+		return event.Calendar{}, err
+	}
+	if !scoped || c.UserId == userId {
+		c.Access = event.AccessOwner
+		return c, nil
+	}
+	s, err := calendars.GetShare(ctx, c.ID, userId)
+	if errors.Is(err, ErrShareNotFound) {
+		return event.Calendar{}, ErrCalendarNotFound
+	}
+	if err != nil {
+		return event.Calendar{}, err
+	}
+	c.Access = s.Access
+	return c, nil
+}
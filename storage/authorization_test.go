@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestAuthorizedGetEventsHidesTitlesOfFreeBusy(t *testing.T) {
+	ctx := context.Background()
+	mem := NewEventStorage()
+	as := NewAuthorizedEventStore(mem, mem)
+	owner, viewer, reader := uuid.New(), uuid.New(), uuid.New()
+	cal := event.Calendar{ID: uuid.New(), UserId: owner, Name: "Health"}
+	if err := mem.SaveCalendar(ctx, cal); err != nil {
+		t.Fatal(err)
+	}
+	_ = mem.SaveShare(ctx, event.Share{CalendarId: cal.ID, UserId: viewer, Access: event.AccessFreeBusy})
+	_ = mem.SaveShare(ctx, event.Share{CalendarId: cal.ID, UserId: reader, Access: event.AccessRead})
+	ev := event.Event{ID: uuid.New(), Title: "Doctor", DateTime: time.Now().UTC(), Timezone: "UTC", Duration: time.Hour,
+		UserId: owner, CalendarId: cal.ID}
+	if _, err := mem.Save(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		user  uuid.UUID
+		title string
+		want  []string
+	}{
+		{"free/busy without title filter", viewer, "", []string{event.BusyTitle}},
+		{"free/busy with matching title", viewer, "doctor", nil},
+		{"free/busy with busy title", viewer, "busy", nil},
+		{"read with matching title", reader, "doctor", []string{"Doctor"}},
+		{"owner with other title", owner, "dentist", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ef := event.EventFilter{Title: tt.title, Calendar: []string{cal.ID.String()}, IncludeShared: true}
+			evs, err := as.GetEvents(context.WithValue(ctx, "user_id", tt.user), ef)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(evs) != len(tt.want) {
+				t.Fatalf("GetEvents() = %d events, want %v", len(evs), tt.want)
+			}
+			for i := range evs {
+				if evs[i].Title != tt.want[i] {
+					t.Errorf("title = %q, want %q", evs[i].Title, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// sharedCalendar is calendar of owner shared with users for every access
+type sharedCalendar struct {
+	cal                                                 event.Calendar
+	owner, attendee, freeBusy, reader, editor, stranger uuid.UUID
+}
+
+func newSharedCalendar(t *testing.T, mem *InMemoryEventStorage) sharedCalendar {
+	sc := sharedCalendar{owner: uuid.New(), attendee: uuid.New(), freeBusy: uuid.New(), reader: uuid.New(),
+		editor: uuid.New(), stranger: uuid.New()}
+	sc.cal = event.Calendar{ID: uuid.New(), UserId: sc.owner, Name: "Work"}
+	ctx := context.Background()
+	if err := mem.SaveCalendar(ctx, sc.cal); err != nil {
+		t.Fatal(err)
+	}
+	for userId, access := range map[uuid.UUID]event.Access{
+		sc.freeBusy: event.AccessFreeBusy, sc.reader: event.AccessRead, sc.editor: event.AccessEdit} {
+		if err := mem.SaveShare(ctx, event.Share{CalendarId: sc.cal.ID, UserId: userId, Access: access}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sc
+}
+
+// event return new event in calendar with invited attendee
+func (sc sharedCalendar) event() event.Event {
+	ev := newTestEvent(sc.owner)
+	ev.CalendarId = sc.cal.ID
+	ev.Invite(event.Attendee{UserId: sc.attendee, Login: "guest"})
+	return ev
+}
+
+// userContext return context of user, uuid.Nil is context without user, like of background workers
+func userContext(userId uuid.UUID) context.Context {
+	if userId == uuid.Nil {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), "user_id", userId)
+}
+
+func TestAccessTo(t *testing.T) {
+	mem := NewEventStorage()
+	sc := newSharedCalendar(t, mem)
+	ev := sc.event()
+
+	tests := []struct {
+		name string
+		user uuid.UUID
+		want event.Access
+	}{
+		{"without user", uuid.Nil, event.AccessOwner},
+		{"owner", sc.owner, event.AccessOwner},
+		{"attendee", sc.attendee, event.AccessRead},
+		{"free/busy grantee", sc.freeBusy, event.AccessFreeBusy},
+		{"read grantee", sc.reader, event.AccessRead},
+		{"edit grantee", sc.editor, event.AccessEdit},
+		{"stranger", sc.stranger, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AccessTo(userContext(tt.user), mem, &ev)
+			if err != nil || got != tt.want {
+				t.Errorf("AccessTo() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarAccess(t *testing.T) {
+	mem := NewEventStorage()
+	sc := newSharedCalendar(t, mem)
+
+	tests := []struct {
+		name     string
+		user     uuid.UUID
+		calendar uuid.UUID
+		want     event.Access
+		wantErr  error
+	}{
+		{"without user", uuid.Nil, sc.cal.ID, event.AccessOwner, nil},
+		{"owner", sc.owner, sc.cal.ID, event.AccessOwner, nil},
+		{"default calendar which is not saved", sc.stranger, sc.stranger, event.AccessOwner, nil},
+		{"attendee without share", sc.attendee, sc.cal.ID, "", ErrCalendarNotFound},
+		{"free/busy grantee", sc.freeBusy, sc.cal.ID, event.AccessFreeBusy, nil},
+		{"read grantee", sc.reader, sc.cal.ID, event.AccessRead, nil},
+		{"edit grantee", sc.editor, sc.cal.ID, event.AccessEdit, nil},
+		{"stranger", sc.stranger, sc.cal.ID, "", ErrCalendarNotFound},
+		{"default calendar of other user", sc.stranger, sc.owner, "", ErrCalendarNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := CalendarAccess(userContext(tt.user), mem, tt.calendar)
+			if !errors.Is(err, tt.wantErr) || c.Access != tt.want {
+				t.Errorf("CalendarAccess() = %q, %v, want %q, %v", c.Access, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizedEventStore(t *testing.T) {
+	mem := NewEventStorage()
+	as := NewAuthorizedEventStore(mem, mem)
+	sc := newSharedCalendar(t, mem)
+
+	tests := []struct {
+		name       string
+		user       uuid.UUID
+		wantTitle  string
+		wantGet    error
+		wantExist  bool
+		wantChange error
+	}{
+		{"without user", uuid.Nil, "Planning", nil, true, nil},
+		{"owner", sc.owner, "Planning", nil, true, nil},
+		{"attendee", sc.attendee, "Planning", nil, true, ErrForbidden},
+		{"free/busy grantee", sc.freeBusy, event.BusyTitle, nil, true, ErrForbidden},
+		{"read grantee", sc.reader, "Planning", nil, true, ErrForbidden},
+		{"edit grantee", sc.editor, "Planning", nil, true, nil},
+		{"stranger", sc.stranger, "", ErrEventNotFound, false, ErrEventNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := mem.Save(context.Background(), sc.event())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := userContext(tt.user)
+
+			got, err := as.GetEventById(ctx, ev.ID)
+			if !errors.Is(err, tt.wantGet) || got.Title != tt.wantTitle {
+				t.Errorf("GetEventById() = %q, %v, want %q, %v", got.Title, err, tt.wantTitle, tt.wantGet)
+			}
+			if exist, err := as.IsExist(ctx, ev.ID); err != nil || exist != tt.wantExist {
+				t.Errorf("IsExist() = %v, %v, want %v", exist, err, tt.wantExist)
+			}
+			changed := ev
+			changed.Title = "Retro"
+			if _, err = as.Save(ctx, changed); !errors.Is(err, tt.wantChange) {
+				t.Errorf("Save() error = %v, want %v", err, tt.wantChange)
+			}
+			if err = as.Delete(ctx, ev.ID, 0); !errors.Is(err, tt.wantChange) {
+				t.Errorf("Delete() error = %v, want %v", err, tt.wantChange)
+			}
+			if exist, _ := mem.IsExist(context.Background(), ev.ID); exist != (tt.wantChange != nil) {
+				t.Errorf("event exists = %v after delete by %s", exist, tt.name)
+			}
+		})
+	}
+
+	// event of other user is neither found nor counted
+	if exist, err := as.IsExist(userContext(sc.stranger), uuid.New()); err != nil || exist {
+		t.Errorf("IsExist() of missing event = %v, %v", exist, err)
+	}
+	if n, err := as.Count(userContext(sc.stranger)); err != nil || n != 0 {
+		t.Errorf("Count() of stranger = %d, %v", n, err)
+	}
+}
+
+func TestAuthorizedSaveInCalendarOfOtherUser(t *testing.T) {
+	mem := NewEventStorage()
+	as := NewAuthorizedEventStore(mem, mem)
+	sc := newSharedCalendar(t, mem)
+
+	tests := []struct {
+		name    string
+		user    uuid.UUID
+		wantErr error
+	}{
+		{"owner", sc.owner, nil},
+		{"edit grantee", sc.editor, nil},
+		{"read grantee", sc.reader, ErrForbidden},
+		{"free/busy grantee", sc.freeBusy, ErrForbidden},
+		{"stranger", sc.stranger, ErrCalendarNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// new event created in shared calendar belongs to owner of calendar
+			ev := sc.event()
+			_, err := as.Save(userContext(tt.user), ev)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
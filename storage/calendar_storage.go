@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrCalendarNotFound is returned when calendar doesn't exist
+	ErrCalendarNotFound = errors.New("calendar not found")
+	// ErrShareNotFound is returned when calendar is not shared with user
+	ErrShareNotFound = errors.New("share not found")
+)
+
+// CalendarStore stores calendars of users, events refer to them by event.Event.CalendarId
+type CalendarStore interface {
+	// GetCalendars return calendars of user, default calendar first and the others from the oldest
+	GetCalendars(ctx context.Context, userId uuid.UUID) ([]event.Calendar, error)
+	GetCalendar(ctx context.Context, id uuid.UUID) (event.Calendar, error)
+	// SaveCalendar create or update calendar
+	SaveCalendar(ctx context.Context, c event.Calendar) error
+	// DeleteCalendar remove calendar with its shares, its events must be deleted before
+	DeleteCalendar(ctx context.Context, id uuid.UUID) error
+	// CalendarEvents return ids of events in calendar
+	CalendarEvents(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+
+	// GetShares return users calendar is shared with, the oldest first
+	GetShares(ctx context.Context, calendarId uuid.UUID) ([]event.Share, error)
+	GetShare(ctx context.Context, calendarId, userId uuid.UUID) (event.Share, error)
+	// SharedWith return calendars shared with user
+	SharedWith(ctx context.Context, userId uuid.UUID) ([]event.Share, error)
+	// SaveShare grant access or change it, events of calendar are written to change log of user
+	SaveShare(ctx context.Context, s event.Share) error
+	// DeleteShare revoke access, events of calendar are written to change log of user as deleted
+	DeleteShare(ctx context.Context, calendarId, userId uuid.UUID) error
+}
+
+// GetCalendars return calendars of user
+func (i *InMemoryEventStorage) GetCalendars(ctx context.Context, userId uuid.UUID) ([]event.Calendar, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	calendars := make([]event.Calendar, 0)
+	for _, c := range i.calendars {
+		if c.UserId == userId {
+			calendars = append(calendars, c)
+		}
+	}
+	sortCalendars(calendars)
+	return calendars, nil
+}
+
+// GetCalendar return calendar by id
+func (i *InMemoryEventStorage) GetCalendar(ctx context.Context, id uuid.UUID) (event.Calendar, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	c, ok := i.calendars[id]
+	if !ok {
+		return c, ErrCalendarNotFound
+	}
+	return c, nil
+}
+
+// SaveCalendar create or update calendar
+func (i *InMemoryEventStorage) SaveCalendar(ctx context.Context, c event.Calendar) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.calendars[c.ID] = c
+	return nil
+}
+
+// DeleteCalendar remove calendar
+func (i *InMemoryEventStorage) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if _, ok := i.calendars[id]; !ok {
+		return ErrCalendarNotFound
+	}
+	delete(i.calendars, id)
+	delete(i.shares, id)
+	return nil
+}
+
+// CalendarEvents return ids of events in calendar
+func (i *InMemoryEventStorage) CalendarEvents(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	ids := make([]uuid.UUID, 0)
+	for _, ev := range i.store {
+		if ev.CalendarOf() == id {
+			ids = append(ids, ev.ID)
+		}
+	}
+	return ids, nil
+}
+
+// GetShares return shares of calendar
+func (i *InMemoryEventStorage) GetShares(ctx context.Context, calendarId uuid.UUID) ([]event.Share, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	shares := make([]event.Share, 0, len(i.shares[calendarId]))
+	for _, s := range i.shares[calendarId] {
+		shares = append(shares, s)
+	}
+	sortShares(shares)
+	return shares, nil
+}
+
+// GetShare return share of calendar with user
+func (i *InMemoryEventStorage) GetShare(ctx context.Context, calendarId, userId uuid.UUID) (event.Share, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	s, ok := i.shares[calendarId][userId]
+	if !ok {
+		return s, ErrShareNotFound
+	}
+	return s, nil
+}
+
+// SharedWith return shares of calendars with user
+func (i *InMemoryEventStorage) SharedWith(ctx context.Context, userId uuid.UUID) ([]event.Share, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	shares := make([]event.Share, 0)
+	for _, byUser := range i.shares {
+		if s, ok := byUser[userId]; ok {
+			shares = append(shares, s)
+		}
+	}
+	sortShares(shares)
+	return shares, nil
+}
+
+// SaveShare add or replace share
+func (i *InMemoryEventStorage) SaveShare(ctx context.Context, s event.Share) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.shares[s.CalendarId] == nil {
+		i.shares[s.CalendarId] = map[uuid.UUID]event.Share{}
+	}
+	old, existed := i.shares[s.CalendarId][s.UserId]
+	i.shares[s.CalendarId][s.UserId] = s
+	switch {
+	case !existed:
+		i.appendChanges(i.shareChanges(s.CalendarId, s.UserId, event.ChangeCreated))
+	case old.Access != s.Access:
+		i.appendChanges(i.shareChanges(s.CalendarId, s.UserId, event.ChangeUpdated))
+	}
+	return nil
+}
+
+// DeleteShare remove share
+func (i *InMemoryEventStorage) DeleteShare(ctx context.Context, calendarId, userId uuid.UUID) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if _, ok := i.shares[calendarId][userId]; !ok {
+		return ErrShareNotFound
+	}
+	delete(i.shares[calendarId], userId)
+	i.appendChanges(i.shareChanges(calendarId, userId, event.ChangeDeleted))
+	return nil
+}
+
+// isShared check if calendar is shared with user, lock must be held
+func (i *InMemoryEventStorage) isShared(calendarId, userId uuid.UUID) bool {
+	_, ok := i.shares[calendarId][userId]
+	return ok
+}
+
+// grantees return users calendar is shared with, lock must be held
+func (i *InMemoryEventStorage) grantees(calendarId uuid.UUID) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(i.shares[calendarId]))
+	for id := range i.shares[calendarId] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// shareChanges return changes of events of calendar for user whose share is changed, lock must be held
+func (i *InMemoryEventStorage) shareChanges(calendarId, userId uuid.UUID, typ event.ChangeType) []event.Change {
+	evs := make([]event.Event, 0)
+	for _, ev := range i.store {
+		if ev.CalendarOf() == calendarId {
+			evs = append(evs, ev)
+		}
+	}
+	return event.ShareChanges(evs, userId, typ, time.Now().UTC())
+}
+
+func sortShares(shares []event.Share) {
+	sort.Slice(shares, func(a, b int) bool { return shares[a].CreatedAt.Before(shares[b].CreatedAt) })
+}
+
+// sortCalendars put default calendar first and the others from the oldest
+func sortCalendars(calendars []event.Calendar) {
+	sort.Slice(calendars, func(a, b int) bool {
+		if calendars[a].IsDefault() != calendars[b].IsDefault() {
+			return calendars[a].IsDefault()
+		}
+		return calendars[a].CreatedAt.Before(calendars[b].CreatedAt)
+	})
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// GetCalendars return calendars of user
+func (i *repository) GetCalendars(ctx context.Context, userId uuid.UUID) ([]event.Calendar, error) {
+	calendars := make([]event.Calendar, 0)
+	err := i.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at").Find(&calendars).Error
+	sortCalendars(calendars)
+	return calendars, err
+}
+
+// GetCalendar return calendar by id
+func (i *repository) GetCalendar(ctx context.Context, id uuid.UUID) (event.Calendar, error) {
+	var c event.Calendar
+	err := i.db.WithContext(ctx).Where("id = ?", id).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c, ErrCalendarNotFound
+	}
+	return c, err
+}
+
+// SaveCalendar create or update calendar
+func (i *repository) SaveCalendar(ctx context.Context, c event.Calendar) error {
+	return i.db.WithContext(ctx).Save(&c).Error
+}
+
+// DeleteCalendar remove calendar and its shares
+func (i *repository) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&event.Calendar{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCalendarNotFound
+		}
+		return tx.Where("calendar_id = ?", id).Delete(&event.Share{}).Error
+	})
+}
+
+// CalendarEvents return ids of events in calendar
+func (i *repository) CalendarEvents(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	err := i.db.WithContext(ctx).Model(&event.Event{}).Where("calendar_id = ?", id).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetShares return shares of calendar
+func (i *repository) GetShares(ctx context.Context, calendarId uuid.UUID) ([]event.Share, error) {
+	shares := make([]event.Share, 0)
+	err := i.db.WithContext(ctx).Where("calendar_id = ?", calendarId).Order("created_at").Find(&shares).Error
+	return shares, err
+}
+
+// GetShare return share of calendar with user
+func (i *repository) GetShare(ctx context.Context, calendarId, userId uuid.UUID) (event.Share, error) {
+	var s event.Share
+	err := i.db.WithContext(ctx).Where("calendar_id = ? AND user_id = ?", calendarId, userId).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s, ErrShareNotFound
+	}
+	return s, err
+}
+
+// SharedWith return shares of calendars with user
+func (i *repository) SharedWith(ctx context.Context, userId uuid.UUID) ([]event.Share, error) {
+	shares := make([]event.Share, 0)
+	err := i.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at").Find(&shares).Error
+	return shares, err
+}
+
+// SaveShare add or replace share
+func (i *repository) SaveShare(ctx context.Context, s event.Share) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old event.Share
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("calendar_id = ? AND user_id = ?", s.CalendarId, s.UserId).Limit(1).Find(&old)
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.Save(&s).Error; err != nil {
+			return err
+		}
+		switch {
+		case result.RowsAffected == 0:
+			return shareChanges(tx, s.CalendarId, s.UserId, event.ChangeCreated)
+		case old.Access != s.Access:
+			return shareChanges(tx, s.CalendarId, s.UserId, event.ChangeUpdated)
+		}
+		return nil
+	})
+}
+
+// DeleteShare remove share
+func (i *repository) DeleteShare(ctx context.Context, calendarId, userId uuid.UUID) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("calendar_id = ? AND user_id = ?", calendarId, userId).Delete(&event.Share{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrShareNotFound
+		}
+		return shareChanges(tx, calendarId, userId, event.ChangeDeleted)
+	})
+}
+
+// grantees return users calendar is shared with
+func grantees(tx *gorm.DB, calendarId uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	err := tx.Model(&event.Share{}).Where("calendar_id = ?", calendarId).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// shareChanges write changes of events of calendar for user whose share is changed
+func shareChanges(tx *gorm.DB, calendarId, userId uuid.UUID, typ event.ChangeType) error {
+	evs := make([]event.Event, 0)
+	if err := tx.Preload("Attendees").Where("calendar_id = ?", calendarId).Find(&evs).Error; err != nil {
+		return err
+	}
+	return appendChanges(tx, event.ShareChanges(evs, userId, typ, time.Now().UTC()))
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// maxChanges is number of the latest records kept in change log
+const maxChanges = 10000
+
+// ChangeLog is bounded log of changes of events, written by EventStore.Save and Delete
+// in the same write as event, so every server with the same db sees all changes
+type ChangeLog interface {
+	// GetChanges return at most limit changes of user with Seq greater than after, the oldest first.
+	// Changes of all users are returned for uuid.Nil
+	GetChanges(ctx context.Context, userId uuid.UUID, after int64, limit int) ([]event.Change, error)
+	// ChangeBounds return Seq of the oldest kept change and of the latest one, zeros for empty log
+	ChangeBounds(ctx context.Context) (first, last int64, err error)
+}
+
+// appendChanges add changes to log and drop the oldest ones over maxChanges
+func (i *InMemoryEventStorage) appendChanges(changes []event.Change) {
+	for _, c := range changes {
+		i.seq++
+		c.Seq = i.seq
+		i.changes = append(i.changes, c)
+	}
+	if over := len(i.changes) - maxChanges; over > 0 {
+		i.changes = append([]event.Change(nil), i.changes[over:]...)
+	}
+}
+
+// GetChanges return changes after Seq
+func (i *InMemoryEventStorage) GetChanges(ctx context.Context, userId uuid.UUID, after int64, limit int) ([]event.Change, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	start := sort.Search(len(i.changes), func(k int) bool { return i.changes[k].Seq > after })
+	changes := make([]event.Change, 0)
+	for _, c := range i.changes[start:] {
+		if len(changes) == limit {
+			break
+		}
+		if userId == uuid.Nil || c.UserId == userId {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+// ChangeBounds return bounds of log
+func (i *InMemoryEventStorage) ChangeBounds(ctx context.Context) (first, last int64, err error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if len(i.changes) == 0 {
+		return 0, i.seq, nil
+	}
+	return i.changes[0].Seq, i.changes[len(i.changes)-1].Seq, nil
+}
+
+// changesOf return change records for event which is saved or deleted now,
+// oldGrantees and grantees are users calendars of old and saved event are shared with
+func changesOf(old *event.Event, oldGrantees []uuid.UUID, ev *event.Event, grantees []uuid.UUID, deleted bool) []event.Change {
+	now := time.Now().UTC()
+	if deleted {
+		return event.DeleteChanges(ev, grantees, now)
+	}
+	return event.SaveChanges(old, oldGrantees, ev, grantees, now)
+}
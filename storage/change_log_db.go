@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// changeSeq is the only row with Seq of the latest change. It is locked by transaction which writes changes
+// until commit, so changes are committed in order of Seq, unlike auto increment which is allocated
+// before commit, leaves gaps after rollbacks and grows by auto_increment_increment
+type changeSeq struct {
+	ID  int8 `gorm:"primaryKey;autoIncrement:false"`
+	Seq int64
+}
+
+// changeSeqId is id of the row of changeSeq
+const changeSeqId = 1
+
+// initChangeSeq create counter of Seq when it is missing, it starts from the latest change in log
+func initChangeSeq(db *gorm.DB) error {
+	if err := db.AutoMigrate(&changeSeq{}); err != nil {
+		return err
+	}
+	var last int64
+	if err := db.Model(&event.Change{}).Select("COALESCE(MAX(seq), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&changeSeq{ID: changeSeqId, Seq: last}).Error
+}
+
+// appendChanges add changes to log in transaction of Save or Delete and drop the oldest ones over maxChanges.
+// It must be the last statement of transaction, because counter of Seq stays locked until commit
+func appendChanges(tx *gorm.DB, changes []event.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	var counter changeSeq
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, "id = ?", changeSeqId).Error; err != nil {
+		return err
+	}
+	for k := range changes {
+		counter.Seq++
+		changes[k].Seq = counter.Seq
+	}
+	if err := tx.Model(&counter).Update("seq", counter.Seq).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&changes).Error; err != nil {
+		return err
+	}
+	last := changes[len(changes)-1].Seq
+	return tx.Where("seq <= ?", last-maxChanges).Delete(&event.Change{}).Error
+}
+
+// GetChanges return changes after Seq
+func (i *repository) GetChanges(ctx context.Context, userId uuid.UUID, after int64, limit int) ([]event.Change, error) {
+	changes := make([]event.Change, 0)
+	query := i.db.WithContext(ctx).Where("seq > ?", after)
+	if userId != uuid.Nil {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.Order("seq").Limit(limit).Find(&changes).Error
+	return changes, err
+}
+
+// ChangeBounds return bounds of log
+func (i *repository) ChangeBounds(ctx context.Context) (first, last int64, err error) {
+	var bounds struct {
+		First int64
+		Last  int64
+	}
+	err = i.db.WithContext(ctx).Model(&event.Change{}).Select("COALESCE(MIN(seq), 0) AS first, COALESCE(MAX(seq), 0) AS last").Scan(&bounds).Error
+	return bounds.First, bounds.Last, err
+}
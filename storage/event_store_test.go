@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"calendar/event"
+	"calendar/user"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestEvent return event of owner which starts in an hour
+func newTestEvent(owner uuid.UUID) event.Event {
+	return event.Event{ID: uuid.New(), Title: "Planning", DateTime: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		Timezone: "UTC", Duration: time.Hour, UserId: owner}
+}
+
+func TestRespond(t *testing.T) {
+	ctx := context.Background()
+	mem := NewEventStorage()
+	owner, guest := uuid.New(), uuid.New()
+	ev := newTestEvent(owner)
+	ev.Invite(event.Attendee{UserId: guest, Login: "guest"})
+	ev, err := mem.Save(ctx, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := NewAuthorizedEventStore(mem, mem)
+
+	if _, err = as.Respond(context.WithValue(ctx, "user_id", owner), ev.ID, guest, event.Accepted); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("answer for other user: %v", err)
+	}
+	if _, err = as.Respond(context.WithValue(ctx, "user_id", owner), ev.ID, owner, event.Accepted); !errors.Is(err, event.ErrNotAttendee) {
+		t.Fatalf("answer of organizer: %v", err)
+	}
+	if _, err = as.Respond(ctx, uuid.New(), guest, event.Accepted); !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("answer to missing event: %v", err)
+	}
+	a, err := as.Respond(context.WithValue(ctx, "user_id", guest), ev.ID, guest, event.Declined)
+	if err != nil || a.Status != event.Declined {
+		t.Fatalf("Respond() = %v, %v", a, err)
+	}
+	saved, _ := mem.GetEventById(ctx, ev.ID)
+	if saved.Attendee(guest).Status != event.Declined || saved.Title != ev.Title || saved.Version != ev.Version+1 {
+		t.Fatalf("event after answer = %+v", saved)
+	}
+}
+
+// testStores return in-memory store and db store when CALENDAR_TEST_DB_DSN is set, users in db are created by newOwner
+func testStores(t *testing.T) map[string]EventStore {
+	stores := map[string]EventStore{"memory": NewEventStorage()}
+	dsn := os.Getenv("CALENDAR_TEST_DB_DSN")
+	if dsn == "" {
+		return stores
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&user.User{}, &event.Event{}, &event.Override{}, &event.Attendee{}, &event.Reminder{},
+		&event.Trigger{}, &event.Change{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = initChangeSeq(db); err != nil {
+		t.Fatal(err)
+	}
+	stores["db"] = &repository{db}
+	return stores
+}
+
+// newOwner return id of user who can own events in store
+func newOwner(t *testing.T, store EventStore) uuid.UUID {
+	id := uuid.New()
+	if repo, ok := store.(*repository); ok {
+		if err := repo.db.Create(&user.User{ID: id, Login: id.String()}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func TestSaveAndDeleteCheckVersion(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ev, err := store.Save(ctx, newTestEvent(newOwner(t, store)))
+			if err != nil || ev.Version != 1 {
+				t.Fatalf("new event: version %d, %v", ev.Version, err)
+			}
+			ev.Title = "Review"
+			if ev, err = store.Save(ctx, ev); err != nil || ev.Version != 2 {
+				t.Fatalf("save of the latest version: version %d, %v", ev.Version, err)
+			}
+			stale := ev
+			stale.Version = 1
+			if _, err = store.Save(ctx, stale); !errors.Is(err, ErrStaleVersion) {
+				t.Fatalf("save of stale version: %v", err)
+			}
+			if err = store.Delete(ctx, ev.ID, 1); !errors.Is(err, ErrStaleVersion) {
+				t.Fatalf("delete of stale version: %v", err)
+			}
+			stored, _ := store.GetEventById(ctx, ev.ID)
+			if stored.Title != "Review" || stored.Version != 2 {
+				t.Fatalf("stale change is stored: %+v", stored)
+			}
+			if err = store.Delete(ctx, ev.ID, 2); err != nil {
+				t.Fatalf("delete of the latest version: %v", err)
+			}
+			if _, err = store.Save(ctx, ev); !errors.Is(err, ErrEventNotFound) {
+				t.Fatalf("save of deleted event: %v", err)
+			}
+		})
+	}
+}
+
+func TestSaveReturnsStoredEventInTimezoneOfUser(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			owner := newOwner(t, store)
+			ctx := context.WithValue(context.WithValue(context.Background(), "user_id", owner), "timezone", "Europe/Riga")
+			ev := newTestEvent(uuid.Nil)
+			saved, err := store.Save(ctx, ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the same event as GetEventById returns, so responses and ETags don't depend on store
+			stored, err := store.GetEventById(ctx, ev.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.UserId != owner || saved.Timezone != "Europe/Riga" || saved.DateTime.Location().String() != "Europe/Riga" ||
+				!saved.DateTime.Equal(ev.DateTime) || saved.Version != stored.Version || !saved.Equal(&stored) {
+				t.Fatalf("Save() = %+v, want %+v", saved, stored)
+			}
+		})
+	}
+}
+
+func TestConcurrentChangesOfVersion(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ev, err := store.Save(ctx, newTestEvent(newOwner(t, store)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// every writer read version 1, only one of them can change it
+			const writers = 8
+			errs := make(chan error, writers+1)
+			var wg sync.WaitGroup
+			for n := 0; n < writers; n++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					changed := ev
+					changed.Title = fmt.Sprintf("Planning %d", n)
+					_, err := store.Save(ctx, changed)
+					errs <- err
+				}(n)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- store.Delete(ctx, ev.ID, ev.Version)
+			}()
+			wg.Wait()
+			close(errs)
+
+			succeeded := 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case !errors.Is(err, ErrStaleVersion) && !errors.Is(err, ErrEventNotFound):
+					t.Fatalf("concurrent change: %v", err)
+				}
+			}
+			if succeeded != 1 {
+				t.Fatalf("%d concurrent changes of the same version succeeded", succeeded)
+			}
+			if stored, err := store.GetEventById(ctx, ev.ID); err == nil && stored.Version != 2 {
+				t.Fatalf("version after one change = %d", stored.Version)
+			}
+		})
+	}
+}
+
+func TestChangesOfSharedCalendar(t *testing.T) {
+	ctx := context.Background()
+	mem := NewEventStorage()
+	owner, reader := uuid.New(), uuid.New()
+	cal := event.Calendar{ID: uuid.New(), UserId: owner, Name: "Team"}
+	if err := mem.SaveCalendar(ctx, cal); err != nil {
+		t.Fatal(err)
+	}
+	ev := newTestEvent(owner)
+	ev.CalendarId = cal.ID
+	ev, err := mem.Save(ctx, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, after, _ := mem.ChangeBounds(ctx)
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   event.ChangeType
+	}{
+		{"calendar is shared", func() error {
+			return mem.SaveShare(ctx, event.Share{CalendarId: cal.ID, UserId: reader, Access: event.AccessFreeBusy})
+		}, event.ChangeCreated},
+		{"access is changed", func() error {
+			return mem.SaveShare(ctx, event.Share{CalendarId: cal.ID, UserId: reader, Access: event.AccessRead})
+		}, event.ChangeUpdated},
+		{"event is saved", func() error {
+			ev.Title = "Review"
+			ev, err = mem.Save(ctx, ev)
+			return err
+		}, event.ChangeUpdated},
+		{"calendar is unshared", func() error { return mem.DeleteShare(ctx, cal.ID, reader) }, event.ChangeDeleted},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		changes, err := mem.GetChanges(ctx, reader, after, 10)
+		if err != nil || len(changes) != 1 || changes[0].EventId != ev.ID || changes[0].Type != step.want {
+			t.Fatalf("%s: changes %+v, %v, want one %s", step.name, changes, err, step.want)
+		}
+		after = changes[0].Seq
+	}
+	if err = mem.Delete(ctx, ev.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := mem.GetChanges(ctx, reader, after, 10); len(changes) != 0 {
+		t.Errorf("changes after share is removed: %+v", changes)
+	}
+}
+
+func TestGetEventsFindsOccurrenceMovedIntoWindow(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			owner := newOwner(t, store)
+			start := time.Date(2021, time.September, 6, 9, 0, 0, 0, time.UTC)
+			ev := event.Event{ID: uuid.New(), Title: "Retro", DateTime: start, Timezone: "UTC", Duration: time.Hour,
+				RRule: "FREQ=WEEKLY;COUNT=3", UserId: owner}
+			// the first occurrence is moved a week earlier, before start of series
+			ev.Overrides = []event.Override{{EventId: ev.ID, RecurrenceId: start, Title: "Retro",
+				DateTime: start.AddDate(0, 0, -7), Timezone: "UTC", Duration: time.Hour}}
+			if _, err := store.Save(context.Background(), ev); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(context.Background(), "user_id", owner)
+			evs, err := store.GetEvents(ctx, event.EventFilter{DateFrom: "2021-08-30", DateTo: "2021-09-05", Timezone: "UTC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(evs) != 1 || !evs[0].DateTime.Equal(start.AddDate(0, 0, -7)) {
+				t.Fatalf("GetEvents() = %v, want occurrence moved into window", evs)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+)
+
+// filterParams keeps parsed values of event.EventFilter
+type filterParams struct {
+	loc *time.Location
+	// zoned is true when timezone is given by filter or context, not UTC by default
+	zoned    bool
+	dateFrom time.Time
+	dateTo   time.Time
+	timeFrom event.HoursMin
+	timeTo   event.HoursMin
+	// calendars are ids of calendars from filter, nil means all calendars
+	calendars map[uuid.UUID]bool
+}
+
+// newFilterParams parse filter values in timezone from filter or from context
+func newFilterParams(ctx context.Context, ef event.EventFilter) (fp filterParams, err error) {
+	if ef.Timezone != "" {
+		fp.loc, err = time.LoadLocation(ef.Timezone)
+	} else if v := ctx.Value("timezone"); v != nil {
+		fp.loc, err = time.LoadLocation(v.(string))
+	}
+
+	fp.zoned = err == nil && fp.loc != nil
+	if !fp.zoned {
+		fp.loc, _ = time.LoadLocation("UTC")
+	}
+
+	if ef.DateFrom != "" {
+		fp.dateFrom, err = time.ParseInLocation(shortForm, ef.DateFrom, fp.loc)
+		if err != nil {
+			log.Println("Wrong date from ", ef.DateFrom, err)
+			return fp, err
+		}
+	}
+
+	if ef.DateTo != "" {
+		fp.dateTo, err = time.ParseInLocation(shortForm, ef.DateTo, fp.loc)
+		if err != nil {
+			log.Println("Wrong date to ", ef.DateTo, err)
+			return fp, err
+		}
+	}
+
+	if ef.TimeFrom != "" {
+		fp.timeFrom, err = event.NewHoursMin(ef.TimeFrom)
+		if err != nil {
+			log.Println("Wrong time from ", ef.TimeFrom, err)
+			return fp, err
+		}
+	}
+
+	if ef.TimeTo != "" {
+		fp.timeTo, err = event.NewHoursMin(ef.TimeTo)
+		if err != nil {
+			log.Println("Wrong time to ", ef.TimeTo, err)
+			return fp, err
+		}
+	}
+
+	for _, value := range ef.Calendar {
+		for _, part := range strings.Split(value, ",") {
+			id, err := uuid.Parse(strings.TrimSpace(part))
+			if err != nil {
+				log.Println("Wrong calendar ", part, err)
+				return fp, err
+			}
+			if fp.calendars == nil {
+				fp.calendars = map[uuid.UUID]bool{}
+			}
+			fp.calendars[id] = true
+		}
+	}
+	return fp, nil
+}
+
+// inCalendars check if event is in calendars of filter
+func (fp *filterParams) inCalendars(ev *event.Event) bool {
+	return fp.calendars == nil || fp.calendars[ev.CalendarOf()]
+}
+
+// calendarIds return ids of calendars of filter
+func (fp *filterParams) calendarIds() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(fp.calendars))
+	for id := range fp.calendars {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// isFiltered check if event meet the criteria and move it to filter timezone
+func (fp *filterParams) isFiltered(ev *event.Event, ef event.EventFilter) bool {
+	return event.IsFiltered(ev, ef, fp.loc, &fp.dateFrom, &fp.dateTo, &fp.timeFrom, &fp.timeTo)
+}
+
+// filterOccurrences expand recurring event inside filter dates
+// and return occurrences which meet the criteria, series is returned as it is with event.EventFilter.Series
+func (fp *filterParams) filterOccurrences(ev event.Event, ef event.EventFilter) ([]event.Event, error) {
+	if ef.Series {
+		if ef.Title != "" && !strings.Contains(strings.ToLower(ev.Title), strings.ToLower(ef.Title)) {
+			return nil, nil
+		}
+		if fp.zoned {
+			ev.ChangeTimezone(fp.loc)
+		}
+		return []event.Event{ev}, nil
+	}
+	var from, to time.Time
+	if ef.DateFrom != "" {
+		from = fp.dateFrom
+	}
+	if ef.DateTo != "" {
+		to = fp.dateTo.Add(24 * time.Hour)
+	}
+	occurrences, err := ev.Occurrences(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := occurrences[:0]
+	for _, occ := range occurrences {
+		if fp.isFiltered(&occ, ef) {
+			events = append(events, occ)
+		}
+	}
+	return events, nil
+}
+
+// hoursMinString format HoursMin as "15:04" to compare with sql TIME_FORMAT
+func hoursMinString(hm event.HoursMin) string {
+	return fmt.Sprintf("%02d:%02d", hm.H, hm.M)
+}
+
+// userIdFromContext return id of logged in user if it is set
+func userIdFromContext(ctx context.Context) (uuid.UUID, bool) {
+	if v := ctx.Value("user_id"); v != nil {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			return id, true
+		}
+	}
+	return uuid.Nil, false
+}
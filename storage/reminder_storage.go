@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// ReminderStore stores triggers of reminders. Pending triggers are created by EventStore.Save
+// in the same write as event, so they survive restarts of server
+type ReminderStore interface {
+	// ClaimTriggers return pending triggers due at now and lock them till now+lease, so other schedulers skip them.
+	// Trigger which is not updated before lease is over is claimed again
+	ClaimTriggers(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]event.Trigger, error)
+	// UpdateTrigger save delivery state of claimed trigger and add next trigger of its reminder.
+	// Next trigger is skipped when trigger was removed meanwhile or reminder already has other pending trigger
+	UpdateTrigger(ctx context.Context, t event.Trigger, next *event.Trigger) error
+	// GetTriggers return triggers of event, the latest first
+	GetTriggers(ctx context.Context, eventId uuid.UUID) ([]event.Trigger, error)
+}
+
+// replaceTriggers remove pending triggers of event which are not due yet and add new ones.
+// Due triggers are kept, scheduler checks them against changed event before delivery
+func (i *InMemoryEventStorage) replaceTriggers(ev event.Event, now time.Time, triggers []event.Trigger) {
+	for id, t := range i.triggers {
+		if t.EventId == ev.ID && t.Status == event.TriggerPending && t.FireAt.After(now) {
+			delete(i.triggers, id)
+		}
+	}
+	for _, t := range triggers {
+		i.triggers[t.ID] = t
+	}
+}
+
+// ClaimTriggers return due pending triggers, the earliest first
+func (i *InMemoryEventStorage) ClaimTriggers(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]event.Trigger, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	due := make([]event.Trigger, 0)
+	for _, t := range i.triggers {
+		if t.Status == event.TriggerPending && !t.FireAt.After(now) && (t.LockedUntil == nil || t.LockedUntil.Before(now)) {
+			due = append(due, t)
+		}
+	}
+	sort.Slice(due, func(a, b int) bool { return due[a].FireAt.Before(due[b].FireAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	lockedUntil := now.Add(lease)
+	for k := range due {
+		due[k].LockedUntil = &lockedUntil
+		i.triggers[due[k].ID] = due[k]
+	}
+	return due, nil
+}
+
+// UpdateTrigger save trigger and add next one
+func (i *InMemoryEventStorage) UpdateTrigger(ctx context.Context, t event.Trigger, next *event.Trigger) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if _, ok := i.triggers[t.ID]; !ok {
+		return nil
+	}
+	i.triggers[t.ID] = t
+	if next == nil {
+		return nil
+	}
+	for _, other := range i.triggers {
+		if other.ReminderId == next.ReminderId && other.Status == event.TriggerPending {
+			return nil
+		}
+	}
+	i.triggers[next.ID] = *next
+	return nil
+}
+
+// GetTriggers return triggers of event
+func (i *InMemoryEventStorage) GetTriggers(ctx context.Context, eventId uuid.UUID) ([]event.Trigger, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	triggers := make([]event.Trigger, 0)
+	for _, t := range i.triggers {
+		if t.EventId == eventId {
+			triggers = append(triggers, t)
+		}
+	}
+	sort.Slice(triggers, func(a, b int) bool { return triggers[a].FireAt.After(triggers[b].FireAt) })
+	return triggers, nil
+}
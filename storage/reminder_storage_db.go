@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"calendar/event"
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// replaceTriggers remove pending triggers of event which are not due yet and add new ones in transaction of Save
+func replaceTriggers(tx *gorm.DB, ev event.Event, now time.Time, triggers []event.Trigger) error {
+	result := tx.Where("event_id = ? AND status = ? AND fire_at > ?", ev.ID, event.TriggerPending, now).Delete(&event.Trigger{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(triggers) == 0 {
+		return nil
+	}
+	return tx.Create(&triggers).Error
+}
+
+// ClaimTriggers lock due pending triggers, rows locked by other schedulers are skipped
+func (i *repository) ClaimTriggers(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]event.Trigger, error) {
+	var triggers []event.Trigger
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND fire_at <= ? AND (locked_until IS NULL OR locked_until < ?)", event.TriggerPending, now, now).
+			Order("fire_at").Limit(limit).Find(&triggers)
+		if result.Error != nil || len(triggers) == 0 {
+			return result.Error
+		}
+		ids := make([]uuid.UUID, 0, len(triggers))
+		for _, t := range triggers {
+			ids = append(ids, t.ID)
+		}
+		lockedUntil := now.Add(lease)
+		result = tx.Model(&event.Trigger{}).Where("id IN ?", ids).Update("locked_until", lockedUntil)
+		for k := range triggers {
+			triggers[k].LockedUntil = &lockedUntil
+		}
+		return result.Error
+	})
+	return triggers, err
+}
+
+// UpdateTrigger save delivery state of trigger and add next one
+func (i *repository) UpdateTrigger(ctx context.Context, t event.Trigger, next *event.Trigger) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&t).Select("status", "attempts", "last_error", "locked_until", "sent_at").Updates(&t)
+		if result.Error != nil || result.RowsAffected == 0 || next == nil {
+			return result.Error
+		}
+		var pending int64
+		result = tx.Model(&event.Trigger{}).Where("reminder_id = ? AND status = ?", next.ReminderId, event.TriggerPending).Count(&pending)
+		if result.Error != nil || pending > 0 {
+			return result.Error
+		}
+		return tx.Create(next).Error
+	})
+}
+
+// GetTriggers return triggers of event
+func (i *repository) GetTriggers(ctx context.Context, eventId uuid.UUID) ([]event.Trigger, error) {
+	triggers := make([]event.Trigger, 0)
+	result := i.db.WithContext(ctx).Where("event_id = ?", eventId).Order("fire_at DESC").Find(&triggers)
+	return triggers, result.Error
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"calendar/user"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned when session doesn't exist, belongs to other user or its refresh token is already rotated
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore stores login sessions of users
+type SessionStore interface {
+	CreateSession(ctx context.Context, s user.Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (user.Session, error)
+	// GetSessionByRefreshHash find session by hash of its current or previous refresh token
+	GetSessionByRefreshHash(ctx context.Context, hash string) (user.Session, error)
+	// RotateSession replace refresh token hash only if it is still oldHash
+	RotateSession(ctx context.Context, id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error
+	// GetSessions return active sessions of user
+	GetSessions(ctx context.Context, userId uuid.UUID) ([]user.Session, error)
+	RevokeSession(ctx context.Context, userId, id uuid.UUID) error
+	// RevokeSessions revoke all sessions of user except one, uuid.Nil revokes all
+	RevokeSessions(ctx context.Context, userId, except uuid.UUID) error
+}
+
+// InMemorySessionStorage collects sessions to map by id
+type InMemorySessionStorage struct {
+	store map[uuid.UUID]user.Session
+	lock  sync.RWMutex
+}
+
+// NewSessionStorage initialises an empty store
+func NewSessionStorage() *InMemorySessionStorage {
+	return &InMemorySessionStorage{store: map[uuid.UUID]user.Session{}}
+}
+
+// CreateSession add new session
+func (ss *InMemorySessionStorage) CreateSession(ctx context.Context, s user.Session) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.store[s.ID] = s
+	return nil
+}
+
+// GetSession return session by id
+func (ss *InMemorySessionStorage) GetSession(ctx context.Context, id uuid.UUID) (user.Session, error) {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	s, ok := ss.store[id]
+	if !ok {
+		return s, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// GetSessionByRefreshHash find session by hash of its current or previous refresh token
+func (ss *InMemorySessionStorage) GetSessionByRefreshHash(ctx context.Context, hash string) (user.Session, error) {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	for _, s := range ss.store {
+		if hash != "" && (s.RefreshHash == hash || s.PreviousHash == hash) {
+			return s, nil
+		}
+	}
+	return user.Session{}, ErrSessionNotFound
+}
+
+// RotateSession replace refresh token hash only if it is still oldHash
+func (ss *InMemorySessionStorage) RotateSession(ctx context.Context, id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	s, ok := ss.store[id]
+	if !ok || s.RefreshHash != oldHash || s.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	s.PreviousHash, s.RefreshHash = oldHash, newHash
+	s.LastUsedAt = time.Now().UTC()
+	s.ExpiresAt = expiresAt
+	ss.store[id] = s
+	return nil
+}
+
+// GetSessions return active sessions of user, recently used first
+func (ss *InMemorySessionStorage) GetSessions(ctx context.Context, userId uuid.UUID) ([]user.Session, error) {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	now := time.Now()
+	sessions := make([]user.Session, 0)
+	for _, s := range ss.store {
+		if s.UserId == userId && s.IsActive(now) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession revoke active session of user
+func (ss *InMemorySessionStorage) RevokeSession(ctx context.Context, userId, id uuid.UUID) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	s, ok := ss.store[id]
+	if !ok || s.UserId != userId || !s.IsActive(time.Now()) {
+		return ErrSessionNotFound
+	}
+	now := time.Now().UTC()
+	s.RevokedAt = &now
+	ss.store[id] = s
+	return nil
+}
+
+// RevokeSessions revoke all sessions of user except one, uuid.Nil revokes all
+func (ss *InMemorySessionStorage) RevokeSessions(ctx context.Context, userId, except uuid.UUID) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	now := time.Now().UTC()
+	for id, s := range ss.store {
+		if s.UserId == userId && id != except && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			ss.store[id] = s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"calendar/user"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewDbSessionStorage return session store which shares db connection with events store
+func NewDbSessionStorage(store *repository) *sessionRepository {
+	return &sessionRepository{store.db}
+}
+
+// CreateSession add new session
+func (sr *sessionRepository) CreateSession(ctx context.Context, s user.Session) error {
+	return sr.db.WithContext(ctx).Create(&s).Error
+}
+
+// GetSession return session by id
+func (sr *sessionRepository) GetSession(ctx context.Context, id uuid.UUID) (user.Session, error) {
+	var s user.Session
+	err := sr.db.WithContext(ctx).Where("id = ?", id).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s, ErrSessionNotFound
+	}
+	return s, err
+}
+
+// GetSessionByRefreshHash find session by hash of its current or previous refresh token
+func (sr *sessionRepository) GetSessionByRefreshHash(ctx context.Context, hash string) (user.Session, error) {
+	var s user.Session
+	if hash == "" {
+		return s, ErrSessionNotFound
+	}
+	err := sr.db.WithContext(ctx).Where("refresh_hash = ? OR previous_hash = ?", hash, hash).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s, ErrSessionNotFound
+	}
+	return s, err
+}
+
+// RotateSession replace refresh token hash only if it is still oldHash,
+// the condition in UPDATE makes concurrent refreshes with the same token fail except one
+func (sr *sessionRepository) RotateSession(ctx context.Context, id uuid.UUID, oldHash, newHash string, expiresAt time.Time) error {
+	result := sr.db.WithContext(ctx).Model(&user.Session{}).
+		Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"previous_hash": oldHash,
+			"refresh_hash":  newHash,
+			"last_used_at":  time.Now().UTC(),
+			"expires_at":    expiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// GetSessions return active sessions of user, recently used first
+func (sr *sessionRepository) GetSessions(ctx context.Context, userId uuid.UUID) ([]user.Session, error) {
+	sessions := make([]user.Session, 0)
+	result := sr.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now().UTC()).
+		Order("last_used_at DESC").Find(&sessions)
+	return sessions, result.Error
+}
+
+// RevokeSession revoke active session of user
+func (sr *sessionRepository) RevokeSession(ctx context.Context, userId, id uuid.UUID) error {
+	result := sr.db.WithContext(ctx).Model(&user.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userId, time.Now().UTC()).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeSessions revoke all sessions of user except one, uuid.Nil revokes all
+func (sr *sessionRepository) RevokeSessions(ctx context.Context, userId, except uuid.UUID) error {
+	return sr.db.WithContext(ctx).Model(&user.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userId, except).
+		Update("revoked_at", time.Now().UTC()).Error
+}
@@ -3,8 +3,8 @@ package storage
 import (
 	"calendar/event"
 	"context"
+	"errors"
 	"github.com/google/uuid"
-	"log"
 	"sync"
 	"time"
 )
@@ -15,20 +15,40 @@ const shortForm = "2006-01-02"
 var once sync.Once
 var instance *InMemoryEventStorage = nil
 
+// ErrEventNotFound is returned when there is no event with requested id
+var ErrEventNotFound = errors.New("event not found")
+
+// ErrStaleVersion is returned when event is changed or deleted by version which is not the latest one
+var ErrStaleVersion = errors.New("event is changed by someone else")
+
 // EventStore stores information about events
 type EventStore interface {
 	GetEvents(ctx context.Context, ef event.EventFilter) ([]event.Event, error)
 	GetEventById(ctx context.Context, id uuid.UUID) (event.Event, error)
 	IsExist(ctx context.Context, id uuid.UUID) (bool, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	// Delete event, with version other than 0 only this version of event is deleted
+	Delete(ctx context.Context, id uuid.UUID, version int64) error
+	// Save event, with ev.Version other than 0 only this version of event is replaced. Saved event has the next version
 	Save(ctx context.Context, ev event.Event) (event.Event, error)
+	// Respond set participation status of invited registered user and leave the rest of event as it is,
+	// event.ErrNotAttendee is returned when user is not invited
+	Respond(ctx context.Context, eventId, userId uuid.UUID, status event.PartStat) (event.Attendee, error)
 	Count(ctx context.Context) (int, error)
+	// MaxDuration return the longest duration of events and their moved occurrences,
+	// event can start so long before a time range and still last in it
+	MaxDuration(ctx context.Context) (time.Duration, error)
 }
 
 // NewEventStorage initialises an empty store only one time
 func NewEventStorage() *InMemoryEventStorage {
 	once.Do(func() {
-		instance = &InMemoryEventStorage{map[uuid.UUID]event.Event{}, &sync.RWMutex{}}
+		instance = &InMemoryEventStorage{
+			store:     map[uuid.UUID]event.Event{},
+			triggers:  map[uuid.UUID]event.Trigger{},
+			calendars: map[uuid.UUID]event.Calendar{},
+			shares:    map[uuid.UUID]map[uuid.UUID]event.Share{},
+			lock:      &sync.RWMutex{},
+		}
 	})
 	return instance
 }
@@ -36,100 +56,148 @@ func NewEventStorage() *InMemoryEventStorage {
 // InMemoryEventStorage collects events to map by id
 type InMemoryEventStorage struct {
 	store map[uuid.UUID]event.Event
+	// triggers are pending and delivered reminders by id
+	triggers map[uuid.UUID]event.Trigger
+	// calendars of all users by id
+	calendars map[uuid.UUID]event.Calendar
+	// shares are shares by id of calendar and id of user
+	shares map[uuid.UUID]map[uuid.UUID]event.Share
+	// changes is change log with the latest change last, seq is Seq of the latest change
+	changes []event.Change
+	seq     int64
 	// A mutex is used to synchronize read/write access to the map
 	lock *sync.RWMutex
 }
 
-// GetEventById return event by id
+// GetEventById return event by id or ErrEventNotFound
 func (i *InMemoryEventStorage) GetEventById(ctx context.Context, id uuid.UUID) (event.Event, error) {
 	i.lock.RLock()
 	defer i.lock.RUnlock()
-	ev := i.store[id]
+	stored, ok := i.store[id]
+	if !ok {
+		return event.Event{}, ErrEventNotFound
+	}
+	ev := copyEvent(stored)
+	if userId, ok := userIdFromContext(ctx); ok {
+		ev.AttachRsvp(userId)
+	}
 	err := ev.ChangeTimezoneFromContext(ctx)
 
 	return ev, err
 }
 
-// GetEvents return all events as slice
+// GetEvents return events of logged in user, including events the user is invited to, or all events without user in context
 func (i *InMemoryEventStorage) GetEvents(ctx context.Context, ef event.EventFilter) ([]event.Event, error) {
-	var dateFrom, dateTo time.Time
-	var timeFrom, timeTo event.HoursMin
+	fp, err := newFilterParams(ctx, ef)
+	if err != nil {
+		return nil, err
+	}
 
 	i.lock.RLock()
 	defer i.lock.RUnlock()
-	events := make([]event.Event, len(i.store))
-
-	var loc *time.Location
-	var err error
-	if ef.Timezone != "" {
-		loc, err = time.LoadLocation(ef.Timezone)
-	} else if v := ctx.Value("timezone"); v != nil {
-		loc, err = time.LoadLocation(v.(string))
-	}
-
-	if err != nil || loc == nil {
-		loc, _ = time.LoadLocation("UTC")
-	}
-
-	if ef.DateFrom != "" {
-		dateFrom, err = time.ParseInLocation(shortForm, ef.DateFrom, loc)
-		if err != nil {
-			log.Println("Wrong date from ", ef.DateFrom, err)
-			return nil, err
-		}
-	}
+	events := make([]event.Event, 0, len(i.store))
 
-	if ef.DateTo != "" {
-		dateTo, err = time.ParseInLocation(shortForm, ef.DateTo, loc)
-		if err != nil {
-			log.Println("Wrong date to ", ef.DateTo, err)
-			return nil, err
+	userId, scoped := userIdFromContext(ctx)
+	for _, ev := range i.store {
+		if scoped && ev.UserId != userId && ev.Attendee(userId) == nil &&
+			!(ef.IncludeShared && i.isShared(ev.CalendarOf(), userId)) {
+			continue
 		}
-	}
-
-	if ef.TimeFrom != "" {
-		timeFrom, err = event.NewHoursMin(ef.TimeFrom)
-		if err != nil {
-			log.Println("Wrong time from ", ef.TimeFrom, err)
-			return nil, err
+		if !fp.inCalendars(&ev) {
+			continue
 		}
-	}
-
-	if ef.TimeTo != "" {
-		timeTo, err = event.NewHoursMin(ef.TimeTo)
+		ev.AttachRsvp(userId)
+		occurrences, err := fp.filterOccurrences(ev, ef)
 		if err != nil {
-			log.Println("Wrong time to ", ef.TimeTo, err)
 			return nil, err
 		}
+		events = append(events, occurrences...)
 	}
-
-	idx := 0
-	for _, ev := range i.store {
-		if event.IsFiltered(&ev, ef, loc, &dateFrom, &dateTo, &timeFrom, &timeTo) {
-			events[idx] = ev
-			idx++
-		}
-	}
-	events = events[:idx]
 	return events, nil
 }
 
 // Save event to store
 func (i *InMemoryEventStorage) Save(ctx context.Context, ev event.Event) (event.Event, error) {
+	if ev.UserId == uuid.Nil {
+		if userId, ok := userIdFromContext(ctx); ok {
+			ev.UserId = userId
+		}
+	}
+	ev.CalendarId = ev.CalendarOf()
+	now := time.Now()
+	triggers, err := ev.Triggers(now)
+	if err != nil {
+		return ev, err
+	}
 	i.lock.Lock()
-	defer i.lock.Unlock()
-	i.store[ev.ID] = ev
+	var old *event.Event
+	stored, ok := i.store[ev.ID]
+	switch {
+	case ok && ev.Version != 0 && ev.Version != stored.Version:
+		i.lock.Unlock()
+		return ev, ErrStaleVersion
+	case ok:
+		old = &stored
+		ev.Version = stored.Version + 1
+	case ev.Version != 0:
+		// changed event is deleted meanwhile
+		i.lock.Unlock()
+		return ev, ErrEventNotFound
+	default:
+		ev.Version = 1
+	}
+	var oldGrantees []uuid.UUID
+	if old != nil {
+		oldGrantees = i.grantees(old.CalendarOf())
+	}
+	i.appendChanges(changesOf(old, oldGrantees, &ev, i.grantees(ev.CalendarOf()), false))
+	i.store[ev.ID] = copyEvent(ev)
+	i.replaceTriggers(ev, now, triggers)
+	i.lock.Unlock()
 	return i.GetEventById(ctx, ev.ID)
 }
 
-// Delete event from store
-func (i *InMemoryEventStorage) Delete(ctx context.Context, id uuid.UUID) error {
+// Delete event from store, ErrEventNotFound is returned when there is no such event
+func (i *InMemoryEventStorage) Delete(ctx context.Context, id uuid.UUID, version int64) error {
 	i.lock.Lock()
 	defer i.lock.Unlock()
+	ev, ok := i.store[id]
+	if !ok {
+		return ErrEventNotFound
+	}
+	if version != 0 && version != ev.Version {
+		return ErrStaleVersion
+	}
+	i.appendChanges(changesOf(nil, nil, &ev, i.grantees(ev.CalendarOf()), true))
 	delete(i.store, id)
+	for tid, t := range i.triggers {
+		if t.EventId == id && t.Status == event.TriggerPending {
+			delete(i.triggers, tid)
+		}
+	}
 	return nil
 }
 
+// Respond change status of attendee who is user, version of event is incremented
+func (i *InMemoryEventStorage) Respond(ctx context.Context, eventId, userId uuid.UUID, status event.PartStat) (event.Attendee, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	stored, ok := i.store[eventId]
+	if !ok {
+		return event.Attendee{}, ErrEventNotFound
+	}
+	ev := copyEvent(stored)
+	a, err := ev.Respond(userId, status)
+	if err != nil {
+		return a, err
+	}
+	ev.Version = stored.Version + 1
+	grantees := i.grantees(ev.CalendarOf())
+	i.appendChanges(changesOf(&stored, grantees, &ev, grantees, false))
+	i.store[eventId] = ev
+	return a, nil
+}
+
 // IsExist check if event already in store
 func (i *InMemoryEventStorage) IsExist(ctx context.Context, id uuid.UUID) (bool, error) {
 	i.lock.RLock()
@@ -142,5 +210,41 @@ func (i *InMemoryEventStorage) IsExist(ctx context.Context, id uuid.UUID) (bool,
 func (i *InMemoryEventStorage) Count(ctx context.Context) (int, error) {
 	i.lock.RLock()
 	defer i.lock.RUnlock()
-	return len(i.store), nil
+	userId, scoped := userIdFromContext(ctx)
+	if !scoped {
+		return len(i.store), nil
+	}
+	cnt := 0
+	for _, ev := range i.store {
+		if ev.UserId == userId {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+// MaxDuration return the longest duration of events and overrides in store
+func (i *InMemoryEventStorage) MaxDuration(ctx context.Context) (time.Duration, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	var longest time.Duration
+	for _, ev := range i.store {
+		if ev.Duration > longest {
+			longest = ev.Duration
+		}
+		for _, o := range ev.Overrides {
+			if o.Duration > longest {
+				longest = o.Duration
+			}
+		}
+	}
+	return longest, nil
+}
+
+// copyEvent copy overrides, attendees and reminders, so changes of returned event don't touch stored one
+func copyEvent(ev event.Event) event.Event {
+	ev.Overrides = append([]event.Override(nil), ev.Overrides...)
+	ev.Attendees = append([]event.Attendee(nil), ev.Attendees...)
+	ev.Reminders = append([]event.Reminder(nil), ev.Reminders...)
+	return ev
 }
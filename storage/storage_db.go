@@ -3,15 +3,22 @@ package storage
 import (
 	"calendar/event"
 	"calendar/user"
+	"calendar/webhook"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
 var repo *repository = nil
 
+// likeReplacer escape wildcards of LIKE pattern
+var likeReplacer = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
 type repository struct {
 	db *gorm.DB
 }
@@ -41,25 +48,121 @@ func NewDbStorage(dsn string, idleConn, maxConn int) (*repository, error) {
 		if err != nil {
 			return
 		}
+		err = repo.db.AutoMigrate(&event.Override{})
+		if err != nil {
+			return
+		}
+		err = repo.db.AutoMigrate(&event.Calendar{}, &event.Share{}, &event.Attendee{})
+		if err != nil {
+			return
+		}
+		err = repo.db.AutoMigrate(&event.Reminder{}, &event.Trigger{}, &event.Change{})
+		if err != nil {
+			return
+		}
+		err = initChangeSeq(repo.db)
+		if err != nil {
+			return
+		}
 		err = repo.db.AutoMigrate(&user.User{})
 		if err != nil {
 			return
 		}
+		err = repo.db.AutoMigrate(&user.Session{})
+		if err != nil {
+			return
+		}
+		err = repo.db.AutoMigrate(&webhook.Webhook{}, &webhook.Delivery{})
+		if err != nil {
+			return
+		}
 	})
 
 	return repo, nil
 }
 
+// Close close connections of db pool, in-flight queries are finished first
+func (i *repository) Close() error {
+	sqlDB, err := i.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// GetEventById return event by id or ErrEventNotFound
 func (i *repository) GetEventById(ctx context.Context, id uuid.UUID) (event.Event, error) {
 	var ev event.Event
-	i.db.First(&ev, id)
+	result := i.db.Preload("Overrides").Preload("Attendees").Preload("Reminders").First(&ev, "id = ?", id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return event.Event{}, ErrEventNotFound
+	}
+	if result.Error != nil {
+		return event.Event{}, result.Error
+	}
+	if userId, ok := userIdFromContext(ctx); ok {
+		ev.AttachRsvp(userId)
+	}
 	err := ev.ChangeTimezoneFromContext(ctx)
 	return ev, err
 }
 
-// GetEvents return all events as slice
+// GetEvents return events of logged in user, including events the user is invited to, filtered by event.EventFilter
 func (i *repository) GetEvents(ctx context.Context, ef event.EventFilter) ([]event.Event, error) {
-	return make([]event.Event, 1), nil
+	fp, err := newFilterParams(ctx, ef)
+	if err != nil {
+		return nil, err
+	}
+
+	// recurring events are fetched by title and start only,
+	// their occurrences are checked after expansion
+	query := i.visible(ctx, ef.IncludeShared)
+	if ef.Series {
+		// series are not expanded, so date and time criteria are not checked
+		ef.DateFrom, ef.DateTo, ef.TimeFrom, ef.TimeTo = "", "", "", ""
+	}
+	if ef.DateFrom != "" {
+		query = query.Where("(rrule <> '' OR time >= ?)", fp.dateFrom.UTC())
+	}
+	if ef.DateTo != "" {
+		// series which starts later can have occurrence moved into the window
+		to := fp.dateTo.Add(24 * time.Hour).UTC()
+		moved := i.db.Model(&event.Override{}).Select("event_id").Where("time < ? AND cancelled = ?", to, false)
+		query = query.Where("(time < ? OR id IN (?))", to, moved)
+	}
+	if fp.calendars != nil {
+		query = query.Where("calendar_id IN ?", fp.calendarIds())
+	}
+	if ef.Title != "" {
+		query = query.Where("LOWER(title) LIKE ?", "%"+likeReplacer.Replace(strings.ToLower(ef.Title))+"%")
+	}
+	// CONVERT_TZ returns NULL when mysql has no timezone tables loaded,
+	// such rows are kept and checked by event.IsFiltered below
+	localTime := "TIME_FORMAT(CONVERT_TZ(time, '+00:00', ?), '%H:%i')"
+	if ef.TimeFrom != "" {
+		query = query.Where("(rrule <> '' OR "+localTime+" IS NULL OR "+localTime+" >= ?)", fp.loc.String(), fp.loc.String(), hoursMinString(fp.timeFrom))
+	}
+	if ef.TimeTo != "" {
+		query = query.Where("(rrule <> '' OR "+localTime+" IS NULL OR "+localTime+" <= ?)", fp.loc.String(), fp.loc.String(), hoursMinString(fp.timeTo))
+	}
+
+	var found []event.Event
+	result := query.Preload("Overrides").Preload("Attendees").Preload("Reminders").Order("time").Find(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	userId, _ := userIdFromContext(ctx)
+	events := make([]event.Event, 0, len(found))
+	for _, ev := range found {
+		ev.AttachRsvp(userId)
+		occurrences, err := fp.filterOccurrences(ev, ef)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, occurrences...)
+	}
+	return events, nil
 }
 
 // Save event to store
@@ -68,35 +171,215 @@ func (i *repository) Save(ctx context.Context, ev event.Event) (event.Event, err
 	if err != nil {
 		return ev, err
 	}
-	if ev.UserId.String() == "00000000-0000-0000-0000-000000000000" {
-		if v := ctx.Value("user_id"); v != nil {
-			ev.UserId = v.(uuid.UUID)
+	if ev.UserId == uuid.Nil {
+		if userId, ok := userIdFromContext(ctx); ok {
+			ev.UserId = userId
 		}
 	}
+	ev.CalendarId = ev.CalendarOf()
 
-	var result *gorm.DB
-	if exist {
-		result = i.db.Save(&ev)
-	} else {
-		result = i.db.Create(&ev)
+	now := time.Now()
+	triggers, err := ev.Triggers(now)
+	if err != nil {
+		return ev, err
 	}
-	return ev, result.Error
+
+	err = i.db.Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		// previous organizer and attendees are needed for change log
+		var old *event.Event
+		if exist {
+			// row is locked until commit, so version can't be changed by concurrent save
+			old = &event.Event{}
+			result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Attendees").First(old, "id = ?", ev.ID)
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			if result.Error != nil {
+				return result.Error
+			}
+			if ev.Version != 0 && ev.Version != old.Version {
+				return ErrStaleVersion
+			}
+			ev.Version = old.Version + 1
+			result = tx.Omit("Overrides", "Attendees", "Reminders").Save(&ev)
+		} else if ev.Version != 0 {
+			// changed event is deleted meanwhile
+			return ErrEventNotFound
+		} else {
+			ev.Version = 1
+			result = tx.Omit("Overrides", "Attendees", "Reminders").Create(&ev)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		// overrides are replaced as a whole, so cancelled or split occurrences don't stay in db
+		result = tx.Where("event_id = ?", ev.ID).Delete(&event.Override{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(ev.Overrides) > 0 {
+			if result = tx.Create(&ev.Overrides); result.Error != nil {
+				return result.Error
+			}
+		}
+		result = tx.Where("event_id = ?", ev.ID).Delete(&event.Attendee{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(ev.Attendees) > 0 {
+			if result = tx.Create(&ev.Attendees); result.Error != nil {
+				return result.Error
+			}
+		}
+		result = tx.Where("event_id = ?", ev.ID).Delete(&event.Reminder{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(ev.Reminders) > 0 {
+			if result = tx.Create(&ev.Reminders); result.Error != nil {
+				return result.Error
+			}
+		}
+		if err := replaceTriggers(tx, ev, now, triggers); err != nil {
+			return err
+		}
+		var oldGrantees []uuid.UUID
+		if old != nil {
+			if oldGrantees, err = grantees(tx, old.CalendarOf()); err != nil {
+				return err
+			}
+		}
+		evGrantees, err := grantees(tx, ev.CalendarOf())
+		if err != nil {
+			return err
+		}
+		return appendChanges(tx, changesOf(old, oldGrantees, &ev, evGrantees, false))
+	})
+	if err != nil {
+		return ev, err
+	}
+	return i.GetEventById(ctx, ev.ID)
+}
+
+// Delete event from store, ErrEventNotFound is returned when there is no such event
+func (i *repository) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	return i.db.Transaction(func(tx *gorm.DB) error {
+		var ev event.Event
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Attendees").First(&ev, "id = ?", id)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrEventNotFound
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if version != 0 && version != ev.Version {
+			return ErrStaleVersion
+		}
+		// row is removed, not soft deleted, so event with the same id, like imported again, can be created
+		result = tx.Unscoped().Delete(&event.Event{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		result = tx.Where("event_id = ? AND status = ?", id, event.TriggerPending).Delete(&event.Trigger{})
+		if result.Error != nil {
+			return result.Error
+		}
+		evGrantees, err := grantees(tx, ev.CalendarOf())
+		if err != nil {
+			return err
+		}
+		return appendChanges(tx, changesOf(nil, nil, &ev, evGrantees, true))
+	})
 }
 
-// Delete event from store
-func (i *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	result := i.db.Delete(&event.Event{}, id)
-	return result.Error
+// Respond change status of attendee who is user, version of event is incremented
+func (i *repository) Respond(ctx context.Context, eventId, userId uuid.UUID, status event.PartStat) (event.Attendee, error) {
+	var a event.Attendee
+	err := i.db.Transaction(func(tx *gorm.DB) error {
+		var ev event.Event
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Attendees").First(&ev, "id = ?", eventId)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrEventNotFound
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		old := ev
+		old.Attendees = append([]event.Attendee(nil), ev.Attendees...)
+		var err error
+		if a, err = ev.Respond(userId, status); err != nil {
+			return err
+		}
+		result = tx.Model(&event.Attendee{}).Where("id = ?", a.ID).
+			Updates(map[string]interface{}{"status": a.Status, "updated_at": a.UpdatedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		result = tx.Model(&event.Event{}).Where("id = ?", eventId).Update("version", ev.Version+1)
+		if result.Error != nil {
+			return result.Error
+		}
+		evGrantees, err := grantees(tx, ev.CalendarOf())
+		if err != nil {
+			return err
+		}
+		return appendChanges(tx, changesOf(&old, evGrantees, &ev, evGrantees, false))
+	})
+	return a, err
 }
 
 // IsExist check if event already in store
 func (i *repository) IsExist(ctx context.Context, id uuid.UUID) (bool, error) {
-	var ev event.Event
-	result := i.db.First(&ev, id)
-	return result.RowsAffected > 0, result.Error
+	var cnt int64
+	result := i.db.Model(&event.Event{}).Where("id = ?", id).Count(&cnt)
+	return cnt > 0, result.Error
 }
 
 // Count return number of events in storage
 func (i *repository) Count(ctx context.Context) (int, error) {
-	return 0, nil
+	var cnt int64
+	result := i.scoped(ctx).Count(&cnt)
+	return int(cnt), result.Error
+}
+
+// MaxDuration return the longest duration of events and overrides in db
+func (i *repository) MaxDuration(ctx context.Context) (time.Duration, error) {
+	var events, overrides int64
+	result := i.db.WithContext(ctx).Model(&event.Event{}).Select("COALESCE(MAX(duration), 0)").Scan(&events)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	result = i.db.WithContext(ctx).Model(&event.Override{}).Select("COALESCE(MAX(duration), 0)").Scan(&overrides)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if overrides > events {
+		return time.Duration(overrides), nil
+	}
+	return time.Duration(events), nil
+}
+
+// visible return query of events which logged in user owns or is invited to, and of calendars shared with user when shared is true
+func (i *repository) visible(ctx context.Context, shared bool) *gorm.DB {
+	query := i.db.WithContext(ctx).Model(&event.Event{})
+	if userId, ok := userIdFromContext(ctx); ok {
+		invited := i.db.Model(&event.Attendee{}).Select("event_id").Where("user_id = ?", userId)
+		if shared {
+			calendars := i.db.Model(&event.Share{}).Select("calendar_id").Where("user_id = ?", userId)
+			return query.Where("(user_id = ? OR id IN (?) OR calendar_id IN (?))", userId, invited, calendars)
+		}
+		query = query.Where("(user_id = ? OR id IN (?))", userId, invited)
+	}
+	return query
+}
+
+// scoped return query of events which belong to logged in user,
+// all events are used if there is no user in context
+func (i *repository) scoped(ctx context.Context) *gorm.DB {
+	query := i.db.WithContext(ctx).Model(&event.Event{})
+	if userId, ok := userIdFromContext(ctx); ok {
+		query = query.Where("user_id = ?", userId)
+	}
+	return query
 }
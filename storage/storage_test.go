@@ -3,97 +3,86 @@ package storage
 import (
 	"calendar/event"
 	"context"
-	"reflect"
-	"sync"
+	"github.com/google/uuid"
 	"testing"
 	"time"
 )
 
-type StubEventStorage struct {
-	store map[int]event.Event
-	lock  *sync.RWMutex
+// saveStubEvents save five events of owner in August 2021 and return them
+func saveStubEvents(t *testing.T, i *InMemoryEventStorage, owner uuid.UUID) []event.Event {
+	t.Helper()
+	evs := make([]event.Event, 0, 5)
+	for n := 1; n <= 5; n++ {
+		ev, err := i.Save(context.Background(), getStubEvent(n, owner))
+		if err != nil {
+			t.Fatal(err)
+		}
+		evs = append(evs, ev)
+	}
+	return evs
 }
 
-func TestIsExist(t *testing.T) {
-	store := NewStubEventStorage()
-	type args struct {
-		id int
+func getStubEvent(n int, owner uuid.UUID) event.Event {
+	loc, _ := time.LoadLocation("America/New_York")
+	return event.Event{
+		ID:          uuid.New(),
+		Title:       "Test title",
+		Description: "Some descr",
+		DateTime:    time.Date(2021, time.August, n, n, n, n, 0, loc),
+		Timezone:    loc.String(),
+		Duration:    time.Hour,
+		Notes:       "test",
+		UserId:      owner,
 	}
+}
+
+func TestIsExist(t *testing.T) {
+	i := NewEventStorage()
+	evs := saveStubEvents(t, i, uuid.New())
 	tests := []struct {
-		name   string
-		fields StubEventStorage
-		args   args
-		want   bool
+		name string
+		id   uuid.UUID
+		want bool
 	}{
-		{"Check event by id 1", *store, args{1}, true},
-		{"Check event by id 5", *store, args{5}, true},
-		{"Check event by id 6", *store, args{6}, false},
+		{"Check the first event", evs[0].ID, true},
+		{"Check the last event", evs[4].ID, true},
+		{"Check unknown event", uuid.New(), false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			i := &InMemoryEventStorage{
-				store: tt.fields.store,
-				lock:  tt.fields.lock,
-			}
-			if got := i.IsExist(tt.args.id); got != tt.want {
-				t.Errorf("IsExist() = %v, want %v", got, tt.want)
+			if got, err := i.IsExist(context.Background(), tt.id); err != nil || got != tt.want {
+				t.Errorf("IsExist() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
 }
 
 func TestGetEventById(t *testing.T) {
-	store := NewStubEventStorage()
-	type args struct {
-		id int
-	}
+	i := NewEventStorage()
+	evs := saveStubEvents(t, i, uuid.New())
+	riga, _ := time.LoadLocation("Europe/Riga")
 	tests := []struct {
-		name   string
-		fields StubEventStorage
-		args   args
-		want   event.Event
+		name    string
+		id      uuid.UUID
+		want    event.Event
+		wantErr error
 	}{
-		{"Get event by id 1", *store, args{1}, GetStubEventById(1)},
-		{"Get event by id 5", *store, args{5}, GetStubEventById(5)},
-		{"Get event by id 6", *store, args{6}, event.Event{}},
+		{"Get the first event", evs[0].ID, evs[0], nil},
+		{"Get the last event", evs[4].ID, evs[4], nil},
+		{"Get unknown event", uuid.New(), event.Event{}, ErrEventNotFound},
 	}
 	ctx := context.WithValue(context.Background(), "timezone", "Europe/Riga")
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			i := &InMemoryEventStorage{
-				store: tt.fields.store,
-				lock:  tt.fields.lock,
+			got, err := i.GetEventById(ctx, tt.id)
+			if err != tt.wantErr || got.ID != tt.want.ID || !got.DateTime.Equal(tt.want.DateTime) {
+				t.Fatalf("GetEventById() = %v, %v, want %v", got, err, tt.want)
 			}
-			if got, _ := i.GetEventById(ctx, tt.args.id); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetEventById() = %v, want %v", got, tt.want)
+			// time is shown in timezone of user
+			if err == nil && (got.Timezone != "Europe/Riga" || got.DateTime.Location().String() != riga.String()) {
+				t.Errorf("GetEventById() time = %v in %s", got.DateTime, got.Timezone)
 			}
 		})
 	}
 }
-
-func NewStubEventStorage() *StubEventStorage {
-	return &StubEventStorage{FillNewStubEvents(), &sync.RWMutex{}}
-}
-
-func FillNewStubEvents() map[int]event.Event {
-	s := map[int]event.Event{}
-	for i := 1; i <= 5; i++ {
-		s[i] = GetStubEventById(i)
-	}
-	return s
-}
-
-func GetStubEventById(i int) event.Event {
-	loc, _ := time.LoadLocation("America/New_York")
-	return event.Event{
-		ID:          i,
-		Title:       "Test title " + string(rune(i)),
-		Description: "Some descr",
-		DateTime:    time.Date(2021, time.August, i, i, i, i, 0, loc),
-		Timezone:    loc,
-		Duration:    time.Hour,
-		Notes:       []string{"test", "test2"},
-		Unmarshaler: nil,
-	}
-}
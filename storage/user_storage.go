@@ -2,70 +2,150 @@ package storage
 
 import (
 	"calendar/user"
+	"context"
+	"errors"
 	"github.com/google/uuid"
 	"sync"
 	"time"
 )
 
-type UserStorage struct {
+// ErrUserNotFound is returned when there is no user with requested login, id or feed token
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when login is already taken
+var ErrUserExists = errors.New("user already exists")
+
+// UserStore stores information about users
+type UserStore interface {
+	GetUserByLogin(ctx context.Context, login string) (user.User, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (user.User, error)
+	GetUserByFeedHash(ctx context.Context, hash string) (user.User, error)
+	IsExist(ctx context.Context, login string) (bool, error)
+	Save(ctx context.Context, u user.User) (user.User, error)
+	UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error
+	UpdateFeedHash(ctx context.Context, id uuid.UUID, hash string) error
+	// UpdatePassword set bcrypt hash of the new password
+	UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error
+	UpdateWorkHours(ctx context.Context, id uuid.UUID, start, end string) error
+	Count(ctx context.Context) (int, error)
+	GetAll(ctx context.Context) ([]user.User, error)
+}
+
+// InMemoryUserStorage collects users to map by login
+type InMemoryUserStorage struct {
 	store map[string]user.User
 	lock  sync.RWMutex
 }
 
-var Users = UserStorage{
-	store: map[string]user.User{
-		"User1": {
-			ID:       uuid.New(),
-			Login:    "User1",
-			Email:    "user@ukr.net",
-			Password: "password1",
-			Timezone: "Europe/Athens",
-		}, "User2": {
-			ID:       uuid.New(),
-			Login:    "User2",
-			Email:    "user2@ukr.net",
-			Password: "password2",
-			Timezone: "Europe/Riga",
-		}},
+// NewUserStorage initialises an empty store
+func NewUserStorage() *InMemoryUserStorage {
+	return &InMemoryUserStorage{store: map[string]user.User{}}
 }
 
 // GetUserByLogin get user entity from storage by login
-func (us *UserStorage) GetUserByLogin(login string) (userEntity user.User, isExist bool) {
+func (us *InMemoryUserStorage) GetUserByLogin(ctx context.Context, login string) (user.User, error) {
+	us.lock.RLock()
+	defer us.lock.RUnlock()
+	userEntity, exist := us.store[login]
+	if !exist {
+		return userEntity, ErrUserNotFound
+	}
+	return userEntity, nil
+}
+
+// GetUserById get user entity from storage by id
+func (us *InMemoryUserStorage) GetUserById(ctx context.Context, id uuid.UUID) (user.User, error) {
+	return us.find(func(u user.User) bool { return u.ID == id })
+}
+
+// GetUserByFeedHash get user entity by hash of calendar feed token
+func (us *InMemoryUserStorage) GetUserByFeedHash(ctx context.Context, hash string) (user.User, error) {
+	return us.find(func(u user.User) bool { return hash != "" && u.FeedHash == hash })
+}
+
+func (us *InMemoryUserStorage) find(match func(u user.User) bool) (user.User, error) {
 	us.lock.RLock()
 	defer us.lock.RUnlock()
-	userEntity, isExist = us.store[login]
-	return
+	for _, userEntity := range us.store {
+		if match(userEntity) {
+			return userEntity, nil
+		}
+	}
+	return user.User{}, ErrUserNotFound
 }
 
-// IsExist check if event already in store
-func (us *UserStorage) IsExist(login string) bool {
+// IsExist check if user already in store
+func (us *InMemoryUserStorage) IsExist(ctx context.Context, login string) (bool, error) {
 	us.lock.RLock()
 	defer us.lock.RUnlock()
 	_, exist := us.store[login]
-	return exist
+	return exist, nil
+}
+
+// Save create new user or update existing one with the same id
+func (us *InMemoryUserStorage) Save(ctx context.Context, u user.User) (user.User, error) {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	if old, exist := us.store[u.Login]; exist && old.ID != u.ID {
+		return u, ErrUserExists
+	}
+	for login, old := range us.store {
+		if old.ID == u.ID && login != u.Login {
+			delete(us.store, login)
+		}
+	}
+	us.store[u.Login] = u
+	return u, nil
+}
+
+// UpdateTimezone set timezone of user if it is known location
+func (us *InMemoryUserStorage) UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return err
+	}
+	return us.update(id, func(u *user.User) { u.Timezone = timezone })
+}
+
+// UpdateFeedHash set hash of calendar feed token, empty hash revokes the feed
+func (us *InMemoryUserStorage) UpdateFeedHash(ctx context.Context, id uuid.UUID, hash string) error {
+	return us.update(id, func(u *user.User) { u.FeedHash = hash })
+}
+
+// UpdatePassword set hash of password
+func (us *InMemoryUserStorage) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	return us.update(id, func(u *user.User) { u.Password = hash })
+}
+
+// UpdateWorkHours set working hours of user, empty values remove them
+func (us *InMemoryUserStorage) UpdateWorkHours(ctx context.Context, id uuid.UUID, start, end string) error {
+	return us.update(id, func(u *user.User) { u.WorkStart, u.WorkEnd = start, end })
 }
 
-// UpdateTimezone
-func (us *UserStorage) UpdateTimezone(login string, timezone string) (err error) {
+func (us *InMemoryUserStorage) update(id uuid.UUID, change func(u *user.User)) error {
 	us.lock.Lock()
 	defer us.lock.Unlock()
-	if userEntity, exist := us.store[login]; exist == true {
-		if _, err = time.LoadLocation(timezone); err == nil {
-			userEntity.Timezone = timezone
+	for login, userEntity := range us.store {
+		if userEntity.ID == id {
+			change(&userEntity)
 			us.store[login] = userEntity
+			return nil
 		}
 	}
-	return
+	return ErrUserNotFound
 }
 
 // Count return number of users in storage
-func (us *UserStorage) Count() int {
+func (us *InMemoryUserStorage) Count(ctx context.Context) (int, error) {
 	us.lock.RLock()
 	defer us.lock.RUnlock()
-	return len(us.store)
+	return len(us.store), nil
 }
 
-func (us *UserStorage) GetAll() []user.User {
+// GetAll return all users as slice
+func (us *InMemoryUserStorage) GetAll(ctx context.Context) ([]user.User, error) {
 	us.lock.RLock()
 	defer us.lock.RUnlock()
 	users := make([]user.User, 0, len(us.store))
@@ -73,5 +153,5 @@ func (us *UserStorage) GetAll() []user.User {
 	for _, tx := range us.store {
 		users = append(users, tx)
 	}
-	return users
+	return users, nil
 }
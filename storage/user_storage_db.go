@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"calendar/user"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewDbUserStorage return user store which shares db connection with events store
+func NewDbUserStorage(store *repository) *userRepository {
+	return &userRepository{store.db}
+}
+
+// GetUserByLogin get user entity from db by login
+func (ur *userRepository) GetUserByLogin(ctx context.Context, login string) (user.User, error) {
+	return ur.first(ctx, "login = ?", login)
+}
+
+// GetUserById get user entity from db by id
+func (ur *userRepository) GetUserById(ctx context.Context, id uuid.UUID) (user.User, error) {
+	return ur.first(ctx, "id = ?", id)
+}
+
+// GetUserByFeedHash get user entity by hash of calendar feed token
+func (ur *userRepository) GetUserByFeedHash(ctx context.Context, hash string) (user.User, error) {
+	if hash == "" {
+		return user.User{}, ErrUserNotFound
+	}
+	return ur.first(ctx, "feed_hash = ?", hash)
+}
+
+func (ur *userRepository) first(ctx context.Context, query string, arg interface{}) (user.User, error) {
+	var u user.User
+	err := ur.db.WithContext(ctx).Where(query, arg).First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return u, ErrUserNotFound
+	}
+	return u, err
+}
+
+// IsExist check if user already in db
+func (ur *userRepository) IsExist(ctx context.Context, login string) (bool, error) {
+	var cnt int64
+	result := ur.db.WithContext(ctx).Model(&user.User{}).Where("login = ?", login).Count(&cnt)
+	return cnt > 0, result.Error
+}
+
+// Save create new user or update existing one with the same id
+func (ur *userRepository) Save(ctx context.Context, u user.User) (user.User, error) {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	err := ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cnt int64
+		result := tx.Model(&user.User{}).Where("login = ? AND id <> ?", u.Login, u.ID).Count(&cnt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if cnt > 0 {
+			return ErrUserExists
+		}
+		return tx.Omit("Events").Save(&u).Error
+	})
+	return u, err
+}
+
+// UpdateTimezone set timezone of user if it is known location
+func (ur *userRepository) UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return err
+	}
+	return ur.update(ctx, id, map[string]interface{}{"timezone": timezone})
+}
+
+// UpdateFeedHash set hash of calendar feed token, empty hash revokes the feed
+func (ur *userRepository) UpdateFeedHash(ctx context.Context, id uuid.UUID, hash string) error {
+	return ur.update(ctx, id, map[string]interface{}{"feed_hash": hash})
+}
+
+// UpdatePassword set hash of password
+func (ur *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	return ur.update(ctx, id, map[string]interface{}{"password_hash": hash})
+}
+
+// UpdateWorkHours set working hours of user, empty values remove them
+func (ur *userRepository) UpdateWorkHours(ctx context.Context, id uuid.UUID, start, end string) error {
+	return ur.update(ctx, id, map[string]interface{}{"work_start": start, "work_end": end})
+}
+
+func (ur *userRepository) update(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	result := ur.db.WithContext(ctx).Model(&user.User{}).Where("id = ?", id).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// mysql doesn't count rows which already have the value, so check that user exists
+		if _, err := ur.GetUserById(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count return number of users in db
+func (ur *userRepository) Count(ctx context.Context) (int, error) {
+	var cnt int64
+	result := ur.db.WithContext(ctx).Model(&user.User{}).Count(&cnt)
+	return int(cnt), result.Error
+}
+
+// GetAll return all users as slice
+func (ur *userRepository) GetAll(ctx context.Context) ([]user.User, error) {
+	var users []user.User
+	result := ur.db.WithContext(ctx).Find(&users)
+	return users, result.Error
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"calendar/webhook"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrWebhookNotFound is returned when webhook doesn't exist or belongs to other user
+	ErrWebhookNotFound = webhook.ErrWebhookNotFound
+	// ErrDeliveryNotFound is returned when delivery doesn't exist or belongs to other user
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// WebhookStore stores webhooks of users and log of their deliveries
+type WebhookStore interface {
+	webhook.Store
+	CreateWebhook(ctx context.Context, h webhook.Webhook) error
+	// DeleteWebhook remove webhook of user with its deliveries
+	DeleteWebhook(ctx context.Context, userId, id uuid.UUID) error
+	// GetDeliveries return deliveries of webhook of user, the latest first
+	GetDeliveries(ctx context.Context, userId, webhookId uuid.UUID, limit int) ([]webhook.Delivery, error)
+	GetDelivery(ctx context.Context, userId, id uuid.UUID) (webhook.Delivery, error)
+}
+
+// InMemoryWebhookStorage collects webhooks and deliveries to maps by id
+type InMemoryWebhookStorage struct {
+	hooks      map[uuid.UUID]webhook.Webhook
+	deliveries map[uuid.UUID]webhook.Delivery
+	lock       sync.RWMutex
+}
+
+// NewWebhookStorage initialises an empty store
+func NewWebhookStorage() *InMemoryWebhookStorage {
+	return &InMemoryWebhookStorage{hooks: map[uuid.UUID]webhook.Webhook{}, deliveries: map[uuid.UUID]webhook.Delivery{}}
+}
+
+// CreateWebhook add webhook
+func (ws *InMemoryWebhookStorage) CreateWebhook(ctx context.Context, h webhook.Webhook) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	ws.hooks[h.ID] = h
+	return nil
+}
+
+// GetWebhooks return webhooks of user, the oldest first
+func (ws *InMemoryWebhookStorage) GetWebhooks(ctx context.Context, userId uuid.UUID) ([]webhook.Webhook, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	hooks := make([]webhook.Webhook, 0)
+	for _, h := range ws.hooks {
+		if h.UserId == userId {
+			hooks = append(hooks, h)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks, nil
+}
+
+// GetWebhook return webhook by id
+func (ws *InMemoryWebhookStorage) GetWebhook(ctx context.Context, userId, id uuid.UUID) (webhook.Webhook, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	h, ok := ws.hooks[id]
+	if !ok || (userId != uuid.Nil && h.UserId != userId) {
+		return webhook.Webhook{}, ErrWebhookNotFound
+	}
+	return h, nil
+}
+
+// DeleteWebhook remove webhook and its deliveries
+func (ws *InMemoryWebhookStorage) DeleteWebhook(ctx context.Context, userId, id uuid.UUID) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	if h, ok := ws.hooks[id]; !ok || h.UserId != userId {
+		return ErrWebhookNotFound
+	}
+	delete(ws.hooks, id)
+	for did, d := range ws.deliveries {
+		if d.WebhookId == id {
+			delete(ws.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// CreateDeliveries add deliveries
+func (ws *InMemoryWebhookStorage) CreateDeliveries(ctx context.Context, deliveries []webhook.Delivery) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	for _, d := range deliveries {
+		ws.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+// ClaimDeliveries return due pending deliveries, the earliest first
+func (ws *InMemoryWebhookStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	due := make([]webhook.Delivery, 0)
+	for _, d := range ws.deliveries {
+		if d.Status == webhook.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		ws.deliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// UpdateDelivery save delivery, delivery of deleted webhook is not added again
+func (ws *InMemoryWebhookStorage) UpdateDelivery(ctx context.Context, d webhook.Delivery) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	if _, ok := ws.deliveries[d.ID]; ok {
+		ws.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+// GetDeliveries return deliveries of webhook
+func (ws *InMemoryWebhookStorage) GetDeliveries(ctx context.Context, userId, webhookId uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	deliveries := make([]webhook.Delivery, 0)
+	for _, d := range ws.deliveries {
+		if d.UserId == userId && d.WebhookId == webhookId {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// GetDelivery return delivery of user by id
+func (ws *InMemoryWebhookStorage) GetDelivery(ctx context.Context, userId, id uuid.UUID) (webhook.Delivery, error) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	d, ok := ws.deliveries[id]
+	if !ok || d.UserId != userId {
+		return webhook.Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"calendar/webhook"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewDbWebhookStorage return webhook store which shares db connection with events store
+func NewDbWebhookStorage(store *repository) *webhookRepository {
+	return &webhookRepository{store.db}
+}
+
+// CreateWebhook add webhook
+func (wr *webhookRepository) CreateWebhook(ctx context.Context, h webhook.Webhook) error {
+	return wr.db.WithContext(ctx).Create(&h).Error
+}
+
+// GetWebhooks return webhooks of user, the oldest first
+func (wr *webhookRepository) GetWebhooks(ctx context.Context, userId uuid.UUID) ([]webhook.Webhook, error) {
+	hooks := make([]webhook.Webhook, 0)
+	err := wr.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at").Find(&hooks).Error
+	return hooks, err
+}
+
+// GetWebhook return webhook by id
+func (wr *webhookRepository) GetWebhook(ctx context.Context, userId, id uuid.UUID) (webhook.Webhook, error) {
+	var h webhook.Webhook
+	query := wr.db.WithContext(ctx).Where("id = ?", id)
+	if userId != uuid.Nil {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.First(&h).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return h, ErrWebhookNotFound
+	}
+	return h, err
+}
+
+// DeleteWebhook remove webhook and its deliveries
+func (wr *webhookRepository) DeleteWebhook(ctx context.Context, userId, id uuid.UUID) error {
+	return wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userId).Delete(&webhook.Webhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&webhook.Delivery{}).Error
+	})
+}
+
+// CreateDeliveries add deliveries
+func (wr *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []webhook.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return wr.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+// ClaimDeliveries lock due pending deliveries, rows locked by other dispatchers are skipped
+func (wr *webhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", webhook.DeliveryPending, now).
+			Order("next_attempt_at").Limit(limit).Find(&deliveries)
+		if result.Error != nil || len(deliveries) == 0 {
+			return result.Error
+		}
+		ids := make([]uuid.UUID, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+		}
+		nextAttemptAt := now.Add(lease)
+		result = tx.Model(&webhook.Delivery{}).Where("id IN ?", ids).Update("next_attempt_at", nextAttemptAt)
+		for i := range deliveries {
+			deliveries[i].NextAttemptAt = nextAttemptAt
+		}
+		return result.Error
+	})
+	return deliveries, err
+}
+
+// UpdateDelivery save result of attempt
+func (wr *webhookRepository) UpdateDelivery(ctx context.Context, d webhook.Delivery) error {
+	return wr.db.WithContext(ctx).Model(&d).
+		Select("status", "attempts", "response_status", "last_error", "next_attempt_at", "delivered_at").Updates(&d).Error
+}
+
+// GetDeliveries return deliveries of webhook
+func (wr *webhookRepository) GetDeliveries(ctx context.Context, userId, webhookId uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	deliveries := make([]webhook.Delivery, 0)
+	err := wr.db.WithContext(ctx).Where("user_id = ? AND webhook_id = ?", userId, webhookId).
+		Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetDelivery return delivery of user by id
+func (wr *webhookRepository) GetDelivery(ctx context.Context, userId, id uuid.UUID) (webhook.Delivery, error) {
+	var d webhook.Delivery
+	err := wr.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).First(&d).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return d, ErrDeliveryNotFound
+	}
+	return d, err
+}
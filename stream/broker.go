@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = time.Second
+	batchSize       = 500
+	// bufferSize is number of changes waiting for slow subscriber, it is closed when buffer is full
+	bufferSize = 256
+)
+
+// ErrClosed is returned by Subscribe after Close
+var ErrClosed = errors.New("change stream is closed")
+
+// Subscription gets changes of one user in order of Seq. C is closed when subscriber is too slow
+// or broker is closed, then client reconnects and catches up from change log
+type Subscription struct {
+	UserId uuid.UUID
+	C      chan event.Change
+}
+
+// Broker reads change log of store and fans changes out to subscribers.
+// Change log is the only source, so changes made through any server with the same db are streamed.
+// Stores commit changes in order of Seq, so every change up to the latest read one is committed,
+// Seq can still have gaps, like after trimmed log
+type Broker struct {
+	Log storage.ChangeLog
+	// Interval is time between reads of change log
+	Interval time.Duration
+
+	// poll is held while change log is read, lock guards the fields below and is never held during queries,
+	// so slow db doesn't block Subscribe and Position
+	poll   sync.Mutex
+	lock   sync.Mutex
+	ready  bool
+	closed bool
+	last   int64
+	subs   map[*Subscription]struct{}
+}
+
+// NewBroker return broker of change log
+func NewBroker(changeLog storage.ChangeLog) *Broker {
+	return &Broker{Log: changeLog, Interval: defaultInterval, subs: map[*Subscription]struct{}{}}
+}
+
+// start broker from the end of change log when it is not started yet
+func (b *Broker) start(ctx context.Context) error {
+	b.lock.Lock()
+	ready := b.ready
+	b.lock.Unlock()
+	if ready {
+		return nil
+	}
+	b.poll.Lock()
+	defer b.poll.Unlock()
+	return b.init(ctx)
+}
+
+// init read the end of change log, caller holds poll
+func (b *Broker) init(ctx context.Context) error {
+	if b.ready {
+		return nil
+	}
+	_, last, err := b.Log.ChangeBounds(ctx)
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	b.last, b.ready = last, true
+	b.lock.Unlock()
+	return nil
+}
+
+// Subscribe add subscriber of changes of user and return Seq of the last change broker has sent,
+// every later change of user comes to Subscription.C
+func (b *Broker) Subscribe(ctx context.Context, userId uuid.UUID) (*Subscription, int64, error) {
+	if err := b.start(ctx); err != nil {
+		return nil, 0, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil, 0, ErrClosed
+	}
+	s := &Subscription{UserId: userId, C: make(chan event.Change, bufferSize)}
+	b.subs[s] = struct{}{}
+	return s, b.last, nil
+}
+
+// Position return Seq of the last change broker has sent. Every change up to it is committed,
+// so it is safe point to continue reading of change log
+func (b *Broker) Position(ctx context.Context) (int64, error) {
+	if err := b.start(ctx); err != nil {
+		return 0, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.last, nil
+}
+
+// Unsubscribe remove subscriber
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.C)
+	}
+}
+
+// Close end all subscriptions, it is called on shutdown of server, because streams never end by themselves
+func (b *Broker) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.C)
+	}
+}
+
+// Run read change log every Interval until ctx is cancelled
+func (b *Broker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := b.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Println("change stream: ", err)
+		}
+	}
+}
+
+// Poll send new changes of log to subscribers
+func (b *Broker) Poll(ctx context.Context) error {
+	b.poll.Lock()
+	defer b.poll.Unlock()
+	if err := b.init(ctx); err != nil {
+		return err
+	}
+	for {
+		// last is changed only under poll, so it is read here without lock
+		changes, err := b.Log.GetChanges(ctx, uuid.Nil, b.last, batchSize)
+		if err != nil {
+			return err
+		}
+		b.advance(changes)
+		if len(changes) < batchSize {
+			return nil
+		}
+	}
+}
+
+// advance send changes to subscribers and move position to the last of them
+func (b *Broker) advance(changes []event.Change) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, c := range changes {
+		b.last = c.Seq
+		b.send(c)
+	}
+}
+
+// send change to subscribers of its user, slow subscriber is closed instead of blocking the others
+func (b *Broker) send(c event.Change) {
+	for s := range b.subs {
+		if s.UserId != c.UserId {
+			continue
+		}
+		select {
+		case s.C <- c:
+		default:
+			delete(b.subs, s)
+			close(s.C)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package stream
+
+import (
+	"calendar/event"
+	"calendar/storage"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLog is change log whose changes are committed by test in any order of Seq
+type testLog struct {
+	lock    sync.Mutex
+	changes []event.Change
+	// blocked is closed to finish GetChanges, nil doesn't block
+	blocked chan struct{}
+}
+
+func (tl *testLog) commit(userId uuid.UUID, seqs ...int64) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	for _, seq := range seqs {
+		tl.changes = append(tl.changes, event.Change{Seq: seq, UserId: userId, EventId: uuid.New(), Type: event.ChangeUpdated})
+	}
+}
+
+func (tl *testLog) GetChanges(ctx context.Context, userId uuid.UUID, after int64, limit int) ([]event.Change, error) {
+	if tl.blocked != nil {
+		<-tl.blocked
+	}
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	var changes []event.Change
+	for _, c := range tl.changes {
+		if c.Seq > after && (userId == uuid.Nil || c.UserId == userId) {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (tl *testLog) ChangeBounds(ctx context.Context) (first, last int64, err error) {
+	return 0, 0, nil
+}
+
+// received return Seqs waiting in subscription
+func received(s *Subscription) []int64 {
+	var seqs []int64
+	for len(s.C) > 0 {
+		seqs = append(seqs, (<-s.C).Seq)
+	}
+	return seqs
+}
+
+func TestBrokerSendsChangesOfUser(t *testing.T) {
+	ctx := context.Background()
+	events := storage.NewEventStorage()
+	organizer, attendee, other := uuid.New(), uuid.New(), uuid.New()
+	b := NewBroker(events)
+	orgSub, pos, err := b.Subscribe(ctx, organizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attSub, _, _ := b.Subscribe(ctx, attendee)
+	otherSub, _, _ := b.Subscribe(ctx, other)
+
+	ev := event.Event{ID: uuid.New(), Title: "Planning", DateTime: time.Now().Add(time.Hour).UTC(), Timezone: "UTC",
+		Duration: time.Hour, UserId: organizer}
+	if _, err = events.Save(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	ev.Attendees = []event.Attendee{{ID: uuid.New(), EventId: ev.ID, UserId: attendee, Login: "attendee", Status: event.NeedsAction}}
+	if _, err = events.Save(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.Delete(ctx, ev.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []event.ChangeType{event.ChangeCreated, event.ChangeUpdated, event.ChangeDeleted}
+	for i, typ := range want {
+		c := <-orgSub.C
+		if c.Type != typ || c.EventId != ev.ID || c.Seq <= pos {
+			t.Fatalf("change %d of organizer = %+v", i, c)
+		}
+		pos = c.Seq
+	}
+	if c := <-attSub.C; c.Type != event.ChangeCreated {
+		t.Fatalf("invited attendee got %+v", c)
+	}
+	if c := <-attSub.C; c.Type != event.ChangeDeleted {
+		t.Fatalf("attendee of deleted event got %+v", c)
+	}
+	if len(otherSub.C) != 0 {
+		t.Fatal("other user got changes of event")
+	}
+
+	b.Close()
+	if _, ok := <-orgSub.C; ok {
+		t.Fatal("subscription is open after close")
+	}
+	if _, _, err = b.Subscribe(ctx, organizer); err != ErrClosed {
+		t.Fatalf("subscribe after close: %v", err)
+	}
+}
+
+func TestBrokerSkipsMissingSeq(t *testing.T) {
+	ctx := context.Background()
+	userId := uuid.New()
+	tl := &testLog{}
+	b := NewBroker(tl)
+	sub, _, err := b.Subscribe(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Seq 2 is never written, like change of transaction which is rolled back
+	tl.commit(userId, 1, 3)
+	_ = b.Poll(ctx)
+	if got := received(sub); fmt.Sprint(got) != "[1 3]" {
+		t.Fatalf("changes = %v", got)
+	}
+	tl.commit(userId, 4)
+	_ = b.Poll(ctx)
+	if pos, _ := b.Position(ctx); pos != 4 {
+		t.Fatalf("position = %d", pos)
+	}
+	if got := received(sub); fmt.Sprint(got) != "[4]" {
+		t.Fatalf("changes after gap = %v", got)
+	}
+}
+
+func TestBrokerDoesNotBlockDuringPoll(t *testing.T) {
+	ctx := context.Background()
+	tl := &testLog{blocked: make(chan struct{})}
+	b := NewBroker(tl)
+	if _, err := b.Position(ctx); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- b.Poll(ctx) }()
+
+	subscribed := make(chan error)
+	go func() {
+		_, _, err := b.Subscribe(ctx, uuid.New())
+		if err == nil {
+			_, err = b.Position(ctx)
+		}
+		subscribed <- err
+	}()
+	select {
+	case err := <-subscribed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscribe waits for read of change log")
+	}
+	close(tl.blocked)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	for _, seq := range []int64{0, 1, 1 << 40} {
+		got, err := ParseToken(EncodeToken(seq))
+		if err != nil || got != seq {
+			t.Fatalf("token of %d is parsed as %d, %v", seq, got, err)
+		}
+	}
+	for _, token := range []string{"", "42", EncodeToken(1) + "!", "djEuLTE"} {
+		if _, err := ParseToken(token); err != ErrInvalidToken {
+			t.Fatalf("token %q: %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package stream
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const tokenPrefix = "v1."
+
+// ErrInvalidToken is returned for sync token which is not made by EncodeToken
+var ErrInvalidToken = errors.New("invalid sync token")
+
+// EncodeToken return opaque sync token of position in change log
+func EncodeToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(tokenPrefix + strconv.FormatInt(seq, 10)))
+}
+
+// ParseToken return position in change log of sync token
+func ParseToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), tokenPrefix) {
+		return 0, ErrInvalidToken
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(b), tokenPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidToken
+	}
+	return seq, nil
+}
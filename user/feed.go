@@ -0,0 +1,36 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// feedTokenSize is number of random bytes in feed and refresh tokens
+const feedTokenSize = 32
+
+// NewFeedToken generate random token for calendar feed url and its hash to store
+func NewFeedToken() (token string, hash string, err error) {
+	return newSecretToken()
+}
+
+// HashFeedToken return hash of feed token, only hash is stored,
+// so leaked storage doesn't give access to feeds
+func HashFeedToken(token string) string {
+	return hashToken(token)
+}
+
+func newSecretToken() (token string, hash string, err error) {
+	b := make([]byte, feedTokenSize)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	maxLoginLength    = 64
+	minPasswordLength = 8
+	// bcrypt uses only first 72 bytes of password
+	maxPasswordLength = 72
+)
+
+var (
+	// login is part of paths, like /dav/{login}/ and shares of calendars, so it can't have slash
+	ErrInvalidLogin    = errors.New("login must be from 1 to 64 characters without /")
+	ErrInvalidEmail    = errors.New("wrong email")
+	ErrInvalidPassword = errors.New("password must be from 8 to 72 bytes")
+)
+
+// dummyHash is compared with password of unknown user,
+// so response time doesn't tell whether login exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Registration is form of new user
+type Registration struct {
+	Login    string `json:"login"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// Validate check registration form, empty timezone is UTC
+func (reg *Registration) Validate() error {
+	if reg.Login == "" || len([]rune(reg.Login)) > maxLoginLength || strings.Contains(reg.Login, "/") {
+		return ErrInvalidLogin
+	}
+	addr, err := mail.ParseAddress(reg.Email)
+	if err != nil || addr.Address != reg.Email {
+		return ErrInvalidEmail
+	}
+	if len(reg.Password) < minPasswordLength || len(reg.Password) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+	if reg.Timezone == "" {
+		reg.Timezone = "UTC"
+	}
+	_, err = time.LoadLocation(reg.Timezone)
+	return err
+}
+
+// PasswordChange is form to change password, current password is checked again
+type PasswordChange struct {
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword"`
+}
+
+// Validate check new password
+func (pc *PasswordChange) Validate() error {
+	if len(pc.NewPassword) < minPasswordLength || len(pc.NewPassword) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// HashPassword return bcrypt hash of password to store instead of password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword compare password with stored hash in constant time,
+// empty hash is checked against dummy hash to spend the same time as for real user
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
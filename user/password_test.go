@@ -0,0 +1,49 @@
+package user
+
+import (
+	"testing"
+)
+
+func TestRegistrationValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		reg  Registration
+		ok   bool
+	}{
+		{"valid", Registration{Login: "User1", Email: "user@ukr.net", Password: "password1", Timezone: "Europe/Riga"}, true},
+		{"empty timezone", Registration{Login: "User1", Email: "user@ukr.net", Password: "password1"}, true},
+		{"empty login", Registration{Email: "user@ukr.net", Password: "password1"}, false},
+		{"login with slash", Registration{Login: "team/user", Email: "user@ukr.net", Password: "password1"}, false},
+		{"wrong email", Registration{Login: "User1", Email: "user", Password: "password1"}, false},
+		{"email with name", Registration{Login: "User1", Email: "User <user@ukr.net>", Password: "password1"}, false},
+		{"short password", Registration{Login: "User1", Email: "user@ukr.net", Password: "pass"}, false},
+		{"wrong timezone", Registration{Login: "User1", Email: "user@ukr.net", Password: "password1", Timezone: "Mars/Olympus"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.reg.Validate()
+			if (err == nil) != c.ok {
+				t.Errorf("got error %v, want ok %v", err, c.ok)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "password1" {
+		t.Error("password is stored as is")
+	}
+	if !CheckPassword(hash, "password1") {
+		t.Error("right password is rejected")
+	}
+	if CheckPassword(hash, "password2") {
+		t.Error("wrong password is accepted")
+	}
+	if CheckPassword("", "") {
+		t.Error("empty hash is accepted")
+	}
+}
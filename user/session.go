@@ -0,0 +1,65 @@
+package user
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	// AccessTokenTTL is lifetime of JWT in token cookie
+	AccessTokenTTL = 5 * time.Minute
+	// RefreshTokenTTL is lifetime of session which is not refreshed
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Session is one login of user, it lives while refresh token is rotated before expiration.
+// Only hashes of the current and the previous refresh tokens are stored,
+// the previous one is kept to detect reuse of stolen token
+type Session struct {
+	ID           uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserId       uuid.UUID  `json:"-" gorm:"index"`
+	RefreshHash  string     `json:"-" gorm:"size:64;index"`
+	PreviousHash string     `json:"-" gorm:"size:64;index"`
+	UserAgent    string     `json:"userAgent,omitempty" gorm:"size:256"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastUsedAt   time.Time  `json:"lastUsedAt"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	RevokedAt    *time.Time `json:"-"`
+	Current      bool       `json:"current" gorm:"-"`
+}
+
+// NewSession create session of user and return it with refresh token, which is not stored
+func NewSession(userId uuid.UUID, userAgent string) (Session, string, error) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	if len(userAgent) > 256 {
+		userAgent = userAgent[:256]
+	}
+	now := time.Now().UTC()
+	return Session{
+		ID:          uuid.New(),
+		UserId:      userId,
+		RefreshHash: hash,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(RefreshTokenTTL),
+	}, token, nil
+}
+
+// NewRefreshToken generate next refresh token of session and its hash to store
+func NewRefreshToken() (token string, hash string, err error) {
+	return newSecretToken()
+}
+
+// HashRefreshToken return hash of refresh token to find session
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+// IsActive check that session is not revoked and not expired
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
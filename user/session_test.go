@@ -0,0 +1,32 @@
+package user
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestNewSession(t *testing.T) {
+	userId := uuid.New()
+	session, token, err := NewSession(userId, "test agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserId != userId {
+		t.Errorf("got user %v, want %v", session.UserId, userId)
+	}
+	if session.RefreshHash != HashRefreshToken(token) || session.RefreshHash == token {
+		t.Error("only hash of refresh token must be stored")
+	}
+	if !session.IsActive(time.Now()) {
+		t.Error("new session is not active")
+	}
+	if session.IsActive(session.ExpiresAt) {
+		t.Error("expired session is active")
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	if session.IsActive(now) {
+		t.Error("revoked session is active")
+	}
+}
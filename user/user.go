@@ -9,16 +9,20 @@ import (
 
 type User struct {
 	gorm.Model
-	ID       uuid.UUID     `json:"id,omitempty" gorm:"primaryKey;"`
-	Login    string        `json:"login"`
-	Email    string        `json:"email,omitempty"`
-	Password string        `json:"password,omitempty" gorm:"column:password_hash"`
-	Timezone string        `json:"timezone,omitempty"`
-	Events   []event.Event `gorm:"foreignKey:UserId"`
+	ID       uuid.UUID `json:"id,omitempty" gorm:"primaryKey;"`
+	Login    string    `json:"login" gorm:"size:64;uniqueIndex"`
+	Email    string    `json:"email,omitempty"`
+	Password string    `json:"-" gorm:"column:password_hash"`
+	Timezone string    `json:"timezone,omitempty"`
+	FeedHash string    `json:"-" gorm:"column:feed_hash;index"`
+	// WorkStart and WorkEnd are working hours like "09:00" in user's timezone, used to suggest meeting time
+	WorkStart string        `json:"workStart,omitempty" gorm:"size:5"`
+	WorkEnd   string        `json:"workEnd,omitempty" gorm:"size:5"`
+	Events    []event.Event `gorm:"foreignKey:UserId"`
 }
 
-// Create the JWT key used to create the signature
-var JwtKey = []byte("my_secret_key")
+// JwtKey is the key used to create the signature, it is set from config at startup
+var JwtKey []byte
 
 // Create a struct to read the username and password from the request body
 type Credentials struct {
@@ -29,8 +33,9 @@ type Credentials struct {
 
 // Create a struct that will be encoded to a JWT.
 type Claims struct {
-	ID       uuid.UUID `json:"id"`
-	Username string    `json:"username"`
-	Timezone string    `json:"timezone"`
+	ID        uuid.UUID `json:"id"`
+	SessionId uuid.UUID `json:"sid"`
+	Username  string    `json:"username"`
+	Timezone  string    `json:"timezone"`
 	jwt.StandardClaims
 }
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrPrivateURL  = errors.New("webhook url must not point to private, loopback or link-local address")
+	ErrUnknownHost = errors.New("webhook host can't be resolved")
+)
+
+// sharedAddressSpace is carrier-grade NAT range (RFC 6598), it is not reachable from internet like private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// lookupIP resolves host of webhook, tests replace it
+var lookupIP = net.DefaultResolver.LookupIPAddr
+
+// publicIP report whether ip may be reached by webhook, so users can't make server call its own network
+// (metadata of cloud at 169.254.169.254, databases, admin pages)
+func publicIP(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// checkHost return ErrPrivateURL when host is or resolves to address which is not public
+func checkHost(ctx context.Context, host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateURL
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrPrivateURL
+		}
+		return nil
+	}
+	addrs, err := lookupIP(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrUnknownHost
+	}
+	for _, a := range addrs {
+		if !publicIP(a.IP) {
+			return ErrPrivateURL
+		}
+	}
+	return nil
+}
+
+// dialControl refuse connection to address which is not public, it is called after host is resolved,
+// so webhook whose host is changed to private address after it was created can't be used either
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicIP(net.ParseIP(host)) {
+		return ErrPrivateURL
+	}
+	return nil
+}
+
+// NewClient return client for webhooks which connects only to public addresses, redirects are checked too
+// because every connection is checked when it is dialed
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Control: dialControl}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
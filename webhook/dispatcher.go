@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval    = 5 * time.Second
+	defaultLease       = time.Minute
+	defaultMaxAttempts = 8
+	defaultWorkers     = 4
+	defaultBatchSize   = 100
+	// retryDelay is delay after first failed attempt, it doubles with every next attempt
+	retryDelay   = 30 * time.Second
+	maxErrorSize = 256
+)
+
+// ErrWebhookNotFound is returned when webhook doesn't exist or belongs to other user
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Store is part of storage.WebhookStore which dispatcher uses
+type Store interface {
+	// GetWebhooks return webhooks of user
+	GetWebhooks(ctx context.Context, userId uuid.UUID) ([]Webhook, error)
+	// GetWebhook return webhook of user, uuid.Nil user finds webhook of any user
+	GetWebhook(ctx context.Context, userId, id uuid.UUID) (Webhook, error)
+	CreateDeliveries(ctx context.Context, deliveries []Delivery) error
+	// ClaimDeliveries return pending deliveries due at now and move their next attempt to now+lease,
+	// so other dispatchers skip them until delivery is updated or lease is over
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	UpdateDelivery(ctx context.Context, d Delivery) error
+}
+
+// Dispatcher sends deliveries to webhooks in background with exponential retry.
+// Deliveries are stored before sending, so changes are not lost when server stops
+type Dispatcher struct {
+	Store Store
+	// Client sends deliveries, client of NewClient doesn't connect to private addresses
+	Client *http.Client
+	// Interval is time between checks of deliveries to retry
+	Interval    time.Duration
+	Lease       time.Duration
+	MaxAttempts int
+	// Workers is number of deliveries sent at once, so slow webhook doesn't hold the others
+	Workers   int
+	BatchSize int
+	wake      chan struct{}
+}
+
+// NewDispatcher return dispatcher with default settings
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		Client:      NewClient(10 * time.Second),
+		Interval:    defaultInterval,
+		Lease:       defaultLease,
+		MaxAttempts: defaultMaxAttempts,
+		Workers:     defaultWorkers,
+		BatchSize:   defaultBatchSize,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Publish store deliveries of change of event to webhooks of user and wake dispatcher, nothing is sent here
+func (d *Dispatcher) Publish(ctx context.Context, userId uuid.UUID, typ string, eventJSON []byte) error {
+	hooks, err := d.Store.GetWebhooks(ctx, userId)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	deliveries, err := NewDeliveries(hooks, typ, eventJSON, time.Now())
+	if err != nil {
+		return err
+	}
+	if err = d.Store.CreateDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	d.Wake()
+	return nil
+}
+
+// Wake start sending of new deliveries without waiting for Interval
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run send due deliveries until ctx is cancelled, deliveries being sent are finished first
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if err := d.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Println("webhooks: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Tick send deliveries due at now
+func (d *Dispatcher) Tick(ctx context.Context, now time.Time) error {
+	deliveries, err := d.Store.ClaimDeliveries(ctx, now, d.Lease, d.BatchSize)
+	if err != nil {
+		return err
+	}
+	sem := make(chan struct{}, d.Workers)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := d.deliver(ctx, delivery, now); err != nil {
+				log.Printf("webhooks: delivery %s: %v", delivery.ID, err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver send delivery and save result, failed delivery is retried after growing delay
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery, now time.Time) error {
+	hook, err := d.Store.GetWebhook(ctx, uuid.Nil, delivery.WebhookId)
+	if errors.Is(err, ErrWebhookNotFound) {
+		delivery.Status = DeliveryFailed
+		delivery.LastError = "webhook is deleted"
+		return d.Store.UpdateDelivery(context.Background(), delivery)
+	}
+	if err != nil {
+		return err
+	}
+	status, err := d.send(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		// sending is interrupted by shutdown, delivery is sent again after lease
+		return ctx.Err()
+	}
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivered := time.Now().UTC()
+		delivery.Status = DeliveryDelivered
+		delivery.DeliveredAt = &delivered
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = DeliveryFailed
+		delivery.LastError = truncate(err.Error())
+	default:
+		delivery.NextAttemptAt = now.Add(retryDelay << (delivery.Attempts - 1))
+		delivery.LastError = truncate(err.Error())
+	}
+	// result is saved even after cancel of ctx, because webhook may have got the delivery
+	return d.Store.UpdateDelivery(context.Background(), delivery)
+}
+
+// send post signed payload, response other than 2xx is error
+func (d *Dispatcher) send(ctx context.Context, hook Webhook, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), delivery.Payload))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TypeHeader, delivery.Type)
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorSize {
+		return s[:maxErrorSize]
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"time"
+)
+
+// Types of changes of events which are sent to webhooks
+const (
+	EventCreated = "event.created"
+	EventUpdated = "event.updated"
+	EventDeleted = "event.deleted"
+)
+
+const (
+	// SignatureHeader is header with time of sending and HMAC-SHA256 of "time.body" made with secret of webhook,
+	// like "t=1627894800,v1=5257a869..."
+	SignatureHeader = "X-Calendar-Signature"
+	// DeliveryHeader is id of delivery, TypeHeader is type of change
+	DeliveryHeader = "X-Calendar-Delivery"
+	TypeHeader     = "X-Calendar-Event"
+
+	secretSize    = 32
+	minSecretSize = 16
+	maxURLSize    = 2048
+)
+
+var (
+	ErrInvalidURL    = errors.New("webhook url must be absolute http or https url")
+	ErrInvalidSecret = errors.New("webhook secret must be at least 16 bytes")
+)
+
+// Webhook is endpoint of user which gets signed changes of user's events
+type Webhook struct {
+	ID     uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId uuid.UUID `json:"-" gorm:"index"`
+	URL    string    `json:"url" gorm:"size:2048"`
+	// Secret is key of signature, it is returned only when webhook is created
+	Secret    string    `json:"secret,omitempty" gorm:"size:64"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewWebhook check url and create webhook of user, random secret is generated when it is empty.
+// Url must point to public address, host is resolved with ctx
+func NewWebhook(ctx context.Context, userId uuid.UUID, rawURL, secret string) (Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || len(rawURL) > maxURLSize {
+		return Webhook{}, ErrInvalidURL
+	}
+	if err = checkHost(ctx, u.Hostname()); err != nil {
+		return Webhook{}, err
+	}
+	if secret == "" {
+		b := make([]byte, secretSize)
+		if _, err = rand.Read(b); err != nil {
+			return Webhook{}, err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if len(secret) < minSecretSize || len(secret) > 64 {
+		return Webhook{}, ErrInvalidSecret
+	}
+	return Webhook{ID: uuid.New(), UserId: userId, URL: rawURL, Secret: secret, CreatedAt: time.Now().UTC()}, nil
+}
+
+// Sign return value of SignatureHeader for body sent at time t
+func Sign(secret string, t time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", t.Unix())
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Payload is JSON body sent to webhook
+type Payload struct {
+	// ID is the same in all attempts and replays of one change, so receivers can drop duplicates
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// DeliveryStatus is state of sending change to webhook
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed is delivery which is not accepted after all attempts or whose webhook is deleted
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is sending of one change to one webhook, it is kept as log of deliveries
+type Delivery struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	WebhookId uuid.UUID `json:"webhookId" gorm:"index"`
+	UserId    uuid.UUID `json:"-" gorm:"index"`
+	// PayloadId is id of Payload, replay of delivery has the same one
+	PayloadId uuid.UUID       `json:"payloadId"`
+	Type      string          `json:"type" gorm:"size:20"`
+	Payload   json.RawMessage `json:"payload" gorm:"type:text"`
+	Status    DeliveryStatus  `json:"status" gorm:"size:10;index"`
+	Attempts  int             `json:"attempts"`
+	// ResponseStatus is HTTP status of the last attempt, 0 when webhook was not reached
+	ResponseStatus int    `json:"responseStatus,omitempty"`
+	LastError      string `json:"lastError,omitempty" gorm:"size:256"`
+	// NextAttemptAt is time of next attempt of pending delivery, it is moved forward while delivery is being sent
+	NextAttemptAt time.Time  `json:"nextAttemptAt" gorm:"index"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// NewDeliveries create pending deliveries of change of event to every webhook
+func NewDeliveries(hooks []Webhook, typ string, eventJSON []byte, now time.Time) ([]Delivery, error) {
+	p := Payload{ID: uuid.New(), Type: typ, OccurredAt: now.UTC(), Event: eventJSON}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(hooks))
+	for _, h := range hooks {
+		deliveries = append(deliveries, Delivery{
+			ID:            uuid.New(),
+			WebhookId:     h.ID,
+			UserId:        h.UserId,
+			PayloadId:     p.ID,
+			Type:          typ,
+			Payload:       body,
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	return deliveries, nil
+}
+
+// Replay return new pending delivery with the same payload
+func (d Delivery) Replay(now time.Time) Delivery {
+	return Delivery{
+		ID:            uuid.New(),
+		WebhookId:     d.WebhookId,
+		UserId:        d.UserId,
+		PayloadId:     d.PayloadId,
+		Type:          d.Type,
+		Payload:       d.Payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
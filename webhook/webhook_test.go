@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is the least store for dispatcher, storage package can't be used here because it imports webhook
+type memStore struct {
+	hooks      []Webhook
+	deliveries map[uuid.UUID]Delivery
+	lock       sync.Mutex
+}
+
+func (ms *memStore) GetWebhooks(ctx context.Context, userId uuid.UUID) ([]Webhook, error) {
+	return ms.hooks, nil
+}
+
+func (ms *memStore) GetWebhook(ctx context.Context, userId, id uuid.UUID) (Webhook, error) {
+	for _, h := range ms.hooks {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return Webhook{}, ErrWebhookNotFound
+}
+
+func (ms *memStore) CreateDeliveries(ctx context.Context, deliveries []Delivery) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	for _, d := range deliveries {
+		ms.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+func (ms *memStore) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	var due []Delivery
+	for id, d := range ms.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = now.Add(lease)
+			ms.deliveries[id] = d
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (ms *memStore) UpdateDelivery(ctx context.Context, d Delivery) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.deliveries[d.ID] = d
+	return nil
+}
+
+func (ms *memStore) only() Delivery {
+	for _, d := range ms.deliveries {
+		return d
+	}
+	return Delivery{}
+}
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1627894800, 0)
+	got := Sign("0123456789abcdef", at, []byte(`{"id":1}`))
+	if !strings.HasPrefix(got, "t=1627894800,v1=") || len(got) != len("t=1627894800,v1=")+64 {
+		t.Errorf("Sign() = %s", got)
+	}
+	if hmac.Equal([]byte(got), []byte(Sign("0123456789abcdeX", at, []byte(`{"id":1}`)))) {
+		t.Error("signature doesn't depend on secret")
+	}
+}
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	var calls int
+	var signature, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		signature, body = r.Header.Get(SignatureHeader), string(b)
+	}))
+	defer srv.Close()
+
+	// test server listens on loopback which NewWebhook and client of dispatcher refuse
+	hook := Webhook{ID: uuid.New(), UserId: uuid.New(), URL: srv.URL, Secret: "0123456789abcdef"}
+	store := &memStore{hooks: []Webhook{hook}, deliveries: map[uuid.UUID]Delivery{}}
+	d := NewDispatcher(store)
+	d.Client = srv.Client()
+	ctx := context.Background()
+	if err := d.Publish(ctx, hook.UserId, EventCreated, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	_ = d.Tick(ctx, now)
+	failed := store.only()
+	if failed.Status != DeliveryPending || failed.Attempts != 1 || failed.ResponseStatus != http.StatusServiceUnavailable ||
+		!failed.NextAttemptAt.Equal(now.Add(retryDelay)) {
+		t.Fatalf("failed delivery = %+v", failed)
+	}
+	_ = d.Tick(ctx, now.Add(retryDelay))
+	delivered := store.only()
+	if delivered.Status != DeliveryDelivered || delivered.Attempts != 2 {
+		t.Fatalf("delivery after retry = %+v", delivered)
+	}
+	var ts int64
+	if _, err := fmt.Sscanf(signature, "t=%d,", &ts); err != nil || Sign(hook.Secret, time.Unix(ts, 0), []byte(body)) != signature {
+		t.Errorf("wrong signature %q of body %s", signature, body)
+	}
+	if !strings.Contains(body, `"type":"event.created"`) || !strings.Contains(body, `"event":{"id":"1"}`) {
+		t.Errorf("payload = %s", body)
+	}
+}
+
+func TestNewWebhookRejectsPrivateAddresses(t *testing.T) {
+	lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		case "internal.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.5")}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	defer func() { lookupIP = net.DefaultResolver.LookupIPAddr }()
+
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://example.com/hook", nil},
+		{"https://93.184.216.34:8443/hook", nil},
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"http://localhost:8080/hook", ErrPrivateURL},
+		{"http://127.0.0.1/hook", ErrPrivateURL},
+		{"http://[::1]/hook", ErrPrivateURL},
+		{"http://10.1.2.3/hook", ErrPrivateURL},
+		{"http://192.168.0.1/hook", ErrPrivateURL},
+		{"http://169.254.169.254/latest/meta-data", ErrPrivateURL},
+		{"http://[fe80::1]/hook", ErrPrivateURL},
+		{"http://0.0.0.0/hook", ErrPrivateURL},
+		{"http://internal.example.com/hook", ErrPrivateURL},
+		{"http://unknown.example.com/hook", ErrUnknownHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := NewWebhook(context.Background(), uuid.New(), tt.url, "")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("NewWebhook() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherDoesNotDialPrivateAddress(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	// webhook whose host resolves to loopback after it was created
+	hook := Webhook{ID: uuid.New(), UserId: uuid.New(), URL: srv.URL, Secret: "0123456789abcdef"}
+	store := &memStore{hooks: []Webhook{hook}, deliveries: map[uuid.UUID]Delivery{}}
+	d := NewDispatcher(store)
+	ctx := context.Background()
+	if err := d.Publish(ctx, hook.UserId, EventCreated, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Tick(ctx, time.Now())
+	failed := store.only()
+	if calls != 0 || failed.Status != DeliveryPending || failed.ResponseStatus != 0 ||
+		!strings.Contains(failed.LastError, ErrPrivateURL.Error()) {
+		t.Fatalf("delivery to loopback = %+v, calls = %d", failed, calls)
+	}
+}